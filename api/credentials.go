@@ -0,0 +1,38 @@
+package api
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// What credentials are needed for
+type Access int
+
+const (
+	// cloning
+	AccessRead Access = iota
+
+	// pushing
+	AccessWrite
+)
+
+func (a Access) String() string {
+	if a == AccessWrite {
+		return "write"
+	}
+	return "read"
+}
+
+// Works out the credentials for a repository, see SetCredentialResolver
+type CredentialResolver interface {
+	// the auth method for accessing repoUrl, or nil if there are no credentials for it.
+	// An error fails the step that needed the credentials.
+	Resolve(ctx context.Context, repoUrl string, access Access) (transport.AuthMethod, error)
+}
+
+// Adapts a function to a CredentialResolver
+type CredentialResolverFunc func(ctx context.Context, repoUrl string, access Access) (transport.AuthMethod, error)
+
+func (f CredentialResolverFunc) Resolve(ctx context.Context, repoUrl string, access Access) (transport.AuthMethod, error) {
+	return f(ctx, repoUrl, access)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+)
+
+// Kinds of failures, to be checked with errors.Is
+//
+// Errors returned by an instance are *Error values that wrap the underlying error (often one from
+// go-git, which errors.Is also finds) along with one of these kinds.
+var (
+	// methods were called in the wrong order, e.g. Generate before WriteRenderSpecFile, or a repo was cloned twice
+	ErrWrongOrder = errors.New("implementation error")
+
+	// the remote rejected the credentials, or none were given but the repo needs them
+	ErrAuthentication = errors.New("authentication failed")
+
+	// the repository does not exist, or is not visible with the given credentials
+	ErrRepositoryNotFound = errors.New("repository not found")
+
+	// a branch (or other reference) does not exist
+	ErrBranchNotFound = errors.New("branch not found")
+
+	// the remote refused the push, e.g. because it is not a fast forward or the branch is protected
+	ErrPushRejected = errors.New("push rejected")
+
+	// the parameters did not pass the validation of the generator spec, see the response for details
+	ErrInvalidParameters = errors.New("invalid parameters")
+
+	// a template could not be rendered, see the response for details
+	ErrRenderFailed = errors.New("rendering failed")
+
+	// StageGeneratedOnlyStrict found changes to files that were not generated, or UpdatePristineBranch found
+	// uncommitted files it would delete
+	ErrUnexpectedChanges = errors.New("unexpected changes")
+
+	// a hook vetoed the step, the error of the hook is wrapped as well
+	ErrHookVeto = errors.New("vetoed by hook")
+
+	// the host key of an ssh server did not pass SetHostKeyVerification
+	ErrHostKeyRejected = errors.New("host key rejected")
+)
+
+// The error returned by the steps of a session
+//
+// Use errors.As to get at the phase and repository, and errors.Is with the Err... kinds
+// or the errors of go-git to find out what went wrong.
+type Error struct {
+	// the phase the error happened in, e.g. PhaseCloneTarget
+	Phase string
+
+	// url of the repository involved, without credentials, or empty if none was
+	Repo string
+
+	// one of the Err... kinds, or nil if the error does not fit any of them
+	Kind error
+
+	// the underlying error
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	if e.Kind != nil {
+		return e.Kind.Error()
+	}
+	return "unknown error in phase " + e.Phase
+}
+
+func (e *Error) Unwrap() []error {
+	var wrapped []error
+	if e.Kind != nil {
+		wrapped = append(wrapped, e.Kind)
+	}
+	if e.Err != nil {
+		wrapped = append(wrapped, e.Err)
+	}
+	return wrapped
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+)
+
+// Plugs behavior into the steps of a session, see AddHooks
+//
+// Every method may veto the step it belongs to by returning an error, which then fails the step with
+// that error wrapped. Embed NoHooks to only implement the methods you need.
+type Hooks interface {
+	// the source repo has been cloned, e.g. to check the generator version
+	AfterSourceClone(ctx context.Context, session HookSession) error
+
+	// the target repo has been cloned or prepared, and the target branch is checked out
+	AfterTargetCheckout(ctx context.Context, session HookSession) error
+
+	// Generate is about to render, e.g. to validate the render spec in session.TargetPath
+	BeforeRender(ctx context.Context, session HookSession) error
+
+	// Generate has rendered (and merged, pruned and restored user regions), e.g. to add extra files
+	// such as CODEOWNERS to session.TargetPath. The result may be modified.
+	AfterRender(ctx context.Context, session HookSession, result *GenerateResult) error
+
+	// CommitAndPush is about to commit, e.g. to check the worktree of the target
+	BeforeCommit(ctx context.Context, session HookSession) error
+
+	// CommitAndPush has committed, and pushed if it had auth, e.g. for auditing. Failed pushes are in
+	// result.PushResults. An error fails CommitAndPush, but cannot undo the push.
+	AfterPush(ctx context.Context, session HookSession, result *CommitResult) error
+}
+
+// What hooks get to know about the session
+type HookSession struct {
+	// local paths of the clones, empty if not cloned yet
+	SourcePath string
+	TargetPath string
+
+	// the branch the target is generated into, empty until the target is checked out
+	TargetBranch string
+
+	// empty until WriteRenderSpecFile
+	Generator      string
+	RenderSpecFile string
+}
+
+// Implements Hooks with methods that do nothing, for embedding
+type NoHooks struct{}
+
+func (NoHooks) AfterSourceClone(context.Context, HookSession) error { return nil }
+
+func (NoHooks) AfterTargetCheckout(context.Context, HookSession) error { return nil }
+
+func (NoHooks) BeforeRender(context.Context, HookSession) error { return nil }
+
+func (NoHooks) AfterRender(context.Context, HookSession, *GenerateResult) error { return nil }
+
+func (NoHooks) BeforeCommit(context.Context, HookSession) error { return nil }
+
+func (NoHooks) AfterPush(context.Context, HookSession, *CommitResult) error { return nil }
@@ -0,0 +1,22 @@
+package api
+
+// How the host keys of ssh servers are verified, see SetHostKeyVerification
+//
+// A host key is accepted if it matches a pinned fingerprint for the host, or a known_hosts entry. Hosts
+// that have pinned fingerprints only accept those. Hosts that are not known at all are rejected, unless
+// TrustOnFirstUse is set.
+type HostKeyVerification struct {
+	// known_hosts lines, in the format of ~/.ssh/known_hosts
+	KnownHosts []byte
+
+	// paths of known_hosts files
+	KnownHostsFiles []string
+
+	// SHA256 fingerprints of the accepted keys, as printed by 'ssh-keygen -l', keyed by host name
+	// pattern as in path.Match, e.g. {"github.com": {"SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU"}}
+	Fingerprints map[string][]string
+
+	// path of a known_hosts file that the keys of unknown hosts are added to on first contact, and
+	// that is checked like KnownHostsFiles afterwards. Created if it does not exist.
+	TrustOnFirstUse string
+}
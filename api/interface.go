@@ -20,6 +20,8 @@ type GitApi interface {
 	CreateTemporaryWorkdir(ctx context.Context, basePath string) error
 
 	// clone the source repo into the working directory and switch to the given branch (or tag, or revision)
+	//
+	// Tags and revisions are checked out detached, from a clone of the whole repository.
 	CloneSourceRepo(ctx context.Context, gitRepoUrl string, gitBranch string, auth transport.AuthMethod) (GitApiRepo, error)
 
 	// clone the target repo into the working directory and set up the given branch
@@ -50,6 +52,45 @@ type GitApi interface {
 		renderSpecFile string,
 		parameters map[string]interface{}) (*genlibapi.Response, error)
 
+	// decide how Generate brings the generated files into the target (default is UpdateOverwrite)
+	//
+	// With UpdateMerge, Generate renders the current generator version into a scratch directory, and the previous
+	// generator version (recorded in the manifest, see SetPruneOrphans) with the parameters committed in the target
+	// as the merge base. It then three-way merges the generated files with the files in the target, so changes made
+	// by humans are kept. Where they cannot be merged, the files get conflict markers, and GenerateResult.Conflicts
+	// lists them. If the previous generator version is unknown, any difference counts as a conflict.
+	//
+	// With UpdatePristineBranch, Generate commits the pure generator output to a separate branch in the target
+	// (see SetPristineBranch), creating it if necessary. It then merges that branch into the target branch,
+	// and the next CommitAndPush creates a merge commit and pushes both branches. Over time, git history tracks
+	// both template changes and customizations. go-git cannot merge, so the merge is done file by file with the
+	// same three-way text merge as UpdateMerge, using the merge base from git history. Unlike git, it does not
+	// detect renames, and treats binary files as text, so conflicting changes to them get conflict markers.
+	// Switching to the pristine branch empties the worktree, so Generate fails with ErrUnexpectedChanges if
+	// the target has uncommitted changes, untracked or ignored files other than the render spec file.
+	SetUpdateStrategy(strategy UpdateStrategy)
+
+	// configure the branch used by UpdatePristineBranch
+	SetPristineBranch(spec PristineBranchSpec)
+
+	// have Generate delete files that an earlier run generated, but which are no longer generated
+	//
+	// Generate keeps a manifest of the generated files next to the render spec file (for 'generated-main.yaml'
+	// it is called 'generated-main.manifest.yaml'). Files listed there that the generator no longer produces
+	// are deleted, unless they were changed since they were generated. Only files generated after pruning
+	// was enabled can be pruned.
+	SetPruneOrphans(enabled bool)
+
+	// have Generate keep the contents of user regions in generated files
+	//
+	// Templates mark user regions with a line containing 'BEGIN USER CODE: <name>' and a line containing
+	// 'END USER CODE', in whatever comment syntax fits the file. Before rendering, Generate captures the regions
+	// from the files in the target, and afterwards puts them back into the freshly rendered files. Regions whose
+	// name no longer appears in the rendered file are listed in GenerateResult.OrphanedRegions.
+	//
+	// Only applies to UpdateOverwrite, the other update strategies keep human edits anyway.
+	SetPreserveUserRegions(enabled bool)
+
 	// generate files using the render spec file written by WriteRenderSpecFile
 	//
 	// Response is filled even in case of an error and will contain more details of what caused the error
@@ -57,9 +98,110 @@ type GitApi interface {
 	// that were rendered.
 	Generate(ctx context.Context) (*genlibapi.Response, error)
 
-	// commit the changes in the target and push them (if an auth method is supplied)
+	// like Generate, but also reports what happened beyond rendering
+	//
+	// GenerateResult is filled even in case of an error and will contain more details of what caused the error
+	// and what output files were affected. After a successful run, GenerateResult also contains the list of files
+	// that were rendered, and the list of files that were pruned.
+	GenerateWithResult(ctx context.Context) (*GenerateResult, error)
+
+	// compare the committed files in the target with what the generator produces from them
+	//
+	// DetectDrift renders every render spec committed in the target into a scratch copy of the target,
+	// so the worktree of the target is left alone, and it does not need WriteRenderSpecFile. A yaml file
+	// counts as a render spec if it names one of the generators in the source.
+	//
+	// If SetPreserveUserRegions is enabled, the contents of user regions are not considered drift.
+	DetectDrift(ctx context.Context) (*DriftResult, error)
+
+	// list the uncommitted changes in the target with unified diffs, for example to review what Generate did
+	// before calling CommitAndPush
+	DiffTarget(ctx context.Context) ([]FileDiff, error)
+
+	// add a further remote to push the generation commit to, for example a mirror of the target repo
+	//
+	// Must be called after the target repo was cloned or prepared. The current target branch is pushed
+	// to every remote added here, each with its own auth method, even if CommitAndPush gets no auth for
+	// the target repo itself.
+	AddPushRemote(ctx context.Context, name string, gitRepoUrl string, auth transport.AuthMethod) error
+
+	// decide whether CommitAndPush fails if any push fails (PushToAll, the default), or only if every push fails (PushToAny)
+	SetPushPolicy(policy PushPolicy)
+
+	// decide which changes in the target CommitAndPush adds to the commit, see StagingMode (default is StageAll)
+	SetStagingMode(mode StagingMode)
+
+	// have CommitAndPush tag the commit it creates, and push the tag alongside the branch
+	//
+	// Set to nil to not create a tag (the default). It is an error during CommitAndPush if the tag
+	// already exists and points to a different commit, unless tag.Overwrite is set.
+	SetCommitTag(tag *TagSpec)
+
+	// have the instance report progress to sink, or stop reporting if sink is nil
+	//
+	// Cloning and pushing pass on what the remote reports (git sideband progress), parsed into object counts
+	// and percentages, and Generate reports how many files it rendered. Call this before CloneSourceRepo to
+	// see the progress of the clones.
+	SetProgressSink(sink ProgressSink)
+
+	// have the instance ask resolver for credentials whenever a method that needs them gets a nil auth method
+	//
+	// With a resolver, CommitAndPush with a nil auth method pushes if the resolver has write credentials
+	// for the target repo. Call this before CloneSourceRepo.
+	SetCredentialResolver(resolver CredentialResolver)
+
+	// have clones and pushes retried with backoff when they fail for transient reasons, see RetryPolicy
+	//
+	// Call this before CloneSourceRepo. Half-finished clones are deleted before the next attempt.
+	SetRetryPolicy(policy RetryPolicy)
+
+	// have clones and pushes over http(s) use the CA bundles, client certificates and proxies in hosts
+	//
+	// The first entry whose Host matches the host of a repository applies, hosts that match none use the
+	// defaults of net/http. Returns an error if an entry is invalid, e.g. a certificate cannot be parsed.
+	// Call this before CloneSourceRepo.
+	//
+	// This has a process-wide side effect: go-git allows one client per protocol, so the first call replaces
+	// the go-git clients for http and https by one that wraps them. Requests without transport settings
+	// still go through the wrapped client. Make the first call before other goroutines clone or push, and
+	// do not install clients with client.InstallProtocol afterwards, which would disable the settings.
+	SetTransport(hosts []HostTransport) error
+
+	// have ssh auth methods verify the host keys of servers as described by verification, instead of with
+	// ~/.ssh/known_hosts
+	//
+	// Applies to auth methods passed in as well as those of the credential resolver. Set to nil to go back
+	// to the default of go-git. Returns an error if a known_hosts file cannot be read. Call this before
+	// CloneSourceRepo.
+	SetHostKeyVerification(verification *HostKeyVerification) error
+
+	// have the urls of source, target and push remotes rewritten by rules before they are used, see UrlRewriting
+	//
+	// Credential resolvers, errors and push results see the rewritten urls, the push results and the manifest
+	// also the original ones. Set to nil to use urls as given. Call this before CloneSourceRepo.
+	SetUrlRewriting(rewriting *UrlRewriting) error
+
+	// the url as the rules of SetUrlRewriting rewrite it
+	RewriteUrl(url string) string
+
+	// register hooks that run at fixed points of the session, and may veto the step they run in
+	//
+	// Hooks run in the order they were added, and the first error stops the rest. See Hooks for when each
+	// method is called.
+	AddHooks(hooks Hooks)
+
+	// commit the changes in the target and push them (if an auth method is supplied, or the credential
+	// resolver has one)
+	//
+	// Use CommitAndPushWithResult to find out what was committed, and how pushing to each remote went.
 	CommitAndPush(ctx context.Context, name string, email string, message string, auth transport.AuthMethod) error
 
+	// like CommitAndPush, but also reports the outcome
+	//
+	// CommitResult is filled even in case of an error and will report success or failure for every remote
+	// a push was attempted to.
+	CommitAndPushWithResult(ctx context.Context, name string, email string, message string, auth transport.AuthMethod) (*CommitResult, error)
+
 	// delete the temporary working directory, including the source and target clones underneath it
 	//
 	// the base path given to CreateTemporaryWorkdir is left untouched so it can be re-used for the next
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"time"
+)
+
+// Describes a complete generator session for Run
+//
+// Only Source.Url, Target.Url, Target.Branch and Generator are required.
+type Job struct {
+	// optional name to tell jobs apart in results and logs
+	Name string
+
+	// base path for the temporary working directory, defaults to the system temp directory
+	WorkdirBase string
+
+	Source SourceSpec
+	Target TargetSpec
+
+	// name of the generator in the source repo, e.g. 'main' for 'generator-main.yaml'
+	Generator string
+
+	// render spec file in the target, defaults to 'generated-<Generator>.yaml'
+	RenderSpecFile string
+
+	// values for the variables of the generator, unset variables get their defaults
+	Parameters map[string]interface{}
+
+	// see SetUpdateStrategy, SetPristineBranch, SetPruneOrphans and SetPreserveUserRegions
+	UpdateStrategy      UpdateStrategy
+	PristineBranch      PristineBranchSpec
+	PruneOrphans        bool
+	PreserveUserRegions bool
+
+	// nil to stop after Generate, leaving the changes uncommitted
+	Commit *CommitSpec
+
+	// nil to commit without pushing, ignored unless Commit is set
+	Push *PushSpec
+
+	// optional, receives the start and end of every phase, as well as the progress within them,
+	// see SetProgressSink
+	Progress ProgressSink
+
+	// optional, see AddHooks
+	Hooks []Hooks
+
+	// optional, asked for credentials wherever the specs above have no auth method, see SetCredentialResolver.
+	// Without Push, it is only asked for read access.
+	Credentials CredentialResolver
+
+	// optional, see SetRetryPolicy
+	Retry RetryPolicy
+
+	// optional, see SetTransport
+	Transport []HostTransport
+
+	// optional, see SetHostKeyVerification
+	HostKeys *HostKeyVerification
+
+	// optional, see SetUrlRewriting. Source.Url, Target.Url and the mirror urls may then be aliases.
+	UrlRewriting *UrlRewriting
+
+	// optional limits for how long phases may take, keyed by the Phase... constants. A phase that
+	// takes longer is interrupted, and fails with an error that wraps context.DeadlineExceeded.
+	// Cleanup always runs, even after a timeout or a cancel of the context given to Run.
+	Timeouts map[string]time.Duration
+}
+
+type SourceSpec struct {
+	Url string
+
+	// branch, tag or revision (a commit hash, which may be abbreviated) of the generator, defaults to 'main'
+	//
+	// Anything but a branch is checked out from a clone of the whole repository.
+	Branch string
+
+	Auth transport.AuthMethod
+}
+
+type TargetSpec struct {
+	Url string
+
+	// branch to commit to
+	Branch string
+
+	// branch, tag or revision to create Branch from if it does not exist yet, defaults to Branch
+	BaseBranch string
+
+	// used for cloning, and for pushing unless Push.Auth is set
+	Auth transport.AuthMethod
+}
+
+type CommitSpec struct {
+	// identity for the generation commit, defaults to 'go-generator-git'
+	AuthorName  string
+	AuthorEmail string
+
+	// defaults to 'regenerate with generator <Generator>'
+	Message string
+
+	// see SetStagingMode and SetCommitTag
+	StagingMode StagingMode
+	Tag         *TagSpec
+}
+
+type PushSpec struct {
+	// auth for pushing to the target repo, defaults to Target.Auth. The target repo is only pushed to
+	// if there is an auth method.
+	Auth transport.AuthMethod
+
+	// further remotes to push to, see AddPushRemote
+	Mirrors []RemoteSpec
+
+	// see SetPushPolicy
+	Policy PushPolicy
+}
+
+type RemoteSpec struct {
+	Name string
+	Url  string
+	Auth transport.AuthMethod
+}
+
+// Settings for RunBatch
+type BatchOptions struct {
+	// how many jobs run at the same time, defaults to 1
+	Parallelism int
+
+	// do not start any further jobs once a job has failed
+	StopOnError bool
+}
+
+// Names of the phases Run goes through, in order, as found in PhaseResult.Name
+const (
+	PhaseCreateWorkdir   = "create-workdir"
+	PhaseCloneSource     = "clone-source"
+	PhaseCloneTarget     = "clone-target"
+	PhaseWriteRenderSpec = "write-render-spec"
+	PhaseGenerate        = "generate"
+	PhaseCommitAndPush   = "commit-and-push"
+	PhaseCleanup         = "cleanup"
+)
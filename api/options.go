@@ -0,0 +1,104 @@
+package api
+
+import (
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"time"
+)
+
+// Decides how Generate brings generated files into the target.
+type UpdateStrategy int
+
+const (
+	// overwrite files in the target with the generated files (the default)
+	UpdateOverwrite UpdateStrategy = iota
+
+	// three-way merge the generated files with the files in the target, using the output of the previous
+	// generator version as the merge base, see SetUpdateStrategy
+	UpdateMerge
+
+	// commit the pure generator output to a dedicated branch first, then merge that branch into the target
+	// branch, see SetPristineBranch
+	UpdatePristineBranch
+)
+
+// Settings for UpdatePristineBranch
+type PristineBranchSpec struct {
+	// Name of the branch that only ever contains generator output.
+	// Defaults to 'generator/pristine/<generatorName>'.
+	Branch string
+
+	// Author of the commits on the pristine branch. Defaults to 'go-generator-git'.
+	AuthorName  string
+	AuthorEmail string
+}
+
+// Describes a tag to create on the generation commit
+type TagSpec struct {
+	// Name of the tag. This is a golang template, so you can use {{ .Generator }}, {{ .SourceRevision }} and
+	// {{ .SourceRevisionShort }} to build names like 'generated/{{ .Generator }}/{{ .SourceRevisionShort }}'.
+	Name string
+
+	// If set, an annotated tag is created with this message. Otherwise, the tag is a lightweight tag.
+	Message string
+
+	// Optional key to sign the tag with. Only annotated tags can be signed. The private key must already be decrypted.
+	SignKey *openpgp.Entity
+
+	// Replace an existing tag of the same name that points to a different commit.
+	Overwrite bool
+}
+
+// Decides when pushing to several remotes counts as a success.
+type PushPolicy int
+
+const (
+	// every push must succeed (the default)
+	PushToAll PushPolicy = iota
+
+	// at least one push must succeed
+	PushToAny
+)
+
+// Decides which changes in the target CommitAndPush adds to the commit.
+type StagingMode int
+
+const (
+	// add every change in the target directory (the default)
+	StageAll StagingMode = iota
+
+	// add only the render spec file and the files rendered by the last call to Generate,
+	// report any other changed paths in CommitResult.IgnoredPaths
+	StageGeneratedOnly
+
+	// like StageGeneratedOnly, but CommitAndPush fails if there are any other changed paths
+	StageGeneratedOnlyStrict
+)
+
+// Decides how often clones and pushes are tried when they fail for reasons that may go away,
+// such as dropped connections or a 503 from the git server. Errors like failed authentication,
+// a repository that does not exist, or a rejected push are never retried.
+//
+// The zero value tries every operation once.
+type RetryPolicy struct {
+	// how often an operation is tried in total, values below 2 disable retrying
+	MaxAttempts int
+
+	// wait before the first retry, defaults to 1s. Every further wait is Multiplier times longer,
+	// up to MaxBackoff.
+	InitialBackoff time.Duration
+
+	// longest wait between attempts, defaults to 30s
+	MaxBackoff time.Duration
+
+	// growth of the wait, defaults to 2
+	Multiplier float64
+
+	// randomly varies every wait by up to this fraction (e.g. 0.2 for ±20%), so that many sessions
+	// failing at once do not retry in lockstep
+	Jitter float64
+}
+
+// A retry policy suitable for most git servers: 4 attempts, waiting about 1s, 2s and 4s in between.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 30 * time.Second, Multiplier: 2, Jitter: 0.2}
+}
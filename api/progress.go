@@ -0,0 +1,64 @@
+package api
+
+import (
+	"time"
+)
+
+// Kinds of ProgressEvent
+type ProgressKind string
+
+const (
+	// a phase of Run started, see Phase*
+	ProgressPhaseStarted ProgressKind = "phase-started"
+
+	// a phase of Run finished, successfully or not
+	ProgressPhaseFinished ProgressKind = "phase-finished"
+
+	// progress reported by the remote while cloning, fetching or pushing
+	ProgressTransfer ProgressKind = "transfer"
+
+	// rendering started or finished
+	ProgressRender ProgressKind = "render"
+)
+
+// Something that happened during a session, see SetProgressSink
+type ProgressEvent struct {
+	Kind ProgressKind
+	Time time.Time
+
+	// the phase, for ProgressPhaseStarted and ProgressPhaseFinished
+	Phase string
+
+	// how long the phase took, and why it failed, for ProgressPhaseFinished
+	Duration time.Duration
+	Err      error
+
+	// what is going on, e.g. 'clone source', 'push origin' or 'render', for ProgressTransfer and ProgressRender
+	Operation string
+
+	// the step reported by the remote, e.g. 'Counting objects', for ProgressTransfer
+	Stage string
+
+	// objects or files done, and in total. Total is 0 if unknown.
+	Current int
+	Total   int
+
+	// between 0 and 100, or -1 if unknown, for ProgressTransfer and ProgressRender
+	Percent int
+
+	// a line from the remote that is not a count, e.g. 'Total 5 (delta 0), reused 0 (delta 0)', for ProgressTransfer
+	Message string
+}
+
+// Receives progress events. It is called on the goroutine doing the work, so it should return quickly.
+type ProgressSink func(event ProgressEvent)
+
+// ProgressChannel returns a ProgressSink that sends the events to ch.
+//
+// Sending blocks until the event is received, so use a buffered channel, and keep reading until the
+// session has ended.
+func ProgressChannel(ch chan<- ProgressEvent) ProgressSink {
+	return func(event ProgressEvent) {
+		ch <- event
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"time"
+)
+
+// Information about the results of Generate
+//
+// Embeds the Response from go-generator-lib, so Success, RenderedFiles and Errors are available directly.
+type GenerateResult struct {
+	*genlibapi.Response
+
+	// files generated by the previous run that are no longer generated, and were deleted (see SetPruneOrphans)
+	PrunedFiles []string
+
+	// files generated by the previous run that are no longer generated, but were kept because they were changed since
+	ModifiedOrphans []string
+
+	// files where generated changes and changes in the target could not be merged (see UpdateMerge)
+	Conflicts []MergeConflict
+
+	// captured user regions that could not be put back because the rendered file no longer has them
+	// (see SetPreserveUserRegions)
+	OrphanedRegions []OrphanedRegion
+}
+
+// A user region whose content was dropped from a file
+type OrphanedRegion struct {
+	// path of the file relative to the target directory
+	Path string
+
+	// name of the region, as given in the begin marker
+	Name string
+
+	// the content of the region before Generate ran, so it can be restored manually
+	Content string
+}
+
+// A file that now contains conflict markers
+type MergeConflict struct {
+	// path of the file relative to the target directory
+	Path string
+
+	// number of regions in the file that are enclosed in conflict markers
+	Regions int
+}
+
+// Information about the results of DetectDrift
+//
+// All paths are relative to the target directory, with forward slashes, sorted.
+type DriftResult struct {
+	// the render spec files found in the target, which were rendered
+	RenderSpecFiles []string
+
+	// generated files that were changed since they were generated
+	Modified []string
+
+	// files that an earlier run generated (according to the manifest), but which were deleted
+	Missing []string
+
+	// files the generator would add to the target
+	Added []string
+
+	// generated files that are exactly as the generator produces them
+	Unchanged []string
+
+	// errors from rendering, if it failed
+	Errors []error
+}
+
+// HasDrift is true if the target differs from the generator output.
+func (d *DriftResult) HasDrift() bool {
+	return len(d.Modified)+len(d.Missing)+len(d.Added) > 0
+}
+
+// Score is the fraction of generated files that differ from the target, between 0 (no drift) and 1.
+func (d *DriftResult) Score() float64 {
+	drifted := len(d.Modified) + len(d.Missing) + len(d.Added)
+	total := drifted + len(d.Unchanged)
+	if total == 0 {
+		return 0
+	}
+	return float64(drifted) / float64(total)
+}
+
+// An uncommitted change to a file in the target (see DiffTarget)
+type FileDiff struct {
+	// path of the file relative to the target directory, with forward slashes
+	Path string
+
+	Change ChangeType
+
+	// unified diff of the change, empty for binary files
+	Diff string
+
+	Binary bool
+}
+
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeModified ChangeType = "modified"
+	ChangeDeleted  ChangeType = "deleted"
+)
+
+// Information about the results of CommitAndPush
+type CommitResult struct {
+	// hash of the commit that was created in the target repo, empty if committing failed
+	CommitHash string
+
+	// name of the tag that was created on the commit, if any
+	Tag string
+
+	// files that were deleted from the target because they are no longer generated (see SetPruneOrphans)
+	PrunedFiles []string
+
+	// changed paths in the target that were left out of the commit because of the staging mode
+	IgnoredPaths []string
+
+	// one entry per remote a push was attempted to, in the order they were pushed
+	PushResults []PushResult
+}
+
+// Information about the push to a single remote
+type PushResult struct {
+	RemoteName string
+	RemoteUrl  string
+
+	// RemoteUrl as it was given, before SetUrlRewriting
+	OriginalUrl string
+
+	Success bool
+	Err     error
+}
+
+// The outcome of a whole generator session, as far as it got
+//
+// Fields for steps that were not run are nil. Use the report package to write it in machine-readable formats.
+type SessionResult struct {
+	// name of the job, if it was run from a Job that has one
+	JobName string
+
+	// name of the generator that was used
+	Generator string
+
+	// render spec file in the target, relative to the target directory
+	RenderSpecFile string
+
+	// the urls of the repositories, before and after SetUrlRewriting
+	Source RepositoryUrl
+	Target RepositoryUrl
+
+	// the steps that were run, in order
+	Phases []PhaseResult
+
+	// result of WriteRenderSpecFile, its Errors contain parameter validation errors
+	RenderSpec *genlibapi.Response
+
+	Generate *GenerateResult
+
+	// the changes Generate made to the target, see DiffTarget
+	Changes []FileDiff
+
+	Drift  *DriftResult
+	Commit *CommitResult
+}
+
+// Success is true if all phases succeeded.
+func (s *SessionResult) Success() bool {
+	for _, p := range s.Phases {
+		if p.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// A single step of a session
+type PhaseResult struct {
+	// one of the Phase... constants, e.g. PhaseGenerate
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+
+	// nil if the phase succeeded
+	Err error
+}
@@ -0,0 +1,22 @@
+package api
+
+// Settings for the http(s) connections to the hosts that match Host, see SetTransport
+type HostTransport struct {
+	// host name pattern as in path.Match, without the port, e.g. 'git.example.com', '*.example.com', or '*' for all hosts
+	Host string
+
+	// PEM encoded certificates of CAs to trust in addition to those of the system
+	CABundle []byte
+
+	// PEM encoded client certificate and its key, for servers that require mutual TLS
+	ClientCertificate []byte
+	ClientKey         []byte
+
+	// url of the proxy to connect through, e.g. 'http://proxy.example.com:3128'. If empty, the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables decide.
+	Proxy string
+
+	// accept any server certificate. This makes the connection open to man-in-the-middle attacks,
+	// so only use it for testing.
+	InsecureSkipVerify bool
+}
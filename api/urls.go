@@ -0,0 +1,23 @@
+package api
+
+// Rules for rewriting repository urls before they are cloned or pushed to, see SetUrlRewriting
+//
+// Aliases are resolved first, then InsteadOf applies to the result, so an alias may expand to a public
+// url that InsteadOf then points to a mirror.
+type UrlRewriting struct {
+	// short names for repositories, e.g. {"templates/go-rest": "https://github.com/some-org/tpl-go-rest"}.
+	// Only urls that are exactly an alias are replaced.
+	Aliases map[string]string
+
+	// prefixes to replace, mapped to their replacement, like the url.<replacement>.insteadOf settings of git,
+	// e.g. {"https://github.com/": "ssh://git@mirror.corp.example.com/github/"}. The longest matching prefix wins.
+	InsteadOf map[string]string
+}
+
+// A repository url as it was given, and as it was used after SetUrlRewriting
+type RepositoryUrl struct {
+	Original string
+
+	// the same as Original if no rule applied
+	Rewritten string
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+)
+
+// authOptions come from flags, falling back to environment variables, so secrets need not appear on the command line.
+type authOptions struct {
+	username       string
+	password       string
+	token          string
+	sshKey         string
+	sshKeyPassword string
+	sshUser        string
+	knownHosts     string
+	tofuStore      string
+}
+
+const (
+	envUsername       = "GENERATOR_GIT_USERNAME"
+	envPassword       = "GENERATOR_GIT_PASSWORD"
+	envToken          = "GENERATOR_GIT_TOKEN"
+	envSshKey         = "GENERATOR_GIT_SSH_KEY"
+	envSshKeyPassword = "GENERATOR_GIT_SSH_KEY_PASSWORD"
+	envSshUser        = "GENERATOR_GIT_SSH_USER"
+
+	defaultSshUser   = "git"
+	defaultTokenUser = "git"
+)
+
+func (a *authOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.username, "username", "", "username for https (env "+envUsername+")")
+	fs.StringVar(&a.password, "password", "", "password for https (env "+envPassword+")")
+	fs.StringVar(&a.token, "token", "", "access token for https, used as password (env "+envToken+")")
+	fs.StringVar(&a.sshKey, "ssh-key", "", "private key file for ssh urls (env "+envSshKey+")")
+	fs.StringVar(&a.sshKeyPassword, "ssh-key-password", "", "password of the private key (env "+envSshKeyPassword+")")
+	fs.StringVar(&a.sshUser, "ssh-user", "", "user for ssh urls, defaults to '"+defaultSshUser+"' (env "+envSshUser+")")
+	fs.StringVar(&a.knownHosts, "known-hosts", "", "known_hosts file to verify the host keys of ssh servers with, instead of ~/.ssh/known_hosts")
+	fs.StringVar(&a.tofuStore, "known-hosts-tofu", "", "known_hosts file that the keys of unknown ssh servers are added to on first contact")
+}
+
+// hostKeys returns the host key verification from -known-hosts and -known-hosts-tofu, or nil for the default.
+func (a *authOptions) hostKeys() *api.HostKeyVerification {
+	if a.knownHosts == "" && a.tofuStore == "" {
+		return nil
+	}
+	verification := &api.HostKeyVerification{TrustOnFirstUse: a.tofuStore}
+	if a.knownHosts != "" {
+		verification.KnownHostsFiles = []string{a.knownHosts}
+	}
+	return verification
+}
+
+func (a *authOptions) applyEnv(getenv func(string) string) {
+	fallback := func(value *string, env string) {
+		if *value == "" {
+			*value = getenv(env)
+		}
+	}
+	fallback(&a.username, envUsername)
+	fallback(&a.password, envPassword)
+	fallback(&a.token, envToken)
+	fallback(&a.sshKey, envSshKey)
+	fallback(&a.sshKeyPassword, envSshKeyPassword)
+	fallback(&a.sshUser, envSshUser)
+	if a.sshUser == "" {
+		a.sshUser = defaultSshUser
+	}
+}
+
+// forUrl returns the auth method fitting the protocol of the url, or nil if none is configured.
+func (a *authOptions) forUrl(url string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	switch endpoint.Protocol {
+	case "ssh":
+		if a.sshKey != "" {
+			return gitssh.NewPublicKeysFromFile(a.sshUser, a.sshKey, a.sshKeyPassword)
+		}
+		return nil, nil
+	default:
+		if a.token != "" {
+			username := a.username
+			if username == "" {
+				username = defaultTokenUser
+			}
+			return &githttp.BasicAuth{Username: username, Password: a.token}, nil
+		}
+		if a.username != "" {
+			return &githttp.BasicAuth{Username: a.username, Password: a.password}, nil
+		}
+		return nil, nil
+	}
+}
+
+// forPush is like forUrl, but falls back to the ssh agent for ssh urls, because pushing always needs some auth.
+func (a *authOptions) forPush(url string) (transport.AuthMethod, error) {
+	auth, err := a.forUrl(url)
+	if err != nil || auth != nil {
+		return auth, err
+	}
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Protocol == "ssh" {
+		return gitssh.NewSSHAgentAuth(a.sshUser)
+	}
+	return nil, usageErrorf("pushing to %s needs credentials, see -username, -token and -ssh-key", url)
+}
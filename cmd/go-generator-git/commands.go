@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	generatorlib "github.com/StephanHCB/go-generator-lib"
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/pipeline"
+	"github.com/mplushnikov/go-generator-git/v2/jobfile"
+	"github.com/mplushnikov/go-generator-git/v2/prompt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func (c *cli) listGenerators(ctx context.Context, args []string) error {
+	fs, o := newFlagSet(c, "list-generators")
+	o.registerSource(fs)
+	if err := o.parse(c, fs, args); err != nil {
+		return err
+	}
+
+	return c.withSource(ctx, o, func(sourcePath string) error {
+		names, err := generatorlib.FindGeneratorNames(ctx, sourcePath)
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+		if o.output == "json" {
+			return c.printJSON(nonNil(names))
+		}
+		for _, name := range names {
+			fmt.Fprintln(c.out, name)
+		}
+		return nil
+	})
+}
+
+func (c *cli) showSpec(ctx context.Context, args []string) error {
+	fs, o := newFlagSet(c, "show-spec")
+	o.registerSource(fs)
+	o.registerGenerator(fs)
+	if err := o.parse(c, fs, args); err != nil {
+		return err
+	}
+
+	return c.withSource(ctx, o, func(sourcePath string) error {
+		spec, err := generatorlib.ObtainGeneratorSpec(ctx, sourcePath, o.generator)
+		if err != nil {
+			return err
+		}
+		return c.printSpec(o, spec)
+	})
+}
+
+func (c *cli) validate(ctx context.Context, args []string) error {
+	fs, o := newFlagSet(c, "validate")
+	o.registerSource(fs)
+	o.registerGenerator(fs)
+	o.registerParameters(fs)
+	if err := o.parse(c, fs, args); err != nil {
+		return err
+	}
+
+	return c.withSource(ctx, o, func(sourcePath string) error {
+		parameters, err := c.collectParameters(ctx, o, sourcePath, "", nil)
+		if err != nil {
+			return err
+		}
+		scratchDir, err := ioutil.TempDir(o.workdir, "validate-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(scratchDir)
+
+		response := generatorlib.WriteRenderSpecWithValues(ctx, &genlibapi.Request{
+			SourceBaseDir:  sourcePath,
+			TargetBaseDir:  scratchDir,
+			RenderSpecFile: o.renderSpecFile,
+		}, o.generator, parameters)
+		if err := c.printValidation(o, response); err != nil {
+			return err
+		}
+		if !response.Success {
+			return errFailed
+		}
+		return nil
+	})
+}
+
+func (c *cli) render(ctx context.Context, args []string) error {
+	fs, o := newFlagSet(c, "render")
+	o.registerSource(fs)
+	o.registerGenerator(fs)
+	o.registerParameters(fs)
+	o.registerTarget(fs)
+	fs.BoolVar(&o.dryRun, "dry-run", false, "only report what would change, and delete the working directory")
+	if err := o.parse(c, fs, args); err != nil {
+		return err
+	}
+	o.keep = !o.dryRun
+	return c.generate(ctx, o, modeRender, false)
+}
+
+func (c *cli) commit(ctx context.Context, args []string) error {
+	fs, o := newFlagSet(c, "commit")
+	o.registerSource(fs)
+	o.registerGenerator(fs)
+	o.registerParameters(fs)
+	o.registerTarget(fs)
+	o.registerCommit(fs)
+	if err := o.parse(c, fs, args); err != nil {
+		return err
+	}
+	o.keep = true
+	return c.generate(ctx, o, modeCommit, false)
+}
+
+func (c *cli) push(ctx context.Context, args []string) error {
+	fs, o := newFlagSet(c, "push")
+	o.registerSource(fs)
+	o.registerGenerator(fs)
+	o.registerParameters(fs)
+	o.registerTarget(fs)
+	o.registerCommit(fs)
+	fs.BoolVar(&o.keep, "keep", false, "keep the working directory")
+	if err := o.parse(c, fs, args); err != nil {
+		return err
+	}
+	return c.generate(ctx, o, modePush, false)
+}
+
+func (c *cli) regenerate(ctx context.Context, args []string) error {
+	fs, o := newFlagSet(c, "regenerate")
+	o.registerSource(fs)
+	o.registerGenerator(fs)
+	o.registerParameters(fs)
+	o.registerTarget(fs)
+	o.registerCommit(fs)
+	fs.BoolVar(&o.keep, "keep", false, "keep the working directory")
+	fs.StringVar(&o.jobsFile, "jobs", "", "job file to run instead of a single regeneration")
+	fs.IntVar(&o.parallelism, "parallel", 1, "how many jobs of the job file to run at the same time")
+	if err := o.parse(c, fs, args); err != nil {
+		return err
+	}
+	if o.jobsFile != "" {
+		return c.runJobFile(ctx, o)
+	}
+	if o.sourceUrl == "" || o.targetUrl == "" {
+		return usageErrorf("-source and -target are required unless -jobs is given")
+	}
+	return c.generate(ctx, o, modePush, true)
+}
+
+// withSource clones the source repo into a temporary working directory that is deleted afterwards.
+func (c *cli) withSource(ctx context.Context, o *options, step func(sourcePath string) error) error {
+	gen := generatorgit.ThreadsafeInstance()
+	hosts, err := o.transport.hosts()
+	if err != nil {
+		return err
+	}
+	if err := gen.SetTransport(hosts); err != nil {
+		return err
+	}
+	if err := gen.SetHostKeyVerification(o.auth.hostKeys()); err != nil {
+		return err
+	}
+	if err := gen.SetUrlRewriting(o.urls.rules()); err != nil {
+		return err
+	}
+	if err := gen.CreateTemporaryWorkdir(ctx, o.workdir); err != nil {
+		return err
+	}
+	defer gen.Cleanup(ctx)
+
+	auth, err := o.auth.forUrl(gen.RewriteUrl(o.sourceUrl))
+	if err != nil {
+		return err
+	}
+	repo, err := gen.CloneSourceRepo(ctx, o.sourceUrl, o.sourceRef, auth)
+	if err != nil {
+		return err
+	}
+	return step(repo.GetLocalPath())
+}
+
+// collectParameters merges base, parameter file and flags.
+//
+// With -interactive, it prompts for every parameter not given in the parameter file or flags instead, offering
+// base, or the values in the render spec of the target if there is one, as defaults. targetPath may be "".
+func (c *cli) collectParameters(ctx context.Context, o *options, sourcePath string, targetPath string, base map[string]interface{}) (map[string]interface{}, error) {
+	if !o.interactive {
+		return o.parameters(base)
+	}
+
+	fixed, err := o.parameters(nil)
+	if err != nil {
+		return nil, err
+	}
+	prefilled := base
+	if prefilled == nil && targetPath != "" {
+		if committed, err := readRenderSpec(targetPath, o.renderSpecFile); err == nil && committed.GeneratorName == o.generator {
+			prefilled = committed.Parameters
+		}
+	}
+	spec, err := generatorlib.ObtainGeneratorSpec(ctx, sourcePath, o.generator)
+	if err != nil {
+		return nil, err
+	}
+	// prompts go to stderr, so stdout only has the result
+	return prompt.New(c.in, c.errOut).Parameters(spec, fixed, prefilled)
+}
+
+type mode int
+
+const (
+	modeRender mode = iota
+	modeCommit
+	modePush
+)
+
+// generate runs a session up to the given mode, and prints its result.
+//
+// With fromTarget, the parameters committed in the render spec of the target are the base for the parameters.
+func (c *cli) generate(ctx context.Context, o *options, m mode, fromTarget bool) error {
+	session := &api.SessionResult{Generator: o.generator, RenderSpecFile: o.renderSpecFile}
+	r := pipeline.NewRecorder(ctx, session)
+	gen := generatorgit.ThreadsafeInstance()
+	gen.SetRetryPolicy(o.retryPolicy())
+	hosts, err := o.transport.hosts()
+	if err != nil {
+		return err
+	}
+	if err := gen.SetTransport(hosts); err != nil {
+		return err
+	}
+	if err := gen.SetHostKeyVerification(o.auth.hostKeys()); err != nil {
+		return err
+	}
+	if err := gen.SetUrlRewriting(o.urls.rules()); err != nil {
+		return err
+	}
+
+	var targetPath string
+	if r.Phase(api.PhaseCreateWorkdir, func(ctx context.Context) error {
+		return gen.CreateTemporaryWorkdir(ctx, o.workdir)
+	}) {
+		targetPath = c.generateSteps(ctx, o, m, fromTarget, gen, r, session)
+		if !o.keep {
+			r.Phase(api.PhaseCleanup, func(ctx context.Context) error {
+				return gen.Cleanup(ctx)
+			})
+			targetPath = ""
+		}
+	}
+
+	if err := c.printSession(o, session); err != nil {
+		return err
+	}
+	if targetPath != "" {
+		fmt.Fprintf(c.errOut, "target clone kept in %s\n", targetPath)
+	}
+	return r.Err()
+}
+
+func (c *cli) generateSteps(ctx context.Context, o *options, m mode, fromTarget bool, gen api.GitApi, r *pipeline.Recorder, session *api.SessionResult) string {
+	var sourcePath, targetPath string
+	ok := r.Phase(api.PhaseCloneSource, func(ctx context.Context) error {
+		auth, err := o.auth.forUrl(gen.RewriteUrl(o.sourceUrl))
+		if err != nil {
+			return err
+		}
+		repo, err := gen.CloneSourceRepo(ctx, o.sourceUrl, o.sourceRef, auth)
+		if repo != nil {
+			sourcePath = repo.GetLocalPath()
+		}
+		return err
+	}) && r.Phase(api.PhaseCloneTarget, func(ctx context.Context) error {
+		auth, err := o.auth.forUrl(gen.RewriteUrl(o.targetUrl))
+		if err != nil {
+			return err
+		}
+		repo, err := gen.CloneTargetRepo(ctx, o.targetUrl, o.branch, o.baseBranch, auth)
+		if repo != nil {
+			targetPath = repo.GetLocalPath()
+		}
+		if err != nil {
+			return err
+		}
+		o.configure(gen)
+		return nil
+	}) && r.Phase(api.PhaseWriteRenderSpec, func(ctx context.Context) error {
+		var base map[string]interface{}
+		if fromTarget {
+			committed, err := readRenderSpec(targetPath, o.renderSpecFile)
+			if err != nil {
+				return err
+			}
+			if committed.GeneratorName != "" {
+				o.generator = committed.GeneratorName
+				session.Generator = committed.GeneratorName
+			}
+			base = committed.Parameters
+		}
+		parameters, err := c.collectParameters(ctx, o, sourcePath, targetPath, base)
+		if err != nil {
+			return err
+		}
+		session.RenderSpec, err = gen.WriteRenderSpecFile(ctx, o.generator, o.renderSpecFile, parameters)
+		return err
+	}) && r.Phase(api.PhaseGenerate, func(ctx context.Context) error {
+		var err error
+		session.Generate, err = gen.GenerateWithResult(ctx)
+		if err != nil {
+			return err
+		}
+		session.Changes, err = gen.DiffTarget(ctx)
+		return err
+	})
+	if !ok || m == modeRender {
+		return targetPath
+	}
+
+	r.Phase(api.PhaseCommitAndPush, func(ctx context.Context) error {
+		pushAuth, err := c.pushAuth(o, m, gen)
+		if err != nil {
+			return err
+		}
+		session.Commit, err = gen.CommitAndPushWithResult(ctx, o.authorName, o.authorEmail, o.message, pushAuth)
+		return err
+	})
+	return targetPath
+}
+
+// pushAuth is nil for modeCommit, so CommitAndPush does not push.
+func (c *cli) pushAuth(o *options, m mode, gen api.GitApi) (transport.AuthMethod, error) {
+	if m != modePush {
+		return nil, nil
+	}
+	return o.auth.forPush(gen.RewriteUrl(o.targetUrl))
+}
+
+func readRenderSpec(targetPath string, renderSpecFile string) (*genlibapi.RenderSpec, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(targetPath, renderSpecFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("the target has no render spec %s, use push for the first generation", renderSpecFile)
+		}
+		return nil, err
+	}
+	spec := &genlibapi.RenderSpec{}
+	if err := yaml.Unmarshal(contents, spec); err != nil {
+		return nil, fmt.Errorf("cannot read render spec %s: %w", renderSpecFile, err)
+	}
+	return spec, nil
+}
+
+func (c *cli) runJobFile(ctx context.Context, o *options) error {
+	f, err := jobfile.Load(o.jobsFile)
+	if err != nil {
+		return err
+	}
+	if f.Defaults.UrlRewriting == nil {
+		f.Defaults.UrlRewriting = o.urls.jobfileRules()
+	}
+	jobs, err := f.ToJobs(ctx, func(_ context.Context, url string) (transport.AuthMethod, error) {
+		return o.auth.forUrl(url)
+	})
+	if err != nil {
+		return err
+	}
+	hosts, err := o.transport.hosts()
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		jobs[i].WorkdirBase = o.workdir
+		if jobs[i].Transport == nil {
+			jobs[i].Transport = hosts
+		}
+		if jobs[i].HostKeys == nil {
+			jobs[i].HostKeys = o.auth.hostKeys()
+		}
+		if jobs[i].Retry.MaxAttempts == 0 {
+			jobs[i].Retry = o.retryPolicy()
+		}
+	}
+
+	results, batchErr := generatorgit.RunBatch(ctx, jobs, api.BatchOptions{Parallelism: o.parallelism})
+	if err := c.printBatch(o, jobs, results); err != nil {
+		return err
+	}
+	return batchErr
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	auloggingapi "github.com/StephanHCB/go-autumn-logging/api"
+	"io"
+	"strings"
+	"sync"
+)
+
+// the library logs through go-autumn-logging, which stays silent unless -v installs this simple logger
+
+func setupLogging(w io.Writer) {
+	aulogging.Logger = &streamLogger{w: w}
+}
+
+type streamLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *streamLogger) Ctx(_ context.Context) auloggingapi.ContextAwareLoggingImplementation {
+	return l
+}
+
+func (l *streamLogger) NoCtx() auloggingapi.ContextAwareLoggingImplementation {
+	return l
+}
+
+func (l *streamLogger) Trace() auloggingapi.LeveledLoggingImplementation { return l.level("TRACE") }
+func (l *streamLogger) Debug() auloggingapi.LeveledLoggingImplementation { return l.level("DEBUG") }
+func (l *streamLogger) Info() auloggingapi.LeveledLoggingImplementation  { return l.level("INFO") }
+func (l *streamLogger) Warn() auloggingapi.LeveledLoggingImplementation  { return l.level("WARN") }
+func (l *streamLogger) Error() auloggingapi.LeveledLoggingImplementation { return l.level("ERROR") }
+func (l *streamLogger) Fatal() auloggingapi.LeveledLoggingImplementation { return l.level("FATAL") }
+func (l *streamLogger) Panic() auloggingapi.LeveledLoggingImplementation { return l.level("PANIC") }
+
+func (l *streamLogger) level(level string) auloggingapi.LeveledLoggingImplementation {
+	return &logEntry{logger: l, level: level}
+}
+
+type logEntry struct {
+	logger *streamLogger
+	level  string
+	err    error
+	fields []string
+}
+
+func (e *logEntry) WithErr(err error) auloggingapi.LeveledLoggingImplementation {
+	e.err = err
+	return e
+}
+
+func (e *logEntry) With(key string, value string) auloggingapi.LeveledLoggingImplementation {
+	e.fields = append(e.fields, key+"="+value)
+	return e
+}
+
+func (e *logEntry) Print(v ...interface{}) {
+	e.emit(fmt.Sprint(v...))
+}
+
+func (e *logEntry) Printf(format string, v ...interface{}) {
+	e.emit(fmt.Sprintf(format, v...))
+}
+
+func (e *logEntry) emit(message string) {
+	line := fmt.Sprintf("%-5s %s", e.level, message)
+	if len(e.fields) > 0 {
+		line += " " + strings.Join(e.fields, " ")
+	}
+	if e.err != nil {
+		line += ": " + e.err.Error()
+	}
+	e.logger.mu.Lock()
+	defer e.logger.mu.Unlock()
+	fmt.Fprintln(e.logger.w, line)
+}
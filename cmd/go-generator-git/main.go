@@ -0,0 +1,115 @@
+// Command go-generator-git renders a generator from one git repository into another, and commits and pushes the result.
+//
+// Run 'go-generator-git help' for the list of commands, and 'go-generator-git <command> -h' for their flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// an interrupt stops the current step, the working directory is still cleaned up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv)
+	stop()
+	os.Exit(code)
+}
+
+// cli holds the streams and environment of an invocation, so commands can be tested without a terminal.
+type cli struct {
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+	getenv func(string) string
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(c *cli, ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"list-generators", "list the generators in a source repository", (*cli).listGenerators},
+	{"show-spec", "show the variables and templates of a generator", (*cli).showSpec},
+	{"validate", "check parameters against the generator spec", (*cli).validate},
+	{"render", "render into a clone of the target, and keep it for inspection (or just report with --dry-run)", (*cli).render},
+	{"commit", "render and commit in a clone of the target, and keep it for inspection", (*cli).commit},
+	{"push", "render, commit and push to the target", (*cli).push},
+	{"regenerate", "render, commit and push using the parameters committed in the target, or run a job file", (*cli).regenerate},
+}
+
+const (
+	exitOk    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// errFailed signals a failure that was already reported in the output.
+var errFailed = errors.New("failed")
+
+func run(ctx context.Context, args []string, in io.Reader, out io.Writer, errOut io.Writer, getenv func(string) string) int {
+	c := &cli{in: in, out: out, errOut: errOut, getenv: getenv}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		c.usage()
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOk
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			err := cmd.run(c, ctx, args[1:])
+			switch {
+			case err == nil:
+				return exitOk
+			case errors.Is(err, flag.ErrHelp):
+				return exitOk
+			case isUsageError(err):
+				fmt.Fprintf(errOut, "%s: %s\n", cmd.name, err.Error())
+				return exitUsage
+			case errors.Is(err, errFailed):
+				return exitError
+			default:
+				fmt.Fprintf(errOut, "%s: %s\n", cmd.name, err.Error())
+				return exitError
+			}
+		}
+	}
+	fmt.Fprintf(errOut, "unknown command '%s'\n\n", args[0])
+	c.usage()
+	return exitUsage
+}
+
+func (c *cli) usage() {
+	fmt.Fprintf(c.errOut, "usage: go-generator-git <command> [flags]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.errOut, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(c.errOut, "\nrun 'go-generator-git <command> -h' to see the flags of a command\n")
+}
+
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func isUsageError(err error) bool {
+	var u *usageError
+	return errors.As(err, &u)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const generatorSpec = `templates:
+  - source: src/main.go.tmpl
+    target: cmd/{{ .serviceName }}/main.go
+variables:
+  serviceName:
+    description: The name of the service
+    pattern: '^[a-z-]+$'
+    default: demo-service
+  owner:
+    description: The owning team
+`
+
+func createSource(t *testing.T) string {
+	return testrepo.Create(t, map[string]string{
+		"generator-main.yaml": generatorSpec,
+		"src/main.go.tmpl":    "package main\n\n// {{ .serviceName }} by {{ .owner }}\nfunc main() {\n}\n",
+	})
+}
+
+type invocation struct {
+	exitCode int
+	out      string
+	errOut   string
+}
+
+func invoke(t *testing.T, stdin string, env map[string]string, args ...string) invocation {
+	var out, errOut bytes.Buffer
+	code := run(context.TODO(), args, strings.NewReader(stdin), &out, &errOut, func(key string) string {
+		return env[key]
+	})
+	return invocation{exitCode: code, out: out.String(), errOut: errOut.String()}
+}
+
+func TestListGenerators(t *testing.T) {
+	source := createSource(t)
+	result := invoke(t, "", nil, "list-generators", "-source", source, "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+	require.Equal(t, "main\n", result.out)
+}
+
+func TestListGenerators_SourceRef(t *testing.T) {
+	source := createSource(t)
+	repo, err := git.PlainOpen(source)
+	require.Nil(t, err)
+	head, err := repo.Head()
+	require.Nil(t, err)
+	_, err = repo.CreateTag("v1", head.Hash(), nil)
+	require.Nil(t, err)
+	testrepo.PushCommit(t, source, map[string]string{"generator-main.yaml": "", "generator-other.yaml": generatorSpec}, "rename")
+
+	for _, ref := range []string{"main", "v1", head.Hash().String()[:8]} {
+		result := invoke(t, "", nil, "list-generators", "-source", source, "-source-ref", ref, "-workdir", t.TempDir())
+		require.Equal(t, exitOk, result.exitCode, result.errOut)
+		expected := "main\n"
+		if ref == "main" {
+			expected = "other\n"
+		}
+		require.Equal(t, expected, result.out, ref)
+	}
+}
+
+func TestShowSpec_Json(t *testing.T) {
+	source := createSource(t)
+	result := invoke(t, "", nil, "show-spec", "-source", source, "-output", "json", "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+
+	spec := specJSON{}
+	require.Nil(t, json.Unmarshal([]byte(result.out), &spec))
+	require.Equal(t, []variableJSON{
+		{Name: "owner", Description: "The owning team", Required: true},
+		{Name: "serviceName", Description: "The name of the service", Pattern: "^[a-z-]+$", Default: "demo-service"},
+	}, spec.Variables)
+	require.Equal(t, "cmd/{{ .serviceName }}/main.go", spec.Templates[0].Target)
+}
+
+func TestValidate(t *testing.T) {
+	source := createSource(t)
+	paramsFile := filepath.Join(t.TempDir(), "params.yaml")
+	require.Nil(t, os.WriteFile(paramsFile, []byte("owner: platform\nserviceName: from-file\n"), 0644))
+
+	result := invoke(t, "", nil, "validate", "-source", source, "-params-file", paramsFile, "-param", "serviceName=Not Valid", "-workdir", t.TempDir())
+	require.Equal(t, exitError, result.exitCode)
+	require.Contains(t, result.out, "parameters are invalid")
+
+	result = invoke(t, "", nil, "validate", "-source", source, "-params-file", paramsFile, "-output", "json", "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+	require.JSONEq(t, `{"valid": true, "errors": []}`, result.out)
+}
+
+func TestListGenerators_Alias(t *testing.T) {
+	source := createSource(t)
+	result := invoke(t, "", nil, "list-generators", "-source", "templates/main", "-alias", "templates/main="+source, "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+	require.Equal(t, "main\n", result.out)
+
+	result = invoke(t, "", nil, "list-generators", "-source", "templates/main", "-alias", "templates/main", "-workdir", t.TempDir())
+	require.NotEqual(t, exitOk, result.exitCode)
+	require.Contains(t, result.errOut, "expected key=value")
+}
+
+func TestValidate_Interactive(t *testing.T) {
+	source := createSource(t)
+	result := invoke(t, "platform\n\n", nil, "validate", "-source", source, "-interactive", "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+	require.Contains(t, result.errOut, "owner (The owning team): ")
+	require.Contains(t, result.errOut, "serviceName (The name of the service) [demo-service]: ")
+	require.Equal(t, "parameters are valid\n", result.out)
+}
+
+func TestRender_DryRunWithDiff(t *testing.T) {
+	source := createSource(t)
+	target := testrepo.Create(t, map[string]string{"README.md": "# target\n"})
+	workdir := t.TempDir()
+
+	result := invoke(t, "", nil, "render", "-source", source, "-target", target, "-param", "owner=platform", "-dry-run", "-diff", "-workdir", workdir)
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+	require.Contains(t, result.out, "added    cmd/demo-service/main.go\n")
+	require.Contains(t, result.out, "+++ b/cmd/demo-service/main.go\n@@ -0,0 +1,5 @@\n+package main\n")
+
+	entries, err := os.ReadDir(workdir)
+	require.Nil(t, err)
+	require.Empty(t, entries)
+	require.Equal(t, "", testrepo.ReadFile(t, target, "main", "generated-main.yaml"))
+}
+
+func TestPushAndRegenerate(t *testing.T) {
+	source := createSource(t)
+	target := testrepo.Create(t, map[string]string{"README.md": "# target\n"})
+
+	result := invoke(t, "", nil, "push", "-source", source, "-target", target, "-param", "owner=platform", "-workdir", t.TempDir())
+	require.Equal(t, exitUsage, result.exitCode)
+	require.Contains(t, result.errOut, "needs credentials")
+
+	// the file transport ignores credentials, but pushing needs some
+	env := map[string]string{envUsername: "somebody", envPassword: "secret"}
+	result = invoke(t, "", env, "push", "-source", source, "-target", target, "-param", "owner=platform", "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+	require.Contains(t, result.out, "pushed to origin")
+	require.Equal(t, "package main\n\n// demo-service by platform\nfunc main() {\n}\n", testrepo.ReadFile(t, target, "main", "cmd/demo-service/main.go"))
+
+	result = invoke(t, "", env, "regenerate", "-source", source, "-target", target, "-param", "serviceName=renamed", "-output", "json", "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+	parsed := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal([]byte(result.out), &parsed))
+	require.Equal(t, true, parsed["success"])
+	require.Equal(t, "package main\n\n// renamed by platform\nfunc main() {\n}\n", testrepo.ReadFile(t, target, "main", "cmd/renamed/main.go"))
+}
+
+func TestRender_InteractivePrefilledFromTarget(t *testing.T) {
+	source := createSource(t)
+	target := testrepo.Create(t, map[string]string{"README.md": "# target\n"})
+	env := map[string]string{envUsername: "somebody", envPassword: "secret"}
+	result := invoke(t, "", env, "push", "-source", source, "-target", target, "-param", "owner=platform", "-param", "serviceName=billing", "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+
+	result = invoke(t, "\nNot Valid\n\n", nil, "render", "-source", source, "-target", target, "-interactive", "-dry-run", "-workdir", t.TempDir())
+	require.Equal(t, exitOk, result.exitCode, result.errOut)
+	require.Contains(t, result.errOut, "owner (The owning team) [platform]: ")
+	require.Contains(t, result.errOut, "'Not Valid' does not match ^[a-z-]+$\nserviceName (The name of the service) [billing]: ")
+	require.Contains(t, result.out, "no changes")
+}
+
+func TestUsage(t *testing.T) {
+	result := invoke(t, "", nil, "frobnicate")
+	require.Equal(t, exitUsage, result.exitCode)
+	require.Contains(t, result.errOut, "unknown command 'frobnicate'")
+
+	result = invoke(t, "", nil, "list-generators")
+	require.Equal(t, exitUsage, result.exitCode)
+	require.Contains(t, result.errOut, "-source is required")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/jobfile"
+	"os"
+)
+
+// options holds the flags of all commands, each command registers the groups it needs.
+type options struct {
+	sourceUrl string
+	sourceRef string
+
+	targetUrl  string
+	branch     string
+	baseBranch string
+
+	generator      string
+	renderSpecFile string
+
+	params      paramFlag
+	paramsFile  string
+	interactive bool
+
+	strategy            string
+	prune               bool
+	preserveUserRegions bool
+
+	authorName  string
+	authorEmail string
+	message     string
+
+	workdir string
+	keep    bool
+	dryRun  bool
+	diff    bool
+
+	jobsFile    string
+	parallelism int
+
+	output  string
+	verbose bool
+	retries int
+
+	auth      authOptions
+	transport transportOptions
+	urls      urlRewritingOptions
+}
+
+func newFlagSet(c *cli, name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.errOut)
+	o := &options{params: paramFlag{}}
+	fs.StringVar(&o.output, "output", "text", "output format, 'text' or 'json'")
+	fs.BoolVar(&o.verbose, "v", false, "log progress to stderr")
+	fs.StringVar(&o.workdir, "workdir", os.TempDir(), "directory to create the temporary working directory in")
+	fs.IntVar(&o.retries, "retries", 1, "how often to try clones and pushes that fail for transient reasons like dropped connections")
+	o.auth.register(fs)
+	o.transport.register(fs)
+	o.urls.register(fs)
+	return fs, o
+}
+
+func (o *options) registerSource(fs *flag.FlagSet) {
+	fs.StringVar(&o.sourceUrl, "source", "", "url of the generator repository (required)")
+	fs.StringVar(&o.sourceRef, "source-ref", "main", "branch, tag or commit hash of the generator repository")
+}
+
+func (o *options) registerGenerator(fs *flag.FlagSet) {
+	fs.StringVar(&o.generator, "generator", "main", "name of the generator, e.g. 'main' for generator-main.yaml")
+}
+
+func (o *options) registerParameters(fs *flag.FlagSet) {
+	fs.Var(o.params, "param", "parameter as name=value, may be repeated, overrides -params-file")
+	fs.StringVar(&o.paramsFile, "params-file", "", "yaml or json file with parameters")
+	fs.BoolVar(&o.interactive, "interactive", false, "prompt for parameters")
+}
+
+func (o *options) registerTarget(fs *flag.FlagSet) {
+	fs.StringVar(&o.targetUrl, "target", "", "url of the target repository (required)")
+	fs.StringVar(&o.branch, "branch", "main", "branch of the target repository to generate into")
+	fs.StringVar(&o.baseBranch, "base-branch", "", "branch to create -branch from if it does not exist, defaults to -branch")
+	fs.StringVar(&o.renderSpecFile, "render-spec", "", "render spec file in the target, defaults to generated-<generator>.yaml")
+	fs.StringVar(&o.strategy, "strategy", "overwrite", "how to update generated files: overwrite, merge, or pristine-branch")
+	fs.BoolVar(&o.prune, "prune", false, "delete files that are no longer generated")
+	fs.BoolVar(&o.preserveUserRegions, "preserve-user-regions", false, "keep the contents of user regions")
+	fs.BoolVar(&o.diff, "diff", false, "show the changes as unified diffs")
+}
+
+func (o *options) registerCommit(fs *flag.FlagSet) {
+	fs.StringVar(&o.authorName, "author-name", "go-generator-git", "author of the commit")
+	fs.StringVar(&o.authorEmail, "author-email", "go-generator-git@localhost", "email of the author of the commit")
+	fs.StringVar(&o.message, "message", "", "commit message, defaults to 'regenerate with generator <generator>'")
+}
+
+func (o *options) parse(c *cli, fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usageErrorf("unexpected arguments %v", fs.Args())
+	}
+	if o.output != "text" && o.output != "json" {
+		return usageErrorf("unknown output format '%s'", o.output)
+	}
+	if fs.Lookup("source") != nil && o.sourceUrl == "" && fs.Lookup("jobs") == nil {
+		return usageErrorf("-source is required")
+	}
+	if fs.Lookup("target") != nil && o.targetUrl == "" && fs.Lookup("jobs") == nil {
+		return usageErrorf("-target is required")
+	}
+	if _, ok := jobfile.ParseUpdateStrategy(o.strategy); fs.Lookup("strategy") != nil && !ok {
+		return usageErrorf("unknown strategy '%s'", o.strategy)
+	}
+	if o.renderSpecFile == "" {
+		o.renderSpecFile = fmt.Sprintf("generated-%s.yaml", o.generator)
+	}
+	if o.baseBranch == "" {
+		o.baseBranch = o.branch
+	}
+	if o.message == "" {
+		o.message = fmt.Sprintf("regenerate with generator %s", o.generator)
+	}
+	o.auth.applyEnv(c.getenv)
+	if o.verbose {
+		setupLogging(c.errOut)
+	}
+	return nil
+}
+
+// retryPolicy is the default policy with the attempts from -retries, or no retrying for -retries 1.
+func (o *options) retryPolicy() api.RetryPolicy {
+	if o.retries <= 1 {
+		return api.RetryPolicy{}
+	}
+	policy := api.DefaultRetryPolicy()
+	policy.MaxAttempts = o.retries
+	return policy
+}
+
+func (o *options) configure(gen api.GitApi) {
+	strategy, _ := jobfile.ParseUpdateStrategy(o.strategy)
+	gen.SetUpdateStrategy(strategy)
+	gen.SetPruneOrphans(o.prune)
+	gen.SetPreserveUserRegions(o.preserveUserRegions)
+}
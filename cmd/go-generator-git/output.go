@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/report"
+	"io"
+	"sort"
+)
+
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+type specJSON struct {
+	Variables []variableJSON `json:"variables"`
+	Templates []templateJSON `json:"templates"`
+}
+
+type variableJSON struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Pattern     string      `json:"pattern,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Required    bool        `json:"required"`
+}
+
+type templateJSON struct {
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Condition string `json:"condition,omitempty"`
+	WithItems bool   `json:"withItems,omitempty"`
+	JustCopy  bool   `json:"justCopy,omitempty"`
+}
+
+func (c *cli) printSpec(o *options, spec *genlibapi.GeneratorSpec) error {
+	var names []string
+	for name := range spec.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if o.output == "json" {
+		result := specJSON{Variables: []variableJSON{}, Templates: []templateJSON{}}
+		for _, name := range names {
+			v := spec.Variables[name]
+			result.Variables = append(result.Variables, variableJSON{
+				Name:        name,
+				Description: v.Description,
+				Pattern:     v.ValidationPattern,
+				Default:     jsonCompatible(v.DefaultValue),
+				Required:    v.DefaultValue == nil,
+			})
+		}
+		for _, t := range spec.Templates {
+			result.Templates = append(result.Templates, templateJSON{
+				Source:    t.RelativeSourcePath,
+				Target:    t.RelativeTargetPath,
+				Condition: t.Condition,
+				WithItems: len(t.WithItems) > 0,
+				JustCopy:  t.JustCopy,
+			})
+		}
+		return c.printJSON(result)
+	}
+
+	fmt.Fprintln(c.out, "variables:")
+	for _, name := range names {
+		v := spec.Variables[name]
+		fmt.Fprintf(c.out, "  %s", name)
+		if v.DefaultValue == nil {
+			fmt.Fprint(c.out, " (required)")
+		} else {
+			fmt.Fprintf(c.out, " [%v]", v.DefaultValue)
+		}
+		fmt.Fprintln(c.out)
+		if v.Description != "" {
+			fmt.Fprintf(c.out, "      %s\n", v.Description)
+		}
+		if v.ValidationPattern != "" {
+			fmt.Fprintf(c.out, "      must match %s\n", v.ValidationPattern)
+		}
+	}
+	fmt.Fprintln(c.out, "templates:")
+	for _, t := range spec.Templates {
+		fmt.Fprintf(c.out, "  %s -> %s\n", t.RelativeSourcePath, t.RelativeTargetPath)
+	}
+	return nil
+}
+
+type validationJSON struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+}
+
+func (c *cli) printValidation(o *options, response *genlibapi.Response) error {
+	var errs []string
+	for _, err := range response.Errors {
+		errs = append(errs, err.Error())
+	}
+	if o.output == "json" {
+		return c.printJSON(validationJSON{Valid: response.Success, Errors: nonNil(errs)})
+	}
+	if response.Success {
+		fmt.Fprintln(c.out, "parameters are valid")
+		return nil
+	}
+	fmt.Fprintln(c.out, "parameters are invalid:")
+	for _, e := range errs {
+		fmt.Fprintf(c.out, "  %s\n", e)
+	}
+	return nil
+}
+
+func (c *cli) printSession(o *options, session *api.SessionResult) error {
+	if o.output == "json" {
+		return report.WriteJSON(c.out, session)
+	}
+	printSessionText(c.out, session, o.diff)
+	return nil
+}
+
+func printSessionText(w io.Writer, session *api.SessionResult, withDiff bool) {
+	for _, p := range session.Phases {
+		status := "ok"
+		if p.Err != nil {
+			status = "FAILED: " + p.Err.Error()
+		}
+		fmt.Fprintf(w, "%-18s %s (%.1fs)\n", p.Name, status, p.Duration.Seconds())
+	}
+	if session.RenderSpec != nil {
+		for _, err := range session.RenderSpec.Errors {
+			fmt.Fprintf(w, "parameter error: %s\n", err.Error())
+		}
+	}
+	if g := session.Generate; g != nil && g.Response != nil {
+		for _, err := range g.Errors {
+			fmt.Fprintf(w, "render error: %s\n", err.Error())
+		}
+		for _, f := range g.RenderedFiles {
+			for _, err := range f.Errors {
+				fmt.Fprintf(w, "render error in %s: %s\n", f.RelativeFilePath, err.Error())
+			}
+		}
+		for _, conflict := range g.Conflicts {
+			fmt.Fprintf(w, "merge conflict: %s (%d regions)\n", conflict.Path, conflict.Regions)
+		}
+		for _, region := range g.OrphanedRegions {
+			fmt.Fprintf(w, "orphaned user region: %s in %s\n", region.Name, region.Path)
+		}
+	}
+	if len(session.Changes) == 0 && session.Generate != nil && session.Generate.Success {
+		fmt.Fprintln(w, "no changes")
+	}
+	for _, change := range session.Changes {
+		fmt.Fprintf(w, "%-8s %s\n", change.Change, change.Path)
+	}
+	if withDiff {
+		for _, change := range session.Changes {
+			if change.Binary {
+				fmt.Fprintf(w, "binary file %s differs\n", change.Path)
+			} else {
+				fmt.Fprint(w, change.Diff)
+			}
+		}
+	}
+	if commit := session.Commit; commit != nil {
+		if commit.CommitHash != "" {
+			fmt.Fprintf(w, "committed %s\n", commit.CommitHash)
+		}
+		if commit.Tag != "" {
+			fmt.Fprintf(w, "tagged %s\n", commit.Tag)
+		}
+		for _, push := range commit.PushResults {
+			if push.Success {
+				fmt.Fprintf(w, "pushed to %s (%s)\n", push.RemoteName, push.RemoteUrl)
+			} else {
+				fmt.Fprintf(w, "push to %s (%s) failed: %v\n", push.RemoteName, push.RemoteUrl, push.Err)
+			}
+		}
+	}
+}
+
+func (c *cli) printBatch(o *options, jobs []api.Job, results []*api.SessionResult) error {
+	if o.output == "json" {
+		if _, err := io.WriteString(c.out, "[\n"); err != nil {
+			return err
+		}
+		for i, result := range results {
+			if i > 0 {
+				if _, err := io.WriteString(c.out, ",\n"); err != nil {
+					return err
+				}
+			}
+			if result == nil {
+				if _, err := io.WriteString(c.out, "null\n"); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := report.WriteJSON(c.out, result); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(c.out, "]\n")
+		return err
+	}
+
+	for i, result := range results {
+		name := jobs[i].Name
+		if name == "" {
+			name = fmt.Sprintf("job %d", i+1)
+		}
+		if result == nil {
+			fmt.Fprintf(c.out, "== %s: skipped\n", name)
+			continue
+		}
+		status := "ok"
+		if !result.Success() {
+			status = "failed"
+		}
+		fmt.Fprintf(c.out, "== %s: %s\n", name, status)
+		printSessionText(c.out, result, o.diff)
+	}
+	return nil
+}
+
+// jsonCompatible converts the maps yaml produces for structured values, which encoding/json cannot handle.
+func jsonCompatible(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{})
+		for k, item := range value {
+			result[fmt.Sprint(k)] = jsonCompatible(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = jsonCompatible(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
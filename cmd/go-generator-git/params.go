@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// paramFlag collects repeated -param name=value flags.
+type paramFlag map[string]interface{}
+
+func (p paramFlag) String() string {
+	var pairs []string
+	for k, v := range p {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (p paramFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected name=value, got '%s'", value)
+	}
+	p[parts[0]] = parts[1]
+	return nil
+}
+
+// parameters merges, in increasing priority, the given base values, the parameters file, and the -param flags.
+func (o *options) parameters(base map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for k, v := range base {
+		result[k] = v
+	}
+	if o.paramsFile != "" {
+		contents, err := ioutil.ReadFile(o.paramsFile)
+		if err != nil {
+			return nil, err
+		}
+		fromFile := make(map[string]interface{})
+		// yaml is a superset of json, so this reads both
+		if err := yaml.Unmarshal(contents, &fromFile); err != nil {
+			return nil, fmt.Errorf("cannot read parameters from %s: %w", o.paramsFile, err)
+		}
+		for k, v := range fromFile {
+			result[k] = v
+		}
+	}
+	for k, v := range o.params {
+		result[k] = v
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"os"
+)
+
+// transportOptions apply to the http(s) connections to all hosts.
+type transportOptions struct {
+	caFile     string
+	clientCert string
+	clientKey  string
+	proxy      string
+	insecure   bool
+}
+
+func (t *transportOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&t.caFile, "ca-file", "", "PEM file with CA certificates to trust in addition to those of the system")
+	fs.StringVar(&t.clientCert, "client-cert", "", "PEM file with a client certificate for mutual TLS")
+	fs.StringVar(&t.clientKey, "client-key", "", "PEM file with the key of -client-cert")
+	fs.StringVar(&t.proxy, "proxy", "", "url of the proxy for http(s), defaults to HTTPS_PROXY and HTTP_PROXY")
+	fs.BoolVar(&t.insecure, "insecure-skip-tls-verify", false, "accept any server certificate (for testing only)")
+}
+
+// hosts returns the settings for all hosts, or nil if no flag is set.
+func (t *transportOptions) hosts() ([]api.HostTransport, error) {
+	if *t == (transportOptions{}) {
+		return nil, nil
+	}
+	hostTransport := api.HostTransport{Host: "*", Proxy: t.proxy, InsecureSkipVerify: t.insecure}
+	for _, file := range []struct {
+		path     string
+		contents *[]byte
+	}{
+		{t.caFile, &hostTransport.CABundle},
+		{t.clientCert, &hostTransport.ClientCertificate},
+		{t.clientKey, &hostTransport.ClientKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+		contents, err := os.ReadFile(file.path)
+		if err != nil {
+			return nil, err
+		}
+		*file.contents = contents
+	}
+	return []api.HostTransport{hostTransport}, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/jobfile"
+	"sort"
+	"strings"
+)
+
+// mappingFlag collects repeated -flag key=value flags.
+type mappingFlag map[string]string
+
+func (m mappingFlag) String() string {
+	var pairs []string
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m mappingFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected key=value, got '%s'", value)
+	}
+	m[parts[0]] = parts[1]
+	return nil
+}
+
+// urlRewritingOptions let repository urls be aliases or be redirected to mirrors.
+type urlRewritingOptions struct {
+	aliases   mappingFlag
+	insteadOf mappingFlag
+}
+
+func (u *urlRewritingOptions) register(fs *flag.FlagSet) {
+	u.aliases = mappingFlag{}
+	u.insteadOf = mappingFlag{}
+	fs.Var(u.aliases, "alias", "name=url, lets name be used as a repository url (repeatable)")
+	fs.Var(u.insteadOf, "instead-of", "prefix=replacement, replaces the prefix of repository urls like git's insteadOf (repeatable)")
+}
+
+// rules returns the rewriting, or nil if no flag is set.
+func (u *urlRewritingOptions) rules() *api.UrlRewriting {
+	if len(u.aliases) == 0 && len(u.insteadOf) == 0 {
+		return nil
+	}
+	return &api.UrlRewriting{Aliases: u.aliases, InsteadOf: u.insteadOf}
+}
+
+// jobfileRules is rules for the defaults of a job file.
+func (u *urlRewritingOptions) jobfileRules() *jobfile.UrlRewriting {
+	if len(u.aliases) == 0 && len(u.insteadOf) == 0 {
+		return nil
+	}
+	return &jobfile.UrlRewriting{Aliases: u.aliases, InsteadOf: u.insteadOf}
+}
@@ -0,0 +1,56 @@
+// Package credentials has ready-made api.CredentialResolvers for the usual places credentials come from:
+// .netrc files, tokens in environment variables, git credential helpers, and ssh agents and key files.
+//
+// Combine them with Chain, which asks one after the other:
+//
+//	generatorgit.SetCredentialResolver(credentials.Chain(
+//		credentials.EnvToken("github.com", "GITHUB_TOKEN", ""),
+//		credentials.Netrc(""),
+//		credentials.SSHAgent(),
+//	))
+package credentials
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+)
+
+// Chain asks the resolvers in order, and returns the first auth method one of them has.
+// An error of any of them stops the search.
+func Chain(resolvers ...api.CredentialResolver) api.CredentialResolver {
+	return api.CredentialResolverFunc(func(ctx context.Context, repoUrl string, access api.Access) (transport.AuthMethod, error) {
+		for _, resolver := range resolvers {
+			auth, err := resolver.Resolve(ctx, repoUrl, access)
+			if err != nil || auth != nil {
+				return auth, err
+			}
+		}
+		return nil, nil
+	})
+}
+
+// Static always returns auth, for example to use the same token for every repository.
+func Static(auth transport.AuthMethod) api.CredentialResolver {
+	return api.CredentialResolverFunc(func(context.Context, string, api.Access) (transport.AuthMethod, error) {
+		return auth, nil
+	})
+}
+
+// endpoint parses a repository url, including scp-like ssh urls such as git@github.com:org/repo.git.
+// Returns nil for urls it cannot parse.
+func endpoint(repoUrl string) *transport.Endpoint {
+	ep, err := transport.NewEndpoint(repoUrl)
+	if err != nil {
+		return nil
+	}
+	return ep
+}
+
+func isHttp(ep *transport.Endpoint) bool {
+	return ep != nil && (ep.Protocol == "http" || ep.Protocol == "https")
+}
+
+func isSsh(ep *transport.Endpoint) bool {
+	return ep != nil && ep.Protocol == "ssh"
+}
@@ -0,0 +1,108 @@
+package credentials
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func resolve(t *testing.T, resolver api.CredentialResolver, repoUrl string) transport.AuthMethod {
+	auth, err := resolver.Resolve(context.TODO(), repoUrl, api.AccessRead)
+	require.Nil(t, err)
+	return auth
+}
+
+func TestNetrc(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	require.Nil(t, os.WriteFile(path, []byte(`machine git.example.com
+  login builder
+  password secret
+
+macdef init
+machine not.a.machine login nobody password nothing
+
+machine other.example.com login other password other-secret
+default login anonymous password guest
+`), 0600))
+	resolver := Netrc(path)
+
+	require.Equal(t, &githttp.BasicAuth{Username: "builder", Password: "secret"}, resolve(t, resolver, "https://git.example.com/org/repo.git"))
+	require.Equal(t, &githttp.BasicAuth{Username: "other", Password: "other-secret"}, resolve(t, resolver, "https://other.example.com:8443/repo.git"))
+	require.Equal(t, &githttp.BasicAuth{Username: "anonymous", Password: "guest"}, resolve(t, resolver, "https://unknown.example.com/repo.git"))
+	require.Nil(t, resolve(t, resolver, "git@git.example.com:org/repo.git"))
+	require.Nil(t, resolve(t, Netrc(filepath.Join(t.TempDir(), "missing")), "https://git.example.com/org/repo.git"))
+}
+
+func TestEnvToken(t *testing.T) {
+	t.Setenv("TEST_GIT_TOKEN", "t0ken")
+	resolver := EnvToken("*.example.com", "TEST_GIT_TOKEN", "")
+
+	require.Equal(t, &githttp.BasicAuth{Username: "x-access-token", Password: "t0ken"}, resolve(t, resolver, "https://git.example.com/org/repo.git"))
+	require.Nil(t, resolve(t, resolver, "https://github.com/org/repo.git"))
+	require.Nil(t, resolve(t, resolver, "ssh://git@git.example.com/org/repo.git"))
+
+	t.Setenv("TEST_GIT_TOKEN", "")
+	require.Nil(t, resolve(t, resolver, "https://git.example.com/org/repo.git"))
+}
+
+func TestGitCredentialHelper(t *testing.T) {
+	dir := t.TempDir()
+	helper := filepath.Join(dir, "helper")
+	// answers with the host it was asked for as the password, and records the request
+	require.Nil(t, os.WriteFile(helper, []byte(`#!/bin/sh
+test "$1" = get || exit 1
+cat > "$(dirname "$0")/request"
+host=$(grep '^host=' "$(dirname "$0")/request" | cut -d= -f2)
+echo "username=helper-user"
+echo "password=for-$host"
+`), 0755))
+
+	auth := resolve(t, GitCredentialHelper(helper), "https://git.example.com:8443/org/repo.git")
+	require.Equal(t, &githttp.BasicAuth{Username: "helper-user", Password: "for-git.example.com:8443"}, auth)
+	request, err := os.ReadFile(filepath.Join(dir, "request"))
+	require.Nil(t, err)
+	require.Equal(t, "protocol=https\nhost=git.example.com:8443\n\n", string(request))
+
+	require.Nil(t, resolve(t, GitCredentialHelper("!echo quit=true; true"), "https://git.example.com/org/repo.git"))
+
+	_, err = GitCredentialHelper("!exit 3").Resolve(context.TODO(), "https://git.example.com/org/repo.git", api.AccessWrite)
+	require.NotNil(t, err)
+}
+
+func TestSSHKeyFile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "id_rsa")
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	resolver := SSHKeyFile(path, "")
+
+	auth := resolve(t, resolver, "deploy@git.example.com:org/repo.git")
+	require.IsType(t, &gitssh.PublicKeys{}, auth)
+	require.Equal(t, "deploy", auth.(*gitssh.PublicKeys).User)
+	require.Equal(t, "git", resolve(t, resolver, "ssh://git.example.com/org/repo.git").(*gitssh.PublicKeys).User)
+	require.Nil(t, resolve(t, resolver, "https://git.example.com/org/repo.git"))
+}
+
+func TestSSHAgent_WithoutAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	require.Nil(t, resolve(t, SSHAgent(), "git@git.example.com:org/repo.git"))
+}
+
+func TestChain(t *testing.T) {
+	t.Setenv("TEST_GIT_TOKEN", "t0ken")
+	fallback := &githttp.BasicAuth{Username: "fallback"}
+	resolver := Chain(EnvToken("github.com", "TEST_GIT_TOKEN", "bot"), Static(fallback))
+
+	require.Equal(t, &githttp.BasicAuth{Username: "bot", Password: "t0ken"}, resolve(t, resolver, "https://github.com/org/repo.git"))
+	require.Equal(t, fallback, resolve(t, resolver, "https://example.com/org/repo.git"))
+}
@@ -0,0 +1,36 @@
+package credentials
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"os"
+	"path"
+)
+
+const defaultTokenUser = "x-access-token"
+
+// EnvToken uses the token in the environment variable for http(s) repositories on hosts that match
+// hostPattern, e.g. 'github.com' or '*.example.com' (see path.Match). The variable is read on every
+// lookup, and an empty one has no credentials. username defaults to 'x-access-token', which most git
+// servers accept along with a token.
+func EnvToken(hostPattern string, variable string, username string) api.CredentialResolver {
+	if username == "" {
+		username = defaultTokenUser
+	}
+	return api.CredentialResolverFunc(func(_ context.Context, repoUrl string, _ api.Access) (transport.AuthMethod, error) {
+		ep := endpoint(repoUrl)
+		if !isHttp(ep) {
+			return nil, nil
+		}
+		if matched, err := path.Match(hostPattern, ep.Host); err != nil || !matched {
+			return nil, err
+		}
+		token := os.Getenv(variable)
+		if token == "" {
+			return nil, nil
+		}
+		return &githttp.BasicAuth{Username: username, Password: token}, nil
+	})
+}
@@ -0,0 +1,234 @@
+package credentials
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGitHubApiUrl  = "https://api.github.com"
+	defaultGitHubHost    = "github.com"
+	defaultRefreshBefore = 5 * time.Minute
+
+	// GitHub accepts app JWTs for at most 10 minutes, and clocks may be a little off
+	jwtLifetime = 9 * time.Minute
+	jwtBackdate = time.Minute
+)
+
+// Settings for GitHubApp
+type GitHubAppOptions struct {
+	// the id of the GitHub App, from its settings page
+	AppID int64
+
+	// the private key of the app in PEM format, as downloaded from its settings page
+	PrivateKey []byte
+
+	// base url of the REST API, defaults to https://api.github.com. For GitHub Enterprise Server,
+	// this is https://<host>/api/v3.
+	ApiBaseUrl string
+
+	// host of the repositories, defaults to github.com, or the host of ApiBaseUrl if that is set.
+	// Repositories on other hosts get no credentials.
+	Host string
+
+	// get a new token once the cached one expires within this time, defaults to 5 minutes
+	RefreshBefore time.Duration
+
+	// defaults to http.DefaultClient
+	HttpClient *http.Client
+}
+
+// GitHubAppResolver authenticates as the installation of a GitHub App for the owner of a repository,
+// see GitHubApp.
+type GitHubAppResolver struct {
+	options GitHubAppOptions
+	key     *rsa.PrivateKey
+	now     func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]installationToken
+}
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// GitHubApp returns a resolver for repositories on GitHub that signs a JWT as the app, exchanges it for
+// an installation token for the owner of the repository, and caches the token until it nears expiry.
+// The app must be installed for the owner, with contents permission for the access needed.
+func GitHubApp(options GitHubAppOptions) (*GitHubAppResolver, error) {
+	key, err := parseRsaKey(options.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if options.AppID == 0 {
+		return nil, errors.New("github app id is required")
+	}
+	if options.ApiBaseUrl == "" {
+		options.ApiBaseUrl = defaultGitHubApiUrl
+		if options.Host == "" {
+			options.Host = defaultGitHubHost
+		}
+	}
+	options.ApiBaseUrl = strings.TrimSuffix(options.ApiBaseUrl, "/")
+	if options.Host == "" {
+		base, err := url.Parse(options.ApiBaseUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid github api url: %w", err)
+		}
+		options.Host = base.Hostname()
+	}
+	if options.RefreshBefore <= 0 {
+		options.RefreshBefore = defaultRefreshBefore
+	}
+	if options.HttpClient == nil {
+		options.HttpClient = http.DefaultClient
+	}
+	return &GitHubAppResolver{options: options, key: key, now: time.Now, tokens: make(map[string]installationToken)}, nil
+}
+
+func (r *GitHubAppResolver) Resolve(ctx context.Context, repoUrl string, _ api.Access) (transport.AuthMethod, error) {
+	ep := endpoint(repoUrl)
+	if !isHttp(ep) || ep.Host != r.options.Host {
+		return nil, nil
+	}
+	owner, repo, ok := ownerAndRepo(ep.Path)
+	if !ok {
+		return nil, nil
+	}
+	token, err := r.Token(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return &githttp.BasicAuth{Username: defaultTokenUser, Password: token}, nil
+}
+
+// Token returns an installation token for the owner, from the cache if it does not expire soon.
+// repo is used to find the installation, and may be any repository of the owner the app can see.
+func (r *GitHubAppResolver) Token(ctx context.Context, owner string, repo string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, ok := r.tokens[owner]; ok && r.now().Add(r.options.RefreshBefore).Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	jwt, err := r.jwt()
+	if err != nil {
+		return "", err
+	}
+	var installation struct {
+		Id int64 `json:"id"`
+	}
+	if err := r.call(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/installation", url.PathEscape(owner), url.PathEscape(repo)), jwt, &installation); err != nil {
+		return "", fmt.Errorf("cannot find the installation of github app %d for %s: %w", r.options.AppID, owner, err)
+	}
+	var created struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := r.call(ctx, http.MethodPost, fmt.Sprintf("/app/installations/%d/access_tokens", installation.Id), jwt, &created); err != nil {
+		return "", fmt.Errorf("cannot create a token for installation %d of github app %d: %w", installation.Id, r.options.AppID, err)
+	}
+	if created.Token == "" {
+		return "", fmt.Errorf("github returned no token for installation %d", installation.Id)
+	}
+	r.tokens[owner] = installationToken{token: created.Token, expiresAt: created.ExpiresAt}
+	return created.Token, nil
+}
+
+// jwt signs a token that authenticates as the app itself (RS256).
+func (r *GitHubAppResolver) jwt() (string, error) {
+	now := r.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-jwtBackdate).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": r.options.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, r.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (r *GitHubAppResolver) call(ctx context.Context, method string, path string, jwt string, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, method, r.options.ApiBaseUrl+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+jwt)
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	response, err := r.options.HttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	switch {
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s: %w", response.Status, api.ErrAuthentication)
+	case response.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s: %w", response.Status, api.ErrRepositoryNotFound)
+	case response.StatusCode >= 300:
+		return fmt.Errorf("unexpected response %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, result)
+}
+
+// ownerAndRepo splits a repository path like /org/repo.git.
+func ownerAndRepo(path string) (string, string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), true
+}
+
+func parseRsaKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("github app private key is not in PEM format")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse github app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github app private key is not an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,138 @@
+package credentials
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeGitHub emulates the installation and token endpoints of the GitHub API for app 42, installed for 'acme'
+type fakeGitHub struct {
+	t       *testing.T
+	key     *rsa.PublicKey
+	now     func() time.Time
+	created int32
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.validJwt(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/repos/acme/service/installation":
+		fmt.Fprint(w, `{"id": 7, "account": {"login": "acme"}}`)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/installation"):
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v3/app/installations/7/access_tokens":
+		n := atomic.AddInt32(&f.created, 1)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "ghs_token%d", "expires_at": "%s"}`, n, f.now().Add(time.Hour).UTC().Format(time.RFC3339))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeGitHub) validJwt(jwt string) bool {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.Nil(f.t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(f.key, crypto.SHA256, digest[:], signature) != nil {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.Nil(f.t, err)
+	var claims struct {
+		Iat int64 `json:"iat"`
+		Exp int64 `json:"exp"`
+		Iss int64 `json:"iss"`
+	}
+	require.Nil(f.t, json.Unmarshal(payload, &claims))
+	now := f.now().Unix()
+	return claims.Iss == 42 && claims.Iat <= now && now < claims.Exp && claims.Exp-claims.Iat <= 600
+}
+
+func newGitHubAppFixture(t *testing.T) (*GitHubAppResolver, *fakeGitHub, *time.Time, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+
+	fake := &fakeGitHub{t: t, key: &key.PublicKey, now: now}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	resolver, err := GitHubApp(GitHubAppOptions{
+		AppID:      42,
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		ApiBaseUrl: server.URL + "/api/v3/",
+	})
+	require.Nil(t, err)
+	resolver.now = now
+	return resolver, fake, &clock, "http://127.0.0.1:8080/acme/service.git"
+}
+
+func TestGitHubApp_InstallationToken(t *testing.T) {
+	resolver, fake, _, repoUrl := newGitHubAppFixture(t)
+
+	auth, err := resolver.Resolve(context.TODO(), repoUrl, api.AccessWrite)
+	require.Nil(t, err)
+	require.Equal(t, &githttp.BasicAuth{Username: "x-access-token", Password: "ghs_token1"}, auth)
+	require.Equal(t, int32(1), atomic.LoadInt32(&fake.created))
+}
+
+func TestGitHubApp_CachesUntilNearExpiry(t *testing.T) {
+	resolver, fake, clock, repoUrl := newGitHubAppFixture(t)
+
+	token, err := resolver.Token(context.TODO(), "acme", "service")
+	require.Nil(t, err)
+	require.Equal(t, "ghs_token1", token)
+
+	*clock = clock.Add(50 * time.Minute)
+	auth, err := resolver.Resolve(context.TODO(), repoUrl, api.AccessRead)
+	require.Nil(t, err)
+	require.Equal(t, "ghs_token1", auth.(*githttp.BasicAuth).Password)
+
+	// within 5 minutes of the expiry
+	*clock = clock.Add(6 * time.Minute)
+	auth, err = resolver.Resolve(context.TODO(), repoUrl, api.AccessRead)
+	require.Nil(t, err)
+	require.Equal(t, "ghs_token2", auth.(*githttp.BasicAuth).Password)
+	require.Equal(t, int32(2), atomic.LoadInt32(&fake.created))
+}
+
+func TestGitHubApp_OtherHostsAndMissingInstallation(t *testing.T) {
+	resolver, _, _, _ := newGitHubAppFixture(t)
+
+	auth, err := resolver.Resolve(context.TODO(), "https://gitlab.example.com/acme/service.git", api.AccessRead)
+	require.Nil(t, err)
+	require.Nil(t, auth)
+
+	_, err = resolver.Resolve(context.TODO(), "http://127.0.0.1/elsewhere/service.git", api.AccessRead)
+	require.ErrorIs(t, err, api.ErrRepositoryNotFound)
+}
+
+func TestGitHubApp_InvalidKey(t *testing.T) {
+	_, err := GitHubApp(GitHubAppOptions{AppID: 42, PrivateKey: []byte("not a key")})
+	require.NotNil(t, err)
+}
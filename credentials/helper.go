@@ -0,0 +1,80 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GitCredentialHelper asks a git credential helper for the credentials of http(s) repositories, using
+// the 'get' action of the protocol git uses (see gitcredentials(7)).
+//
+// helper is given as in the credential.helper setting of git: a name like 'store' or 'osxkeychain' runs
+// 'git credential-<name>', an absolute path runs that program, and anything starting with '!' runs in a shell.
+func GitCredentialHelper(helper string) api.CredentialResolver {
+	return api.CredentialResolverFunc(func(ctx context.Context, repoUrl string, _ api.Access) (transport.AuthMethod, error) {
+		ep := endpoint(repoUrl)
+		if !isHttp(ep) {
+			return nil, nil
+		}
+
+		var input bytes.Buffer
+		fmt.Fprintf(&input, "protocol=%s\n", ep.Protocol)
+		host := ep.Host
+		if ep.Port != 0 {
+			host += ":" + strconv.Itoa(ep.Port)
+		}
+		fmt.Fprintf(&input, "host=%s\n", host)
+		if ep.User != "" {
+			fmt.Fprintf(&input, "username=%s\n", ep.User)
+		}
+		input.WriteString("\n")
+
+		cmd := helperCommand(ctx, helper)
+		cmd.Stdin = &input
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("credential helper %s failed: %w: %s", helper, err, strings.TrimSpace(stderr.String()))
+		}
+
+		username, password := ep.User, ""
+		scanner := bufio.NewScanner(bytes.NewReader(output))
+		for scanner.Scan() {
+			key, value, found := strings.Cut(scanner.Text(), "=")
+			if !found {
+				continue
+			}
+			switch key {
+			case "username":
+				username = value
+			case "password":
+				password = value
+			}
+		}
+		if password == "" {
+			return nil, nil
+		}
+		return &githttp.BasicAuth{Username: username, Password: password}, nil
+	})
+}
+
+func helperCommand(ctx context.Context, helper string) *exec.Cmd {
+	switch {
+	case strings.HasPrefix(helper, "!"):
+		return exec.CommandContext(ctx, "sh", "-c", helper[1:]+" get")
+	case filepath.IsAbs(helper):
+		return exec.CommandContext(ctx, helper, "get")
+	default:
+		return exec.CommandContext(ctx, "git", "credential-"+helper, "get")
+	}
+}
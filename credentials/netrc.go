@@ -0,0 +1,114 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Netrc looks up http(s) repositories in a .netrc file, by host name. An empty path means $NETRC,
+// or ~/.netrc. A missing file has no credentials.
+func Netrc(path string) api.CredentialResolver {
+	return api.CredentialResolverFunc(func(_ context.Context, repoUrl string, _ api.Access) (transport.AuthMethod, error) {
+		ep := endpoint(repoUrl)
+		if !isHttp(ep) {
+			return nil, nil
+		}
+		file, err := netrcPath(path)
+		if err != nil {
+			return nil, err
+		}
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		entries := parseNetrc(string(contents))
+		entry, ok := entries[ep.Host]
+		if !ok {
+			entry, ok = entries[""]
+		}
+		if !ok || entry.password == "" {
+			return nil, nil
+		}
+		return &githttp.BasicAuth{Username: entry.login, Password: entry.password}, nil
+	})
+}
+
+func netrcPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	if env := os.Getenv("NETRC"); env != "" {
+		return env, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+type netrcEntry struct {
+	login    string
+	password string
+}
+
+// parseNetrc returns the entries by machine, with the default entry under "". Macros are skipped.
+func parseNetrc(contents string) map[string]netrcEntry {
+	entries := make(map[string]netrcEntry)
+	var machine *string
+	var current netrcEntry
+	flush := func() {
+		if machine != nil {
+			entries[*machine] = current
+		}
+		machine, current = nil, netrcEntry{}
+	}
+
+	lines := strings.Split(contents, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			next := func() string {
+				j++
+				if j < len(fields) {
+					return fields[j]
+				}
+				return ""
+			}
+			switch fields[j] {
+			case "machine":
+				flush()
+				name := next()
+				machine = &name
+			case "default":
+				flush()
+				name := ""
+				machine = &name
+			case "login":
+				current.login = next()
+			case "password":
+				current.password = next()
+			case "account":
+				next()
+			case "macdef":
+				// a macro runs until the next empty line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	flush()
+	return entries
+}
@@ -0,0 +1,42 @@
+package credentials
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"os"
+)
+
+const defaultSshUser = "git"
+
+// SSHAgent uses the keys of the ssh agent at $SSH_AUTH_SOCK for ssh repositories. Without an agent,
+// there are no credentials.
+func SSHAgent() api.CredentialResolver {
+	return api.CredentialResolverFunc(func(_ context.Context, repoUrl string, _ api.Access) (transport.AuthMethod, error) {
+		ep := endpoint(repoUrl)
+		if !isSsh(ep) || os.Getenv("SSH_AUTH_SOCK") == "" {
+			return nil, nil
+		}
+		return gitssh.NewSSHAgentAuth(sshUser(ep))
+	})
+}
+
+// SSHKeyFile uses the private key in a file for ssh repositories. passphrase is needed for encrypted keys.
+func SSHKeyFile(path string, passphrase string) api.CredentialResolver {
+	return api.CredentialResolverFunc(func(_ context.Context, repoUrl string, _ api.Access) (transport.AuthMethod, error) {
+		ep := endpoint(repoUrl)
+		if !isSsh(ep) {
+			return nil, nil
+		}
+		return gitssh.NewPublicKeysFromFile(sshUser(ep), path, passphrase)
+	})
+}
+
+// sshUser is the user of the url, as in git@github.com:org/repo.git, or 'git'.
+func sshUser(ep *transport.Endpoint) string {
+	if ep.User != "" {
+		return ep.User
+	}
+	return defaultSshUser
+}
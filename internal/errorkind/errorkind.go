@@ -0,0 +1,151 @@
+package errorkind
+
+import (
+	"context"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+var kinds = []error{
+	api.ErrWrongOrder,
+	api.ErrAuthentication,
+	api.ErrRepositoryNotFound,
+	api.ErrBranchNotFound,
+	api.ErrPushRejected,
+	api.ErrInvalidParameters,
+	api.ErrRenderFailed,
+	api.ErrUnexpectedChanges,
+	api.ErrHookVeto,
+	api.ErrHostKeyRejected,
+}
+
+// Of returns the api.Err... kind of an error, recognizing the errors of go-git, or nil if none fits.
+func Of(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, kind := range kinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	switch {
+	// the ssh client only keeps the message of the host key callback
+	case strings.Contains(err.Error(), api.ErrHostKeyRejected.Error()):
+		return api.ErrHostKeyRejected
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod):
+		return api.ErrAuthentication
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		return api.ErrRepositoryNotFound
+	case errors.Is(err, plumbing.ErrReferenceNotFound), errors.Is(err, git.NoMatchingRefSpecError{}):
+		return api.ErrBranchNotFound
+	case errors.Is(err, git.ErrNonFastForwardUpdate), pushRejected(err.Error()):
+		return api.ErrPushRejected
+	}
+	return nil
+}
+
+// pushRejectedMessages are how refused ref updates are reported: go-git turns the status the server
+// sends for each refused ref into "command error on <ref>: <reason>", git prints "! [rejected]".
+var pushRejectedMessages = []string{
+	"command error on refs/",
+	"! [rejected]",
+	"non-fast-forward",
+}
+
+func pushRejected(message string) bool {
+	for _, m := range pushRejectedMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap turns err into an *api.Error for the phase and repository, unless it is nil. Errors that
+// already are one only get the phase and repository filled in if they are missing.
+func Wrap(phase string, repoUrl string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Phase == "" {
+			apiErr.Phase = phase
+		}
+		if apiErr.Repo == "" {
+			apiErr.Repo = repoUrl
+		}
+		return err
+	}
+	return &api.Error{Phase: phase, Repo: repoUrl, Kind: Of(err), Err: err}
+}
+
+var transientStatus = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+var transientMessages = []string{
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"unexpected EOF",
+	"i/o timeout",
+	"TLS handshake timeout",
+}
+
+// Transient is true for errors that may go away when the operation is tried again: dropped or refused
+// connections, network timeouts, and 429, 502, 503 or 504 from an http git server. Errors of a known
+// kind, such as failed authentication, and a cancelled or expired context never are.
+func Transient(err error) bool {
+	if err == nil || Of(err) != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// go-git wraps http status errors without Unwrap
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		var statusErr *githttp.Err
+		if errors.As(unexpected.Err, &statusErr) {
+			return statusErr.Response != nil && transientStatus[statusErr.Response.StatusCode]
+		}
+	}
+
+	// before *net.OpError, because dialing an unknown host is an *net.OpError wrapping a *net.DNSError
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	// the ssh transport and git servers often only leave the message
+	for _, message := range transientMessages {
+		if strings.Contains(err.Error(), message) {
+			return true
+		}
+	}
+	return false
+}
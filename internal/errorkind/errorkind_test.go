@@ -0,0 +1,88 @@
+package errorkind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	require.Nil(t, Of(nil))
+	require.Nil(t, Of(errors.New("something")))
+	require.Equal(t, api.ErrAuthentication, Of(transport.ErrAuthenticationRequired))
+	require.Equal(t, api.ErrAuthentication, Of(fmt.Errorf("clone: %w", transport.ErrAuthorizationFailed)))
+	require.Equal(t, api.ErrRepositoryNotFound, Of(transport.ErrRepositoryNotFound))
+	require.Equal(t, api.ErrBranchNotFound, Of(plumbing.ErrReferenceNotFound))
+	require.Equal(t, api.ErrPushRejected, Of(git.ErrNonFastForwardUpdate))
+	require.Equal(t, api.ErrPushRejected, Of(errors.New("command error on refs/heads/main: protected branch hook declined")))
+	require.Equal(t, api.ErrPushRejected, Of(errors.New(" ! [rejected]        main -> main (fetch first)")))
+	require.Nil(t, Of(errors.New("the request was rejected by the proxy")))
+	require.Equal(t, api.ErrRenderFailed, Of(fmt.Errorf("rendering failed: %w", api.ErrRenderFailed)))
+	require.Equal(t, api.ErrHostKeyRejected, Of(errors.New("ssh: handshake failed: host key rejected: example.com is not known")))
+}
+
+func TestWrap(t *testing.T) {
+	require.Nil(t, Wrap(api.PhaseCloneSource, "https://example.com/repo", nil))
+
+	err := Wrap(api.PhaseCloneSource, "https://example.com/repo", transport.ErrAuthenticationRequired)
+	require.Equal(t, &api.Error{Phase: api.PhaseCloneSource, Repo: "https://example.com/repo",
+		Kind: api.ErrAuthentication, Err: transport.ErrAuthenticationRequired}, err)
+	require.ErrorIs(t, err, api.ErrAuthentication)
+	require.ErrorIs(t, err, transport.ErrAuthenticationRequired)
+	require.Equal(t, transport.ErrAuthenticationRequired.Error(), err.Error())
+
+	veto := &api.Error{Kind: api.ErrHookVeto, Err: errors.New("after render hook: no")}
+	err = Wrap(api.PhaseGenerate, "https://example.com/generator", veto)
+	require.Same(t, veto, err)
+	require.Equal(t, api.PhaseGenerate, veto.Phase)
+	require.Equal(t, "https://example.com/generator", veto.Repo)
+}
+
+func TestTransient(t *testing.T) {
+	require.True(t, Transient(io.ErrUnexpectedEOF))
+	require.True(t, Transient(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}))
+	require.True(t, Transient(errors.New("ssh: handshake failed: read tcp: connection reset by peer")))
+	require.True(t, Transient(plumbing.NewUnexpectedError(&githttp.Err{Response: statusResponse(http.StatusServiceUnavailable)})))
+
+	require.False(t, Transient(nil))
+	require.False(t, Transient(plumbing.NewUnexpectedError(&githttp.Err{Response: statusResponse(http.StatusInternalServerError)})))
+	require.False(t, Transient(transport.ErrAuthenticationRequired))
+	require.False(t, Transient(transport.ErrRepositoryNotFound))
+	require.False(t, Transient(git.ErrNonFastForwardUpdate))
+	require.False(t, Transient(fmt.Errorf("clone: %w", context.Canceled)))
+	require.False(t, Transient(errors.New("something")))
+}
+
+func TestTransient_UnknownHost(t *testing.T) {
+	dial := func(dnsErr *net.DNSError) error {
+		return &url.Error{Op: "Get", URL: "https://nosuchhost.invalid/repo.git/info/refs",
+			Err: &net.OpError{Op: "dial", Net: "tcp", Err: dnsErr}}
+	}
+	require.False(t, Transient(dial(&net.DNSError{Err: "no such host", Name: "nosuchhost.invalid", IsNotFound: true})))
+	require.True(t, Transient(dial(&net.DNSError{Err: "server misbehaving", Name: "nosuchhost.invalid", IsTemporary: true})))
+	require.True(t, Transient(dial(&net.DNSError{Err: "i/o timeout", Name: "nosuchhost.invalid", IsTimeout: true})))
+
+	_, err := net.Dial("tcp", "nosuchhost.invalid:22")
+	require.NotNil(t, err)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		require.False(t, Transient(err))
+	}
+}
+
+func statusResponse(status int) *http.Response {
+	request, _ := http.NewRequest(http.MethodGet, "https://example.com/repo.git/info/refs", nil)
+	return &http.Response{StatusCode: status, Status: http.StatusText(status), Request: request}
+}
@@ -0,0 +1,216 @@
+package hostkeys
+
+import (
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const fingerprintPrefix = "SHA256:"
+
+// Verifier checks the host keys of ssh servers as described by api.HostKeyVerification.
+type Verifier struct {
+	known    ssh.HostKeyCallback
+	pins     []pin
+	tofuPath string
+}
+
+type pin struct {
+	pattern      string
+	fingerprints []string
+}
+
+// guards the trust on first use files of all instances, so concurrent sessions do not add a host twice
+var tofuMu sync.Mutex
+
+// New validates the settings and reads the known_hosts files. Returns nil for nil settings.
+func New(settings *api.HostKeyVerification) (*Verifier, error) {
+	if settings == nil {
+		return nil, nil
+	}
+	v := &Verifier{tofuPath: settings.TrustOnFirstUse}
+
+	files := append([]string{}, settings.KnownHostsFiles...)
+	if len(settings.KnownHosts) > 0 {
+		// knownhosts only reads files, but it reads them right away
+		file, err := os.CreateTemp("", "known_hosts")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(file.Name())
+		_, err = file.Write(settings.KnownHosts)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file.Name())
+	}
+	if len(files) > 0 {
+		known, err := knownhosts.New(files...)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read known hosts: %w", err)
+		}
+		v.known = known
+	}
+
+	for _, pattern := range sortedPatterns(settings.Fingerprints) {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("invalid host pattern '%s' for fingerprints", pattern)
+		}
+		p := pin{pattern: pattern}
+		for _, fingerprint := range settings.Fingerprints[pattern] {
+			if !strings.HasPrefix(fingerprint, fingerprintPrefix) {
+				fingerprint = fingerprintPrefix + fingerprint
+			}
+			p.fingerprints = append(p.fingerprints, strings.TrimRight(fingerprint, "="))
+		}
+		v.pins = append(v.pins, p)
+	}
+
+	if v.tofuPath != "" {
+		file, err := os.OpenFile(v.tofuPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open trust on first use store: %w", err)
+		}
+		_ = file.Close()
+	}
+	return v, nil
+}
+
+// Apply returns a copy of auth that verifies host keys with v, or auth itself if it is not for ssh
+// or v is nil.
+func (v *Verifier) Apply(auth transport.AuthMethod) transport.AuthMethod {
+	if v == nil {
+		return auth
+	}
+	switch a := auth.(type) {
+	case *gitssh.PublicKeys:
+		c := *a
+		c.HostKeyCallback = v.Callback()
+		return &c
+	case *gitssh.PublicKeysCallback:
+		c := *a
+		c.HostKeyCallback = v.Callback()
+		return &c
+	case *gitssh.Password:
+		c := *a
+		c.HostKeyCallback = v.Callback()
+		return &c
+	case *gitssh.PasswordCallback:
+		c := *a
+		c.HostKeyCallback = v.Callback()
+		return &c
+	case *gitssh.KeyboardInteractive:
+		c := *a
+		c.HostKeyCallback = v.Callback()
+		return &c
+	}
+	return auth
+}
+
+// Callback is the ssh.HostKeyCallback for v.
+func (v *Verifier) Callback() ssh.HostKeyCallback {
+	return v.verify
+}
+
+// verify is called by the ssh client with hostname as host:port.
+func (v *Verifier) verify(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	host := hostname
+	if h, _, err := net.SplitHostPort(hostname); err == nil {
+		host = h
+	}
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	if pinned, ok := v.pinned(host); ok {
+		for _, f := range pinned {
+			if f == fingerprint {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s presented %s, which is not a pinned fingerprint", api.ErrHostKeyRejected, host, fingerprint)
+	}
+	if v.known != nil {
+		err := v.known(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		if !unknown(err) {
+			return fmt.Errorf("%w: %s presented %s, which does not match known hosts: %v", api.ErrHostKeyRejected, host, fingerprint, err)
+		}
+	}
+	if v.tofuPath != "" {
+		return v.trustOnFirstUse(hostname, host, remote, key)
+	}
+	return fmt.Errorf("%w: %s is not a known host", api.ErrHostKeyRejected, host)
+}
+
+// pinned returns the fingerprints of the first pattern that matches host.
+func (v *Verifier) pinned(host string) ([]string, bool) {
+	for _, p := range v.pins {
+		if matched, _ := path.Match(p.pattern, host); matched {
+			return p.fingerprints, true
+		}
+	}
+	return nil, false
+}
+
+func (v *Verifier) trustOnFirstUse(hostname string, host string, remote net.Addr, key ssh.PublicKey) error {
+	tofuMu.Lock()
+	defer tofuMu.Unlock()
+
+	// read again, other instances may have added the host meanwhile
+	known, err := knownhosts.New(v.tofuPath)
+	if err != nil {
+		return fmt.Errorf("%w: cannot read trust on first use store: %v", api.ErrHostKeyRejected, err)
+	}
+	if err := known(hostname, remote, key); err == nil {
+		return nil
+	} else if !unknown(err) {
+		return fmt.Errorf("%w: %s presented %s, which does not match the key trusted on first use: %v", api.ErrHostKeyRejected, host, ssh.FingerprintSHA256(key), err)
+	}
+
+	file, err := os.OpenFile(v.tofuPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("%w: cannot write trust on first use store: %v", api.ErrHostKeyRejected, err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+		return fmt.Errorf("%w: cannot write trust on first use store: %v", api.ErrHostKeyRejected, err)
+	}
+	aulogging.Logger.NoCtx().Info().Printf("trusting host key %s of %s on first use", ssh.FingerprintSHA256(key), host)
+	return nil
+}
+
+// unknown is true if knownhosts has no key for the host, rather than a different or revoked one.
+func unknown(err error) bool {
+	var keyErr *knownhosts.KeyError
+	return errors.As(err, &keyErr) && len(keyErr.Want) == 0
+}
+
+func sortedPatterns(fingerprints map[string][]string) []string {
+	patterns := make([]string, 0, len(fingerprints))
+	for pattern := range fingerprints {
+		patterns = append(patterns, pattern)
+	}
+	// more specific patterns first, so '*' can be a fallback for everything else
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	return patterns
+}
@@ -0,0 +1,100 @@
+package hostkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var remote = &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+
+func newKey(t *testing.T) ssh.PublicKey {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	key, err := ssh.NewPublicKey(public)
+	require.Nil(t, err)
+	return key
+}
+
+func TestVerify_KnownHosts(t *testing.T) {
+	known, other := newKey(t), newKey(t)
+	v, err := New(&api.HostKeyVerification{KnownHosts: []byte(knownhosts.Line([]string{"git.example.com"}, known) + "\n")})
+	require.Nil(t, err)
+
+	require.Nil(t, v.Callback()("git.example.com:22", remote, known))
+	require.ErrorIs(t, v.Callback()("git.example.com:22", remote, other), api.ErrHostKeyRejected)
+	err = v.Callback()("git.other.com:22", remote, known)
+	require.ErrorIs(t, err, api.ErrHostKeyRejected)
+	require.Contains(t, err.Error(), "git.other.com is not a known host")
+}
+
+func TestVerify_PinnedFingerprints(t *testing.T) {
+	pinned, other := newKey(t), newKey(t)
+	v, err := New(&api.HostKeyVerification{
+		KnownHosts: []byte(knownhosts.Line([]string{"git.example.com"}, other) + "\n"),
+		Fingerprints: map[string][]string{
+			"*.example.com": {strings.TrimPrefix(ssh.FingerprintSHA256(pinned), "SHA256:")},
+			"*":             {ssh.FingerprintSHA256(other)},
+		},
+	})
+	require.Nil(t, err)
+
+	require.Nil(t, v.Callback()("git.example.com:22", remote, pinned))
+	require.Nil(t, v.Callback()("github.com:22", remote, other))
+	// pinned hosts only accept their fingerprints, whatever known_hosts says
+	require.ErrorIs(t, v.Callback()("git.example.com:22", remote, other), api.ErrHostKeyRejected)
+}
+
+func TestVerify_TrustOnFirstUse(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "known_hosts")
+	first, second := newKey(t), newKey(t)
+	v, err := New(&api.HostKeyVerification{TrustOnFirstUse: store})
+	require.Nil(t, err)
+
+	require.Nil(t, v.Callback()("git.example.com:2222", remote, first))
+	require.Nil(t, v.Callback()("git.example.com:2222", remote, first))
+	require.ErrorIs(t, v.Callback()("git.example.com:2222", remote, second), api.ErrHostKeyRejected)
+
+	contents, err := os.ReadFile(store)
+	require.Nil(t, err)
+	require.Equal(t, knownhosts.Line([]string{"[git.example.com]:2222"}, first)+"\n", string(contents))
+
+	// a fresh verifier sees what was stored
+	v, err = New(&api.HostKeyVerification{TrustOnFirstUse: store})
+	require.Nil(t, err)
+	require.ErrorIs(t, v.Callback()("git.example.com:2222", remote, second), api.ErrHostKeyRejected)
+}
+
+func TestApply(t *testing.T) {
+	var nilVerifier *Verifier
+	auth := &gitssh.PublicKeys{User: "git"}
+	require.Same(t, auth, nilVerifier.Apply(auth))
+
+	v, err := New(&api.HostKeyVerification{})
+	require.Nil(t, err)
+	applied := v.Apply(auth).(*gitssh.PublicKeys)
+	require.NotNil(t, applied.HostKeyCallback)
+	require.Nil(t, auth.HostKeyCallback)
+	require.Equal(t, "git", applied.User)
+
+	basic := &githttp.BasicAuth{Username: "x"}
+	require.Same(t, basic, v.Apply(basic))
+	require.Nil(t, v.Apply(nil))
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(&api.HostKeyVerification{KnownHostsFiles: []string{filepath.Join(t.TempDir(), "missing")}})
+	require.NotNil(t, err)
+	_, err = New(&api.HostKeyVerification{Fingerprints: map[string][]string{"[": {"SHA256:x"}}})
+	require.NotNil(t, err)
+}
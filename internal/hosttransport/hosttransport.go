@@ -0,0 +1,170 @@
+package hosttransport
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+)
+
+// Config holds the http transports for the hosts of one instance.
+//
+// go-git only allows replacing the http client for the whole process, so the first call of New installs
+// a client for http and https that looks up the Config in the context of each session. Sessions without
+// a Config, or for a host it has no transport for, go through the client that was installed before, so
+// a client the application registered with client.InstallProtocol keeps working for everything else.
+//
+// Transports are shared by all Configs with the same settings, so their idle connections are reused
+// rather than piling up with every Config.
+type Config struct {
+	hosts []host
+}
+
+type host struct {
+	pattern   string
+	transport *http.Transport
+}
+
+type configKey struct{}
+
+var (
+	installOnce sync.Once
+
+	transportsMu sync.Mutex
+	// by cacheKey
+	transports = map[string]*http.Transport{}
+)
+
+// New validates the settings and builds a transport for each of them. Returns nil for no settings.
+func New(settings []api.HostTransport) (*Config, error) {
+	if len(settings) == 0 {
+		return nil, nil
+	}
+	c := &Config{}
+	for i, s := range settings {
+		t, err := cached(s)
+		if err != nil {
+			return nil, fmt.Errorf("transport settings %d (%s): %w", i+1, s.Host, err)
+		}
+		c.hosts = append(c.hosts, host{pattern: s.Host, transport: t})
+	}
+	installOnce.Do(install)
+	return c, nil
+}
+
+// WithConfig has clones and pushes that use ctx go through the transports of c.
+func WithConfig(ctx context.Context, c *Config) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, configKey{}, c)
+}
+
+// transportFor returns the transport of the first host pattern that matches hostname, or nil.
+func (c *Config) transportFor(hostname string) *http.Transport {
+	for _, h := range c.hosts {
+		if matched, _ := path.Match(h.pattern, hostname); matched {
+			return h.transport
+		}
+	}
+	return nil
+}
+
+// cached validates the settings and returns their transport, building it if there is none yet.
+func cached(s api.HostTransport) (*http.Transport, error) {
+	if _, err := path.Match(s.Host, ""); err != nil || s.Host == "" {
+		return nil, fmt.Errorf("invalid host pattern '%s'", s.Host)
+	}
+	key := cacheKey(s)
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t, ok := transports[key]; ok {
+		return t, nil
+	}
+	t, err := build(s)
+	if err != nil {
+		return nil, err
+	}
+	transports[key] = t
+	return t, nil
+}
+
+// cacheKey identifies the settings a transport is built from, which are all but the host pattern.
+func cacheKey(s api.HostTransport) string {
+	h := sha256.New()
+	for _, field := range [][]byte{s.CABundle, s.ClientCertificate, s.ClientKey, []byte(s.Proxy)} {
+		_ = binary.Write(h, binary.BigEndian, uint64(len(field)))
+		h.Write(field)
+	}
+	if s.InsecureSkipVerify {
+		h.Write([]byte{1})
+	}
+	return string(h.Sum(nil))
+}
+
+func build(s api.HostTransport) (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.InsecureSkipVerify}
+	if len(s.CABundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(s.CABundle) {
+			return nil, errors.New("the CA bundle contains no PEM encoded certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(s.ClientCertificate) > 0 || len(s.ClientKey) > 0 {
+		certificate, err := tls.X509KeyPair(s.ClientCertificate, s.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsConfig
+	if s.Proxy != "" {
+		proxy, err := url.Parse(s.Proxy)
+		if err != nil || proxy.Scheme == "" || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url '%s'", s.Proxy)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	return t, nil
+}
+
+// install wraps the go-git clients for http and https. It runs once, as go-git reads its clients
+// without locking.
+func install() {
+	ours := githttp.NewClient(&http.Client{Transport: router{}})
+	for _, scheme := range []string{"http", "https"} {
+		previous := client.Protocols[scheme]
+		if previous == nil {
+			previous = githttp.DefaultClient
+		}
+		client.InstallProtocol(scheme, &protocol{ours: ours, previous: previous})
+	}
+}
+
+// router sends each request through the transport the Config in its context has for the host.
+type router struct{}
+
+func (router) RoundTrip(req *http.Request) (*http.Response, error) {
+	if c, ok := req.Context().Value(configKey{}).(*Config); ok {
+		if t := c.transportFor(req.URL.Hostname()); t != nil {
+			return t.RoundTrip(req)
+		}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
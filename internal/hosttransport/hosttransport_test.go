@@ -0,0 +1,150 @@
+package hosttransport
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func get(t *testing.T, ctx context.Context, url string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.Nil(t, err)
+	response, err := (&http.Client{Transport: router{}}).Do(request)
+	if err == nil {
+		response.Body.Close()
+	}
+	return response, err
+}
+
+func TestRouter_TransportOfFirstMatchingHost(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	_, err := get(t, context.TODO(), server.URL)
+	require.ErrorContains(t, err, "certificate")
+
+	for name, settings := range map[string][]api.HostTransport{
+		"ca bundle": {{Host: "127.0.0.1", CABundle: serverCert}},
+		"insecure":  {{Host: "*.example.com"}, {Host: "*", InsecureSkipVerify: true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := New(settings)
+			require.Nil(t, err)
+			response, err := get(t, WithConfig(context.TODO(), config), server.URL)
+			require.Nil(t, err)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+		})
+	}
+
+	config, err := New([]api.HostTransport{{Host: "127.0.0.1"}, {Host: "*", InsecureSkipVerify: true}})
+	require.Nil(t, err)
+	_, err = get(t, WithConfig(context.TODO(), config), server.URL)
+	require.ErrorContains(t, err, "certificate")
+}
+
+func TestRouter_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+
+	config, err := New([]api.HostTransport{{Host: "git.corp.example.com", Proxy: proxy.URL}})
+	require.Nil(t, err)
+	response, err := get(t, WithConfig(context.TODO(), config), "http://git.corp.example.com/org/repo.git/info/refs")
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, []string{"http://git.corp.example.com/org/repo.git/info/refs"}, proxied)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, settings := range []api.HostTransport{
+		{Host: ""},
+		{Host: "[example.com"},
+		{Host: "*", CABundle: []byte("no certificates")},
+		{Host: "*", ClientCertificate: []byte("no certificate")},
+		{Host: "*", Proxy: "proxy:3128"},
+	} {
+		_, err := New([]api.HostTransport{settings})
+		require.NotNil(t, err, "%+v", settings)
+	}
+
+	config, err := New(nil)
+	require.Nil(t, err)
+	require.Nil(t, config)
+}
+
+// recordingClient stands in for a go-git client the application installed, and refuses every session.
+type recordingClient struct {
+	hosts []string
+}
+
+var errRecorded = errors.New("recorded")
+
+func (r *recordingClient) NewUploadPackSession(ep *transport.Endpoint, _ transport.AuthMethod) (transport.UploadPackSession, error) {
+	r.hosts = append(r.hosts, ep.Host)
+	return nil, errRecorded
+}
+
+func (r *recordingClient) NewReceivePackSession(ep *transport.Endpoint, _ transport.AuthMethod) (transport.ReceivePackSession, error) {
+	r.hosts = append(r.hosts, ep.Host)
+	return nil, errRecorded
+}
+
+// installBefore installs c for https and then our client, as if c had been installed before the first
+// call of New. The clients of go-git are restored when the test ends.
+func installBefore(t *testing.T, c transport.Transport) {
+	previous := client.Protocols["https"]
+	t.Cleanup(func() {
+		client.InstallProtocol("https", previous)
+	})
+	client.InstallProtocol("https", c)
+	install()
+}
+
+func advertisedReferences(t *testing.T, ctx context.Context, url string) error {
+	ep, err := transport.NewEndpoint(url)
+	require.Nil(t, err)
+	c, err := client.NewClient(ep)
+	require.Nil(t, err)
+	session, err := c.NewUploadPackSession(ep, nil)
+	require.Nil(t, err)
+	defer session.Close()
+	_, err = session.AdvertisedReferencesContext(ctx)
+	return err
+}
+
+func TestInstall_PreviousClientHandlesEverythingElse(t *testing.T) {
+	previous := &recordingClient{}
+	installBefore(t, previous)
+
+	config, err := New([]api.HostTransport{{Host: "git.corp.example.com", Proxy: "http://127.0.0.1:1"}})
+	require.Nil(t, err)
+
+	err = advertisedReferences(t, context.TODO(), "https://git.corp.example.com/org/repo.git")
+	require.ErrorIs(t, err, errRecorded)
+	err = advertisedReferences(t, WithConfig(context.TODO(), config), "https://github.example.com/org/repo.git")
+	require.ErrorIs(t, err, errRecorded)
+	require.Equal(t, []string{"git.corp.example.com", "github.example.com"}, previous.hosts)
+
+	err = advertisedReferences(t, WithConfig(context.TODO(), config), "https://git.corp.example.com/org/repo.git")
+	require.NotErrorIs(t, err, errRecorded)
+	require.Len(t, previous.hosts, 2)
+}
+
+func TestNew_SharesTransportsOfTheSameSettings(t *testing.T) {
+	first, err := New([]api.HostTransport{{Host: "git.example.com", Proxy: "http://proxy.example.com:3128"}})
+	require.Nil(t, err)
+	second, err := New([]api.HostTransport{{Host: "*.example.com", Proxy: "http://proxy.example.com:3128"}, {Host: "*", InsecureSkipVerify: true}})
+	require.Nil(t, err)
+	require.Same(t, first.transportFor("git.example.com"), second.transportFor("git.example.com"))
+	require.NotSame(t, second.transportFor("git.example.com"), second.transportFor("other.test"))
+}
@@ -0,0 +1,122 @@
+package hosttransport
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// protocol is the go-git client installed for http and https. go-git passes the context only to the
+// calls of a session, so the client a session goes through is chosen when it is first used.
+type protocol struct {
+	// ours sends requests through the transports of the Config in their context.
+	ours transport.Transport
+	// previous is the client that was installed before.
+	previous transport.Transport
+}
+
+// client returns ours if ctx has a Config with a transport for the host of ep, otherwise previous.
+func (p *protocol) client(ctx context.Context, ep *transport.Endpoint) transport.Transport {
+	if c, ok := ctx.Value(configKey{}).(*Config); ok && c.transportFor(ep.Host) != nil {
+		return p.ours
+	}
+	return p.previous
+}
+
+func (p *protocol) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	return &uploadPackSession{protocol: p, ep: ep, auth: auth}, nil
+}
+
+func (p *protocol) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	return &receivePackSession{protocol: p, ep: ep, auth: auth}, nil
+}
+
+type uploadPackSession struct {
+	protocol *protocol
+	ep       *transport.Endpoint
+	auth     transport.AuthMethod
+	session  transport.UploadPackSession
+}
+
+func (s *uploadPackSession) open(ctx context.Context) (transport.UploadPackSession, error) {
+	if s.session == nil {
+		session, err := s.protocol.client(ctx, s.ep).NewUploadPackSession(s.ep, s.auth)
+		if err != nil {
+			return nil, err
+		}
+		s.session = session
+	}
+	return s.session, nil
+}
+
+func (s *uploadPackSession) AdvertisedReferences() (*packp.AdvRefs, error) {
+	return s.AdvertisedReferencesContext(context.Background())
+}
+
+func (s *uploadPackSession) AdvertisedReferencesContext(ctx context.Context) (*packp.AdvRefs, error) {
+	session, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return session.AdvertisedReferencesContext(ctx)
+}
+
+func (s *uploadPackSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (*packp.UploadPackResponse, error) {
+	session, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return session.UploadPack(ctx, req)
+}
+
+func (s *uploadPackSession) Close() error {
+	if s.session == nil {
+		return nil
+	}
+	return s.session.Close()
+}
+
+type receivePackSession struct {
+	protocol *protocol
+	ep       *transport.Endpoint
+	auth     transport.AuthMethod
+	session  transport.ReceivePackSession
+}
+
+func (s *receivePackSession) open(ctx context.Context) (transport.ReceivePackSession, error) {
+	if s.session == nil {
+		session, err := s.protocol.client(ctx, s.ep).NewReceivePackSession(s.ep, s.auth)
+		if err != nil {
+			return nil, err
+		}
+		s.session = session
+	}
+	return s.session, nil
+}
+
+func (s *receivePackSession) AdvertisedReferences() (*packp.AdvRefs, error) {
+	return s.AdvertisedReferencesContext(context.Background())
+}
+
+func (s *receivePackSession) AdvertisedReferencesContext(ctx context.Context) (*packp.AdvRefs, error) {
+	session, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return session.AdvertisedReferencesContext(ctx)
+}
+
+func (s *receivePackSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateRequest) (*packp.ReportStatus, error) {
+	session, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return session.ReceivePack(ctx, req)
+}
+
+func (s *receivePackSession) Close() error {
+	if s.session == nil {
+		return nil
+	}
+	return s.session.Close()
+}
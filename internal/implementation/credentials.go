@@ -0,0 +1,61 @@
+package implementation
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/hostkeys"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+)
+
+func (g *GitGeneratorImpl) SetCredentialResolver(resolver api.CredentialResolver) {
+	g.credentials = resolver
+}
+
+func (g *GitGeneratorImpl) SetHostKeyVerification(verification *api.HostKeyVerification) error {
+	verifier, err := hostkeys.New(verification)
+	if err != nil {
+		return err
+	}
+	g.hostKeys = verifier
+	return nil
+}
+
+// resolveAuth returns auth if the caller gave one, and otherwise asks the credential resolver, if any.
+// ssh auth methods get the host key verification of the instance.
+//
+// Clones of ssh urls without any auth use the ssh agent, see agentAuth. Writes stay without auth, as
+// no auth for the target means not to push.
+func (g *GitGeneratorImpl) resolveAuth(ctx context.Context, repoUrl string, access api.Access, auth transport.AuthMethod) (transport.AuthMethod, error) {
+	if auth == nil && g.credentials != nil && repoUrl != "" {
+		resolved, err := g.credentials.Resolve(ctx, repoUrl, access)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain %s credentials for %s: %w", access, telemetry.SafeUrl(repoUrl), err)
+		}
+		auth = resolved
+	}
+	if auth == nil && access == api.AccessRead {
+		return g.agentAuth(repoUrl)
+	}
+	return g.hostKeys.Apply(auth), nil
+}
+
+// agentAuth returns the ssh agent auth go-git falls back to for an ssh url without auth, with the host
+// key verification of the instance applied, which go-git would skip. Returns nil without host key
+// verification or for other urls, and an error if there is no ssh agent.
+func (g *GitGeneratorImpl) agentAuth(repoUrl string) (transport.AuthMethod, error) {
+	if g.hostKeys == nil {
+		return nil, nil
+	}
+	endpoint, err := transport.NewEndpoint(repoUrl)
+	if err != nil || endpoint.Protocol != "ssh" {
+		return nil, nil
+	}
+	auth, err := gitssh.NewSSHAgentAuth(endpoint.User)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify the host key of %s without auth: %w", telemetry.SafeUrl(repoUrl), err)
+	}
+	return g.hostKeys.Apply(auth), nil
+}
@@ -0,0 +1,170 @@
+package implementation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	generatorlib "github.com/StephanHCB/go-generator-lib"
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/repository/manifest"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+	"github.com/mplushnikov/go-generator-git/v2/internal/userregions"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// not a phase of Run, but traced like one
+const phaseDetectDrift = "detect-drift"
+
+func (g *GitGeneratorImpl) DetectDrift(ctx context.Context) (result *api.DriftResult, err error) {
+	ctx, phase := telemetry.StartPhase(ctx, phaseDetectDrift)
+	defer func() { err = g.endPhase(phase, phaseDetectDrift, g.targetUrl, err) }()
+	result = &api.DriftResult{}
+	if g.workdir == nil {
+		return result, errCreateWorkdirFirst(ctx)
+	}
+	if g.source == nil {
+		return result, errCloneSourceFirst(ctx)
+	}
+	if g.target == nil {
+		return result, errCloneTargetFirst(ctx)
+	}
+	if g.targetBranch == "" {
+		return result, errCloneTargetSuccessfullyFirst(ctx)
+	}
+
+	committed, err := g.target.ReadTree(ctx, g.target.HeadHash(ctx))
+	if err != nil {
+		return result, err
+	}
+	renderSpecFiles, err := g.findRenderSpecFiles(ctx, committed)
+	if err != nil {
+		return result, err
+	}
+	result.RenderSpecFiles = renderSpecFiles
+
+	scratchDir := filepath.Join(g.workdir.Path(ctx), "drift")
+	if err := os.RemoveAll(scratchDir); err != nil {
+		return result, err
+	}
+	defer func() {
+		_ = os.RemoveAll(scratchDir)
+	}()
+	for p, contents := range committed {
+		if err := writeFile(filepath.Join(scratchDir, filepath.FromSlash(p)), contents); err != nil {
+			return result, err
+		}
+	}
+
+	generated := make(map[string][]byte)
+	previouslyGenerated := make(map[string]bool)
+	for _, renderSpecFile := range renderSpecFiles {
+		previous, err := manifest.Instance(ctx, scratchDir, renderSpecFile).Read(ctx)
+		if err != nil {
+			return result, err
+		}
+		if previous != nil {
+			for _, entry := range previous.Files {
+				previouslyGenerated[path.Clean(filepath.ToSlash(entry.Path))] = true
+			}
+		}
+
+		response := g.render(ctx, "detect drift", &genlibapi.Request{
+			SourceBaseDir:  g.source.Path(),
+			TargetBaseDir:  scratchDir,
+			RenderSpecFile: renderSpecFile,
+		})
+		if !response.Success {
+			result.Errors = append(result.Errors, response.Errors...)
+			for _, file := range response.RenderedFiles {
+				result.Errors = append(result.Errors, file.Errors...)
+			}
+			return result, fmt.Errorf("rendering %s failed, see result for details: %w", renderSpecFile, api.ErrRenderFailed)
+		}
+		for _, file := range response.RenderedFiles {
+			p := path.Clean(filepath.ToSlash(file.RelativeFilePath))
+			contents, err := ioutil.ReadFile(filepath.Join(scratchDir, filepath.FromSlash(p)))
+			if err != nil {
+				return result, err
+			}
+			generated[p] = contents
+		}
+	}
+
+	for _, p := range sortedKeys(generated) {
+		current, exists := committed[p]
+		switch {
+		case !exists && previouslyGenerated[p]:
+			result.Missing = append(result.Missing, p)
+		case !exists:
+			result.Added = append(result.Added, p)
+		case bytes.Equal(current, g.withUserRegions(generated[p], current)):
+			result.Unchanged = append(result.Unchanged, p)
+		default:
+			result.Modified = append(result.Modified, p)
+		}
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("drift: %d modified, %d missing, %d added, %d unchanged",
+		len(result.Modified), len(result.Missing), len(result.Added), len(result.Unchanged))
+	return result, nil
+}
+
+// findRenderSpecFiles returns the yaml files in the tree that are render specs for a generator in the source.
+func (g *GitGeneratorImpl) findRenderSpecFiles(ctx context.Context, tree map[string][]byte) ([]string, error) {
+	generatorNames, err := generatorlib.FindGeneratorNames(ctx, g.source.Path())
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, name := range generatorNames {
+		known[name] = true
+	}
+
+	var renderSpecFiles []string
+	for _, p := range sortedKeys(tree) {
+		ext := path.Ext(p)
+		if (ext != ".yaml" && ext != ".yml") || manifest.IsManifest(p) {
+			continue
+		}
+		spec := &genlibapi.RenderSpec{}
+		if err := yaml.Unmarshal(tree[p], spec); err != nil {
+			continue
+		}
+		if known[spec.GeneratorName] {
+			renderSpecFiles = append(renderSpecFiles, p)
+		}
+	}
+	return renderSpecFiles, nil
+}
+
+// withUserRegions fills the user regions of a generated file from current, if user regions are preserved,
+// so they do not count as drift. Broken markers in either file do count as drift.
+func (g *GitGeneratorImpl) withUserRegions(generated []byte, current []byte) []byte {
+	if !g.userRegions || !userregions.Contains(current) {
+		return generated
+	}
+	regions, err := userregions.Extract(current)
+	if err != nil {
+		return generated
+	}
+	spliced, _, err := userregions.Splice(generated, regions)
+	if err != nil {
+		return generated
+	}
+	return spliced
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
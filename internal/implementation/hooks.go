@@ -0,0 +1,42 @@
+package implementation
+
+import (
+	"context"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+)
+
+func (g *GitGeneratorImpl) AddHooks(hooks api.Hooks) {
+	g.hooks = append(g.hooks, hooks)
+}
+
+// runHooks calls every registered hook until one vetoes.
+func (g *GitGeneratorImpl) runHooks(ctx context.Context, name string, call func(hooks api.Hooks, session api.HookSession) error) error {
+	if len(g.hooks) == 0 {
+		return nil
+	}
+	session := g.hookSession(ctx)
+	for _, hooks := range g.hooks {
+		if err := call(hooks, session); err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("%s hook vetoed", name)
+			return &api.Error{Kind: api.ErrHookVeto, Err: fmt.Errorf("%s hook: %w", name, err)}
+		}
+	}
+	return nil
+}
+
+func (g *GitGeneratorImpl) hookSession(_ context.Context) api.HookSession {
+	session := api.HookSession{
+		TargetBranch:   g.targetBranch,
+		Generator:      g.generatorName,
+		RenderSpecFile: g.renderSpecFile,
+	}
+	if g.source != nil {
+		session.SourcePath = g.source.Path()
+	}
+	if g.target != nil {
+		session.TargetPath = g.target.Path()
+	}
+	return session
+}
@@ -1,18 +1,28 @@
 package implementation
 
 import (
+	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	generatorlib "github.com/StephanHCB/go-generator-lib"
 	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/errorkind"
+	"github.com/mplushnikov/go-generator-git/v2/internal/hostkeys"
+	"github.com/mplushnikov/go-generator-git/v2/internal/hosttransport"
+	"github.com/mplushnikov/go-generator-git/v2/internal/progress"
 	"github.com/mplushnikov/go-generator-git/v2/internal/repository/gitsourcerepo"
 	"github.com/mplushnikov/go-generator-git/v2/internal/repository/gittargetrepo"
 	"github.com/mplushnikov/go-generator-git/v2/internal/repository/tmpdir"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+	"github.com/mplushnikov/go-generator-git/v2/internal/urlrewrite"
+	"github.com/mplushnikov/go-generator-git/v2/internal/userregions"
+	"io"
 	"path/filepath"
+	"text/template"
 )
 
 type GitGeneratorImpl struct {
@@ -21,6 +31,31 @@ type GitGeneratorImpl struct {
 	target         *gittargetrepo.GitTargetRepo
 	targetBranch   string
 	renderSpecFile string
+	generatorName  string
+	sourceUrl      string
+	targetUrl      string
+	pushPolicy     api.PushPolicy
+	commitTag      *api.TagSpec
+	stagingMode    api.StagingMode
+	pruneOrphans   bool
+	userRegions    bool
+	updateStrategy api.UpdateStrategy
+	pristineBranch api.PristineBranchSpec
+	renderedFiles  []string
+	prunedFiles    []string
+	manifestFile   string
+	progress       api.ProgressSink
+	retryPolicy    api.RetryPolicy
+	credentials    api.CredentialResolver
+	transport      *hosttransport.Config
+	hostKeys       *hostkeys.Verifier
+	hooks          []api.Hooks
+
+	// see SetUrlRewriting, and the urls as they were given before rewriting
+	urlRules           *urlrewrite.Rules
+	sourceOriginalUrl  string
+	targetOriginalUrl  string
+	mirrorOriginalUrls map[string]string
 }
 
 type GitApiRepoImpl struct {
@@ -37,7 +72,12 @@ func (g *GitGeneratorImpl) CreateTemporaryWorkdir(ctx context.Context, basePath
 	return g.workdir.Create(ctx)
 }
 
-func (g *GitGeneratorImpl) CloneSourceRepo(ctx context.Context, gitRepoUrl string, gitBranch string, auth transport.AuthMethod) (api.GitApiRepo, error) {
+func (g *GitGeneratorImpl) CloneSourceRepo(ctx context.Context, gitRepoUrl string, gitBranch string, auth transport.AuthMethod) (repo api.GitApiRepo, err error) {
+	originalUrl := gitRepoUrl
+	gitRepoUrl = g.rewriteUrl(ctx, gitRepoUrl)
+	ctx, phase := telemetry.StartPhase(hosttransport.WithConfig(ctx, g.transport), api.PhaseCloneSource, telemetry.KeyRepoUrl.String(telemetry.SafeUrl(gitRepoUrl)), telemetry.KeyBranch.String(gitBranch))
+	defer func() { err = g.endPhase(phase, api.PhaseCloneSource, gitRepoUrl, err) }()
+
 	if g.workdir == nil {
 		return nil, errCreateWorkdirFirst(ctx)
 	}
@@ -47,14 +87,28 @@ func (g *GitGeneratorImpl) CloneSourceRepo(ctx context.Context, gitRepoUrl strin
 	path := filepath.Join(g.workdir.Path(ctx), "source")
 	aulogging.Logger.Ctx(ctx).Info().Printf("cloning source repo to %s", path)
 	g.source = gitsourcerepo.Instance(ctx, path)
-	if err := g.source.Clone(ctx, gitRepoUrl, gitBranch, auth); err != nil {
+	g.source.SetRetryPolicy(g.retryPolicy)
+	g.sourceUrl = gitRepoUrl
+	g.sourceOriginalUrl = originalUrl
+	auth, err = g.resolveAuth(ctx, gitRepoUrl, api.AccessRead, auth)
+	if err != nil {
+		return &GitApiRepoImpl{path}, err
+	}
+	if err := g.source.Clone(ctx, gitRepoUrl, gitBranch, auth, progress.Writer("clone source", g.progress)); err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("error cloning source repo from %s on branch %s", gitRepoUrl, gitBranch)
 		return &GitApiRepoImpl{path}, err
 	}
-	return &GitApiRepoImpl{path}, nil
+	return &GitApiRepoImpl{path}, g.runHooks(ctx, "after source clone", func(hooks api.Hooks, session api.HookSession) error {
+		return hooks.AfterSourceClone(ctx, session)
+	})
 }
 
-func (g *GitGeneratorImpl) PrepareTargetRepo(ctx context.Context, gitRepoUrl string, gitBranch string, auth transport.AuthMethod) (api.GitApiRepo, error) {
+func (g *GitGeneratorImpl) PrepareTargetRepo(ctx context.Context, gitRepoUrl string, gitBranch string, auth transport.AuthMethod) (repo api.GitApiRepo, err error) {
+	originalUrl := gitRepoUrl
+	gitRepoUrl = g.rewriteUrl(ctx, gitRepoUrl)
+	ctx, phase := telemetry.StartPhase(ctx, api.PhaseCloneTarget, telemetry.KeyRepoUrl.String(telemetry.SafeUrl(gitRepoUrl)), telemetry.KeyBranch.String(gitBranch))
+	defer func() { err = g.endPhase(phase, api.PhaseCloneTarget, gitRepoUrl, err) }()
+
 	if g.workdir == nil {
 		return nil, errCreateWorkdirFirst(ctx)
 	}
@@ -65,17 +119,42 @@ func (g *GitGeneratorImpl) PrepareTargetRepo(ctx context.Context, gitRepoUrl str
 	path := filepath.Join(g.workdir.Path(ctx), "target")
 
 	g.target = gittargetrepo.Instance(ctx, path)
-	err := g.target.PrepareInit(ctx, gitRepoUrl, gitBranch)
+	g.target.SetProgress(g.progressWriter)
+	g.target.SetRetryPolicy(g.retryPolicy)
+	g.targetUrl = gitRepoUrl
+	g.targetOriginalUrl = originalUrl
+	err = g.target.PrepareInit(ctx, gitRepoUrl, gitBranch)
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("error preparing target repo from %s", gitRepoUrl)
 		return &GitApiRepoImpl{path}, err
 	}
 	g.targetBranch = gitBranch
 
-	return &GitApiRepoImpl{path}, nil
+	return &GitApiRepoImpl{path}, g.afterTargetCheckout(ctx)
+}
+
+func (g *GitGeneratorImpl) CloneTargetRepo(ctx context.Context, gitRepoUrl string, gitBranch string, baseBranch string, auth transport.AuthMethod) (repo api.GitApiRepo, err error) {
+	originalUrl := gitRepoUrl
+	gitRepoUrl = g.rewriteUrl(ctx, gitRepoUrl)
+	ctx, phase := telemetry.StartPhase(ctx, api.PhaseCloneTarget, telemetry.KeyRepoUrl.String(telemetry.SafeUrl(gitRepoUrl)), telemetry.KeyBranch.String(gitBranch))
+	defer func() { err = g.endPhase(phase, api.PhaseCloneTarget, gitRepoUrl, err) }()
+
+	g.targetOriginalUrl = originalUrl
+	repo, err = g.cloneTargetRepo(ctx, gitRepoUrl, gitBranch, baseBranch, auth)
+	if err != nil || repo == nil {
+		return repo, err
+	}
+	return repo, g.afterTargetCheckout(ctx)
+}
+
+func (g *GitGeneratorImpl) afterTargetCheckout(ctx context.Context) error {
+	return g.runHooks(ctx, "after target checkout", func(hooks api.Hooks, session api.HookSession) error {
+		return hooks.AfterTargetCheckout(ctx, session)
+	})
 }
 
-func (g *GitGeneratorImpl) CloneTargetRepo(ctx context.Context, gitRepoUrl string, gitBranch string, baseBranch string, auth transport.AuthMethod) (api.GitApiRepo, error) {
+func (g *GitGeneratorImpl) cloneTargetRepo(ctx context.Context, gitRepoUrl string, gitBranch string, baseBranch string, auth transport.AuthMethod) (api.GitApiRepo, error) {
+	ctx = hosttransport.WithConfig(ctx, g.transport)
 	if g.workdir == nil {
 		return nil, errCreateWorkdirFirst(ctx)
 	}
@@ -88,6 +167,13 @@ func (g *GitGeneratorImpl) CloneTargetRepo(ctx context.Context, gitRepoUrl strin
 
 	aulogging.Logger.Ctx(ctx).Info().Printf("cloning target repo to %s", path)
 	g.target = gittargetrepo.Instance(ctx, path)
+	g.target.SetProgress(g.progressWriter)
+	g.target.SetRetryPolicy(g.retryPolicy)
+	g.targetUrl = gitRepoUrl
+	auth, err := g.resolveAuth(ctx, gitRepoUrl, api.AccessRead, auth)
+	if err != nil {
+		return localApiRepo, err
+	}
 	if err := g.target.Clone(ctx, gitRepoUrl, auth); err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("error cloning target repo from %s", gitRepoUrl)
 		return localApiRepo, err
@@ -117,9 +203,9 @@ func (g *GitGeneratorImpl) CloneTargetRepo(ctx context.Context, gitRepoUrl strin
 			// now the branch exists, verify and check it out
 			hash = g.target.GetHashForRevision(ctx, gitBranch)
 			if hash == nil {
-				message := "internal error - lookup of branch failed right after create"
-				aulogging.Logger.Ctx(ctx).Error().Print(message)
-				return localApiRepo, errors.New(message)
+				err := fmt.Errorf("internal error - lookup of branch %s failed right after create: %w", gitBranch, plumbing.ErrReferenceNotFound)
+				aulogging.Logger.Ctx(ctx).Error().Print(err.Error())
+				return localApiRepo, err
 			}
 
 			aulogging.Logger.Ctx(ctx).Info().Printf("now checking out %s (currently at %s)", gitBranch, hash.String())
@@ -132,14 +218,17 @@ func (g *GitGeneratorImpl) CloneTargetRepo(ctx context.Context, gitRepoUrl strin
 			g.targetBranch = gitBranch
 			return localApiRepo, nil
 		} else {
-			message := fmt.Sprintf("base branch %s does not exist", baseBranch)
-			aulogging.Logger.Ctx(ctx).Error().Print(message)
-			return localApiRepo, errors.New(message)
+			err := fmt.Errorf("base branch %s does not exist: %w", baseBranch, api.ErrBranchNotFound)
+			aulogging.Logger.Ctx(ctx).Error().Print(err.Error())
+			return localApiRepo, err
 		}
 	}
 }
 
-func (g *GitGeneratorImpl) WriteRenderSpecFile(ctx context.Context, generatorName string, renderSpecFile string, parameters map[string]interface{}) (*genlibapi.Response, error) {
+func (g *GitGeneratorImpl) WriteRenderSpecFile(ctx context.Context, generatorName string, renderSpecFile string, parameters map[string]interface{}) (response *genlibapi.Response, err error) {
+	ctx, phase := telemetry.StartPhase(ctx, api.PhaseWriteRenderSpec, telemetry.KeyGenerator.String(generatorName), telemetry.KeyRenderSpec.String(renderSpecFile))
+	defer func() { err = g.endPhase(phase, api.PhaseWriteRenderSpec, g.sourceUrl, err) }()
+
 	if g.workdir == nil {
 		return &genlibapi.Response{Success: false}, errCreateWorkdirFirst(ctx)
 	}
@@ -152,42 +241,146 @@ func (g *GitGeneratorImpl) WriteRenderSpecFile(ctx context.Context, generatorNam
 	if g.targetBranch == "" {
 		return &genlibapi.Response{Success: false}, errCloneTargetSuccessfullyFirst(ctx)
 	}
+	if err := ctx.Err(); err != nil {
+		return &genlibapi.Response{Success: false}, err
+	}
 
 	// set it for request() and remember it for Generate()
 	g.renderSpecFile = renderSpecFile
+	g.generatorName = generatorName
 
-	response := generatorlib.WriteRenderSpecWithValues(ctx, g.request(), generatorName, parameters)
+	response = generatorlib.WriteRenderSpecWithValues(ctx, g.request(), generatorName, parameters)
 	if !response.Success {
-		return response, errors.New("writing render spec file failed, see response for details")
+		return response, fmt.Errorf("writing render spec file failed, see response for details: %w", api.ErrInvalidParameters)
 	}
 	return response, nil
 }
 
+func (g *GitGeneratorImpl) SetUpdateStrategy(strategy api.UpdateStrategy) {
+	g.updateStrategy = strategy
+}
+
+func (g *GitGeneratorImpl) SetPristineBranch(spec api.PristineBranchSpec) {
+	g.pristineBranch = spec
+}
+
+func (g *GitGeneratorImpl) SetPruneOrphans(enabled bool) {
+	g.pruneOrphans = enabled
+}
+
+func (g *GitGeneratorImpl) SetPreserveUserRegions(enabled bool) {
+	g.userRegions = enabled
+}
+
 func (g *GitGeneratorImpl) Generate(ctx context.Context) (*genlibapi.Response, error) {
+	result, err := g.GenerateWithResult(ctx)
+	if result == nil {
+		return nil, err
+	}
+	return result.Response, err
+}
+
+func (g *GitGeneratorImpl) GenerateWithResult(ctx context.Context) (result *api.GenerateResult, err error) {
+	ctx, phase := telemetry.StartPhase(ctx, api.PhaseGenerate, telemetry.KeyGenerator.String(g.generatorName), telemetry.KeyRenderSpec.String(g.renderSpecFile))
+	defer func() { err = g.endPhase(phase, api.PhaseGenerate, g.sourceUrl, err) }()
+
 	if g.workdir == nil {
-		return &genlibapi.Response{Success: false}, errCreateWorkdirFirst(ctx)
+		return generateResult(&genlibapi.Response{Success: false}), errCreateWorkdirFirst(ctx)
 	}
 	if g.source == nil {
-		return &genlibapi.Response{Success: false}, errCloneSourceFirst(ctx)
+		return generateResult(&genlibapi.Response{Success: false}), errCloneSourceFirst(ctx)
 	}
 	if g.target == nil {
-		return &genlibapi.Response{Success: false}, errCloneTargetFirst(ctx)
+		return generateResult(&genlibapi.Response{Success: false}), errCloneTargetFirst(ctx)
 	}
 	if g.targetBranch == "" {
-		return &genlibapi.Response{Success: false}, errCloneTargetSuccessfullyFirst(ctx)
+		return generateResult(&genlibapi.Response{Success: false}), errCloneTargetSuccessfullyFirst(ctx)
 	}
 	if g.renderSpecFile == "" {
-		return &genlibapi.Response{Success: false}, errWriteRenderSpecFirst(ctx)
+		return generateResult(&genlibapi.Response{Success: false}), errWriteRenderSpecFirst(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return generateResult(&genlibapi.Response{Success: false}), err
+	}
+	if err := g.runHooks(ctx, "before render", func(hooks api.Hooks, session api.HookSession) error {
+		return hooks.BeforeRender(ctx, session)
+	}); err != nil {
+		return generateResult(&genlibapi.Response{Success: false}), err
 	}
 
-	response := generatorlib.Render(ctx, g.request())
-	if !response.Success {
-		return response, errors.New("rendering failed, see response for details")
+	var captured map[string][]userregions.Region
+	generatedDir := g.target.Path()
+	switch g.updateStrategy {
+	case api.UpdateMerge:
+		result, generatedDir, err = g.generateMerged(ctx)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Print("error merging generated files into the target")
+			return result, err
+		}
+	case api.UpdatePristineBranch:
+		result, err = g.generatePristine(ctx)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Print("error merging the pristine branch into the target")
+			return result, err
+		}
+	default:
+		if g.userRegions {
+			captured, err = g.captureUserRegions(ctx)
+			if err != nil {
+				aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Print("error capturing user regions from the target")
+				return generateResult(&genlibapi.Response{Success: false}), err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return generateResult(&genlibapi.Response{Success: false}), err
+		}
+		result = generateResult(g.render(ctx, "render", g.request()))
 	}
-	return response, nil
+
+	g.renderedFiles = nil
+	g.prunedFiles = nil
+	for _, file := range result.RenderedFiles {
+		if file.Success {
+			g.renderedFiles = append(g.renderedFiles, file.RelativeFilePath)
+		}
+	}
+	if !result.Success {
+		return result, fmt.Errorf("rendering failed, see response for details: %w", api.ErrRenderFailed)
+	}
+
+	// merging needs the manifest to find the previous generator version,
+	// while the pristine branch takes care of deleted files by itself
+	if g.updateStrategy != api.UpdatePristineBranch && (g.pruneOrphans || g.updateStrategy == api.UpdateMerge) {
+		if err := g.updateManifest(ctx, result, generatedDir); err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Print("error updating the manifest of generated files")
+			return result, err
+		}
+	}
+	g.prunedFiles = result.PrunedFiles
+
+	// after updating the manifest, so it records the pure generator output
+	if captured != nil {
+		if err := g.restoreUserRegions(ctx, captured, result); err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Print("error restoring user regions in the generated files")
+			return result, err
+		}
+	}
+	return result, g.runHooks(ctx, "after render", func(hooks api.Hooks, session api.HookSession) error {
+		return hooks.AfterRender(ctx, session, result)
+	})
 }
 
-func (g *GitGeneratorImpl) CommitAndPush(ctx context.Context, name string, email string, message string, auth transport.AuthMethod) error {
+func (g *GitGeneratorImpl) DiffTarget(ctx context.Context) ([]api.FileDiff, error) {
+	if g.target == nil {
+		return nil, errCloneTargetFirst(ctx)
+	}
+	if g.targetBranch == "" {
+		return nil, errCloneTargetSuccessfullyFirst(ctx)
+	}
+	return g.target.Changes(ctx)
+}
+
+func (g *GitGeneratorImpl) AddPushRemote(ctx context.Context, name string, gitRepoUrl string, auth transport.AuthMethod) error {
 	if g.workdir == nil {
 		return errCreateWorkdirFirst(ctx)
 	}
@@ -198,22 +391,162 @@ func (g *GitGeneratorImpl) CommitAndPush(ctx context.Context, name string, email
 		return errCloneTargetSuccessfullyFirst(ctx)
 	}
 
+	originalUrl := gitRepoUrl
+	gitRepoUrl = g.rewriteUrl(ctx, gitRepoUrl)
+	aulogging.Logger.Ctx(ctx).Info().Printf("adding push remote %s at %s", name, gitRepoUrl)
+	auth, err := g.resolveAuth(ctx, gitRepoUrl, api.AccessWrite, auth)
+	if err != nil {
+		return err
+	}
+	if auth == nil {
+		// mirrors are only added to be pushed to, where go-git would use the ssh agent
+		if auth, err = g.agentAuth(gitRepoUrl); err != nil {
+			return err
+		}
+	}
+	if err := g.target.AddMirrorRemote(ctx, name, gitRepoUrl, auth); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("error adding push remote %s", name)
+		return err
+	}
+	if g.mirrorOriginalUrls == nil {
+		g.mirrorOriginalUrls = make(map[string]string)
+	}
+	g.mirrorOriginalUrls[name] = originalUrl
+	return nil
+}
+
+func (g *GitGeneratorImpl) SetPushPolicy(policy api.PushPolicy) {
+	g.pushPolicy = policy
+}
+
+func (g *GitGeneratorImpl) SetStagingMode(mode api.StagingMode) {
+	g.stagingMode = mode
+}
+
+func (g *GitGeneratorImpl) SetCommitTag(tag *api.TagSpec) {
+	g.commitTag = tag
+}
+
+func (g *GitGeneratorImpl) SetProgressSink(sink api.ProgressSink) {
+	g.progress = sink
+}
+
+func (g *GitGeneratorImpl) SetRetryPolicy(policy api.RetryPolicy) {
+	g.retryPolicy = policy
+	if g.source != nil {
+		g.source.SetRetryPolicy(policy)
+	}
+	if g.target != nil {
+		g.target.SetRetryPolicy(policy)
+	}
+}
+
+func (g *GitGeneratorImpl) SetTransport(hosts []api.HostTransport) error {
+	config, err := hosttransport.New(hosts)
+	if err != nil {
+		return err
+	}
+	g.transport = config
+	return nil
+}
+
+func (g *GitGeneratorImpl) CommitAndPush(ctx context.Context, name string, email string, message string, auth transport.AuthMethod) error {
+	_, err := g.CommitAndPushWithResult(ctx, name, email, message, auth)
+	return err
+}
+
+func (g *GitGeneratorImpl) CommitAndPushWithResult(ctx context.Context, name string, email string, message string, auth transport.AuthMethod) (result *api.CommitResult, err error) {
+	ctx, phase := telemetry.StartPhase(hosttransport.WithConfig(ctx, g.transport), api.PhaseCommitAndPush, telemetry.KeyBranch.String(g.targetBranch))
+	defer func() { err = g.endPhase(phase, api.PhaseCommitAndPush, g.targetUrl, err) }()
+
+	if g.workdir == nil {
+		return &api.CommitResult{}, errCreateWorkdirFirst(ctx)
+	}
+	if g.target == nil {
+		return &api.CommitResult{}, errCloneTargetFirst(ctx)
+	}
+	if g.targetBranch == "" {
+		return &api.CommitResult{}, errCloneTargetSuccessfullyFirst(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return &api.CommitResult{}, err
+	}
+	if err := g.runHooks(ctx, "before commit", func(hooks api.Hooks, session api.HookSession) error {
+		return hooks.BeforeCommit(ctx, session)
+	}); err != nil {
+		return &api.CommitResult{}, err
+	}
+
+	if g.commitTag != nil {
+		tagName, err := g.tagName(ctx, g.commitTag.Name)
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("error evaluating tag name %s", g.commitTag.Name)
+			return &api.CommitResult{}, err
+		}
+		aulogging.Logger.Ctx(ctx).Info().Printf("will tag commit as %s", tagName)
+		if err := g.target.TagNextCommit(ctx, tagName, g.commitTag.Message, g.commitTag.SignKey, g.commitTag.Overwrite); err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("invalid tag %s", tagName)
+			return &api.CommitResult{}, err
+		}
+	}
+
+	if g.stagingMode != api.StageAll {
+		paths := append([]string{}, g.renderedFiles...)
+		paths = append(paths, g.prunedFiles...)
+		if g.renderSpecFile != "" {
+			paths = append(paths, g.renderSpecFile)
+		}
+		if g.manifestFile != "" {
+			paths = append(paths, g.manifestFile)
+		}
+		g.target.StageOnly(ctx, paths, g.stagingMode == api.StageGeneratedOnlyStrict)
+	}
+
+	auth, err = g.resolveAuth(ctx, g.targetUrl, api.AccessWrite, auth)
+	if err != nil {
+		return &api.CommitResult{}, err
+	}
 	if auth != nil {
 		g.target.EnablePush()
 		aulogging.Logger.Ctx(ctx).Info().Printf("committing and pushing")
 	} else {
 		aulogging.Logger.Ctx(ctx).Info().Printf("committing (but not pushing - no auth supplied)")
 	}
-	err := g.target.CommitAndPush(ctx, name, email, message, auth)
+	result, err = g.target.CommitAndPush(ctx, name, email, message, auth, g.pushPolicy)
+	result.PrunedFiles = g.prunedFiles
+	g.setOriginalUrls(result)
+	if result.CommitHash != "" {
+		phase.SetAttributes(telemetry.KeyCommitHash.String(result.CommitHash))
+		telemetry.RecordCommit(ctx, len(result.PushResults) > 0)
+	}
+	if result.CommitHash != "" {
+		hookErr := g.runHooks(ctx, "after push", func(hooks api.Hooks, session api.HookSession) error {
+			return hooks.AfterPush(ctx, session, result)
+		})
+		if err == nil {
+			err = hookErr
+		}
+	}
+	for _, ignoredPath := range result.IgnoredPaths {
+		aulogging.Logger.Ctx(ctx).Warn().Printf("not committing %s, it was not written by the generator", ignoredPath)
+	}
+	for _, pushResult := range result.PushResults {
+		if !pushResult.Success {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(pushResult.Err).Printf("error pushing to %s", pushResult.RemoteName)
+		}
+	}
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Print("error during commit or push")
-		return err
+		return result, err
 	}
 
-	return nil
+	return result, nil
 }
 
-func (g *GitGeneratorImpl) Cleanup(ctx context.Context) error {
+func (g *GitGeneratorImpl) Cleanup(ctx context.Context) (err error) {
+	ctx, phase := telemetry.StartPhase(ctx, api.PhaseCleanup)
+	defer func() { err = g.endPhase(phase, api.PhaseCleanup, "", err) }()
+
 	if g.workdir == nil {
 		aulogging.Logger.Ctx(ctx).Debug().Print("skipping cleanup of temporary working directory that was never created")
 		return nil
@@ -224,6 +557,10 @@ func (g *GitGeneratorImpl) Cleanup(ctx context.Context) error {
 
 // internals
 
+func generateResult(response *genlibapi.Response) *api.GenerateResult {
+	return &api.GenerateResult{Response: response}
+}
+
 func (g *GitGeneratorImpl) request() *genlibapi.Request {
 	return &genlibapi.Request{
 		SourceBaseDir:  g.source.Path(),
@@ -232,33 +569,87 @@ func (g *GitGeneratorImpl) request() *genlibapi.Request {
 	}
 }
 
+// render renders with go-generator-lib, which does it in one go, so progress is only reported before and after.
+func (g *GitGeneratorImpl) render(ctx context.Context, operation string, request *genlibapi.Request) *genlibapi.Response {
+	ctx, span := telemetry.Span(ctx, "render", telemetry.KeyOperation.String(operation))
+	progress.RenderStarted(g.progress, operation)
+	response := generatorlib.Render(ctx, request)
+	progress.RenderFinished(g.progress, operation, len(response.RenderedFiles))
+	span.SetAttributes(telemetry.KeyFilesCount.Int(len(response.RenderedFiles)))
+	var err error
+	if !response.Success {
+		err = api.ErrRenderFailed
+	}
+	telemetry.EndSpan(span, err)
+	return response
+}
+
+func (g *GitGeneratorImpl) progressWriter(operation string) io.Writer {
+	return progress.Writer(operation, g.progress)
+}
+
+func (g *GitGeneratorImpl) tagName(ctx context.Context, nameTemplate string) (string, error) {
+	tmpl, err := template.New("__tagname").Parse(nameTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	sourceRevision := ""
+	if g.source != nil {
+		sourceRevision = g.source.Revision(ctx)
+	}
+	sourceRevisionShort := sourceRevision
+	if len(sourceRevisionShort) > 7 {
+		sourceRevisionShort = sourceRevisionShort[:7]
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]string{
+		"Generator":           g.generatorName,
+		"SourceRevision":      sourceRevision,
+		"SourceRevisionShort": sourceRevisionShort,
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// endPhase wraps the error of a phase into an *api.Error before the phase is traced with it.
+func (g *GitGeneratorImpl) endPhase(phase *telemetry.Phase, name string, repoUrl string, err error) error {
+	err = errorkind.Wrap(name, telemetry.SafeUrl(repoUrl), err)
+	phase.End(err)
+	return err
+}
+
 // error situations
 
 func errCreateWorkdirFirst(ctx context.Context) error {
-	return errMsg(ctx, "implementation error - need to create a temporary workdir before clone")
+	return errMsg(ctx, "need to create a temporary workdir before clone")
 }
 
 func errDuplicateClone(ctx context.Context, whichRepo string) error {
-	return errMsg(ctx, "implementation error - duplicate clone for "+whichRepo)
+	return errMsg(ctx, "duplicate clone for "+whichRepo)
 }
 
 func errCloneSourceFirst(ctx context.Context) error {
-	return errMsg(ctx, "implementation error - must clone source before using it")
+	return errMsg(ctx, "must clone source before using it")
 }
 
 func errCloneTargetFirst(ctx context.Context) error {
-	return errMsg(ctx, "implementation error - must clone target before making changes to it")
+	return errMsg(ctx, "must clone target before making changes to it")
 }
 
 func errCloneTargetSuccessfullyFirst(ctx context.Context) error {
-	return errMsg(ctx, "implementation error - target clone or branch checkout was not successful, you cannot make changes to it")
+	return errMsg(ctx, "target clone or branch checkout was not successful, you cannot make changes to it")
 }
 
 func errWriteRenderSpecFirst(ctx context.Context) error {
-	return errMsg(ctx, "implementation error - you must write the render spec file before templates can be rendered")
+	return errMsg(ctx, "you must write the render spec file before templates can be rendered")
 }
 
 func errMsg(ctx context.Context, message string) error {
-	aulogging.Logger.Ctx(ctx).Error().Print(message)
-	return errors.New(message)
+	err := fmt.Errorf("%w - %s", api.ErrWrongOrder, message)
+	aulogging.Logger.Ctx(ctx).Error().Print(err.Error())
+	return err
 }
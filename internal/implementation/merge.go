@@ -0,0 +1,141 @@
+package implementation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/repository/manifest"
+	"github.com/mplushnikov/go-generator-git/v2/internal/textdiff"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	mergeLabelTarget    = "target"
+	mergeLabelGenerated = "generated"
+)
+
+// generateMerged renders into a scratch directory and three-way merges the output into the target.
+//
+// The merge base is the output of the generator version recorded in the manifest, rendered with the render
+// spec as committed in the target. Returns the scratch directory holding the pure generator output.
+func (g *GitGeneratorImpl) generateMerged(ctx context.Context) (*api.GenerateResult, string, error) {
+	scratchDir := filepath.Join(g.workdir.Path(ctx), "merge")
+	if err := os.RemoveAll(scratchDir); err != nil {
+		return generateResult(&genlibapi.Response{Success: false}), "", err
+	}
+
+	generatedDir := filepath.Join(scratchDir, "generated")
+	renderSpec, err := ioutil.ReadFile(filepath.Join(g.target.Path(), g.renderSpecFile))
+	if err != nil {
+		return generateResult(&genlibapi.Response{Success: false}), "", err
+	}
+	response, err := g.renderInto(ctx, "render", g.source.Path(), generatedDir, renderSpec)
+	if err != nil {
+		return generateResult(&genlibapi.Response{Success: false}), "", err
+	}
+	result := generateResult(response)
+	if !response.Success {
+		return result, generatedDir, nil
+	}
+
+	baseDir := g.renderMergeBase(ctx, scratchDir)
+	for _, file := range response.RenderedFiles {
+		if !file.Success {
+			continue
+		}
+		conflicts, err := mergeFile(file.RelativeFilePath, baseDir, generatedDir, g.target.Path())
+		if err != nil {
+			return result, generatedDir, err
+		}
+		if conflicts > 0 {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("%d merge conflicts in %s", conflicts, file.RelativeFilePath)
+			result.Conflicts = append(result.Conflicts, api.MergeConflict{Path: file.RelativeFilePath, Regions: conflicts})
+		}
+	}
+	return result, generatedDir, nil
+}
+
+// renderMergeBase returns the directory with the output of the previous generator version, or "" if there is none.
+func (g *GitGeneratorImpl) renderMergeBase(ctx context.Context, scratchDir string) string {
+	previous, err := manifest.Instance(ctx, g.target.Path(), g.renderSpecFile).Read(ctx)
+	if err != nil || previous == nil || previous.SourceRevision == "" {
+		aulogging.Logger.Ctx(ctx).Info().Print("previous generator version unknown, merging without a base")
+		return ""
+	}
+	previousRenderSpec, err := g.target.ReadFileAtHead(ctx, g.renderSpecFile)
+	if err != nil || previousRenderSpec == nil {
+		aulogging.Logger.Ctx(ctx).Info().Print("no committed render spec found, merging without a base")
+		return ""
+	}
+
+	baseSourceDir := filepath.Join(scratchDir, "base-source")
+	if err := g.source.ExportRevision(ctx, previous.SourceRevision, baseSourceDir); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("cannot obtain previous generator version %s, merging without a base", previous.SourceRevision)
+		return ""
+	}
+
+	baseDir := filepath.Join(scratchDir, "base")
+	response, err := g.renderInto(ctx, "render merge base", baseSourceDir, baseDir, previousRenderSpec)
+	if err != nil || !response.Success {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("cannot render previous generator version %s, merging without a base", previous.SourceRevision)
+		return ""
+	}
+	return baseDir
+}
+
+// renderInto renders the given render spec into a fresh directory.
+func (g *GitGeneratorImpl) renderInto(ctx context.Context, operation string, sourceDir string, targetDir string, renderSpec []byte) (*genlibapi.Response, error) {
+	renderSpecPath := filepath.Join(targetDir, g.renderSpecFile)
+	if err := os.MkdirAll(filepath.Dir(renderSpecPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(renderSpecPath, renderSpec, 0644); err != nil {
+		return nil, err
+	}
+	return g.render(ctx, operation, &genlibapi.Request{
+		SourceBaseDir:  sourceDir,
+		TargetBaseDir:  targetDir,
+		RenderSpecFile: g.renderSpecFile,
+	}), nil
+}
+
+// mergeFile merges a single generated file into the target, and returns the number of conflicts.
+//
+// Without a base, any difference between the target and the generated file is a conflict.
+func mergeFile(relativePath string, baseDir string, generatedDir string, targetDir string) (int, error) {
+	generated, err := ioutil.ReadFile(filepath.Join(generatedDir, relativePath))
+	if err != nil {
+		return 0, err
+	}
+
+	targetPath := filepath.Join(targetDir, relativePath)
+	current, err := ioutil.ReadFile(targetPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return 0, err
+		}
+		return 0, ioutil.WriteFile(targetPath, generated, 0644)
+	}
+	if bytes.Equal(current, generated) {
+		return 0, nil
+	}
+
+	var base []byte
+	if baseDir != "" {
+		base, err = ioutil.ReadFile(filepath.Join(baseDir, relativePath))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+	}
+
+	merged, conflicts := textdiff.Merge3(base, current, generated, mergeLabelTarget, mergeLabelGenerated)
+	return conflicts, ioutil.WriteFile(targetPath, merged, 0644)
+}
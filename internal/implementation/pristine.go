@@ -0,0 +1,231 @@
+package implementation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/repository/gittargetrepo"
+	"github.com/mplushnikov/go-generator-git/v2/internal/textdiff"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultPristineAuthorName  = "go-generator-git"
+	defaultPristineAuthorEmail = "go-generator-git@localhost"
+)
+
+// generatePristine commits the pure generator output to the pristine branch, and then merges that branch
+// into the target branch in the worktree. The merge is completed by the next CommitAndPush.
+func (g *GitGeneratorImpl) generatePristine(ctx context.Context) (*api.GenerateResult, error) {
+	branch := g.pristineBranchName()
+
+	// switching to the pristine branch empties the worktree, which must not take anything with it
+	uncommitted, err := g.target.UncommittedPaths(ctx, g.renderSpecFile)
+	if err != nil {
+		return generateResult(&genlibapi.Response{Success: false}), err
+	}
+	if len(uncommitted) > 0 {
+		return generateResult(&genlibapi.Response{Success: false}), fmt.Errorf("refusing to switch to pristine branch %s, the target has uncommitted or ignored files that would be lost: %s: %w",
+			branch, strings.Join(uncommitted, ", "), api.ErrUnexpectedChanges)
+	}
+
+	renderSpecPath := filepath.Join(g.target.Path(), g.renderSpecFile)
+	renderSpec, err := ioutil.ReadFile(renderSpecPath)
+	if err != nil {
+		return generateResult(&genlibapi.Response{Success: false}), err
+	}
+
+	if err := g.switchToPristineBranch(ctx, branch); err != nil {
+		_ = g.returnToTargetBranch(ctx, renderSpec)
+		return generateResult(&genlibapi.Response{Success: false}), err
+	}
+
+	if err := writeFile(renderSpecPath, renderSpec); err != nil {
+		_ = g.returnToTargetBranch(ctx, renderSpec)
+		return generateResult(&genlibapi.Response{Success: false}), err
+	}
+	result := generateResult(g.render(ctx, "render", g.request()))
+	if !result.Success {
+		return result, g.returnToTargetBranch(ctx, renderSpec)
+	}
+
+	pristineHash, err := g.commitPristine(ctx, branch)
+	if err != nil {
+		_ = g.returnToTargetBranch(ctx, renderSpec)
+		return result, err
+	}
+
+	if err := g.returnToTargetBranch(ctx, renderSpec); err != nil {
+		return result, err
+	}
+
+	if err := g.mergePristine(ctx, branch, *pristineHash, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (g *GitGeneratorImpl) pristineBranchName() string {
+	if g.pristineBranch.Branch != "" {
+		return g.pristineBranch.Branch
+	}
+	return "generator/pristine/" + g.generatorName
+}
+
+// switchToPristineBranch checks out the pristine branch with an empty worktree, creating it as a branch
+// without history if it exists neither locally nor on the remote.
+func (g *GitGeneratorImpl) switchToPristineBranch(ctx context.Context, branch string) error {
+	if g.target.GetHashForRevision(ctx, plumbing.NewBranchReferenceName(branch).String()) == nil {
+		remoteRef := plumbing.NewRemoteReferenceName(gittargetrepo.REMOTE_NAME, branch).String()
+		if remoteHash := g.target.GetHashForRevision(ctx, remoteRef); remoteHash != nil {
+			aulogging.Logger.Ctx(ctx).Info().Printf("creating local pristine branch %s from %s", branch, remoteHash.String())
+			if err := g.target.CreateBranch(ctx, branch, remoteHash); err != nil {
+				return err
+			}
+		} else {
+			aulogging.Logger.Ctx(ctx).Info().Printf("creating new pristine branch %s", branch)
+			return g.target.StartOrphanBranch(ctx, branch)
+		}
+	}
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("checking out pristine branch %s", branch)
+	if err := g.target.ForceCheckout(ctx, branch); err != nil {
+		return err
+	}
+	// start from scratch, so files that are no longer generated disappear from the pristine branch
+	return g.target.ClearWorktree(ctx)
+}
+
+func (g *GitGeneratorImpl) commitPristine(ctx context.Context, branch string) (*plumbing.Hash, error) {
+	name := g.pristineBranch.AuthorName
+	if name == "" {
+		name = defaultPristineAuthorName
+	}
+	email := g.pristineBranch.AuthorEmail
+	if email == "" {
+		email = defaultPristineAuthorEmail
+	}
+	message := fmt.Sprintf("generator %s at revision %s", g.generatorName, g.source.Revision(ctx))
+
+	hash, err := g.target.CommitAll(ctx, name, email, message)
+	if err != nil {
+		return nil, err
+	}
+	if hash == nil {
+		aulogging.Logger.Ctx(ctx).Info().Printf("generator output unchanged, pristine branch %s stays as it is", branch)
+		hash = g.target.HeadHash(ctx)
+	} else {
+		aulogging.Logger.Ctx(ctx).Info().Printf("committed generator output to pristine branch %s as %s", branch, hash.String())
+	}
+	return hash, nil
+}
+
+func (g *GitGeneratorImpl) returnToTargetBranch(ctx context.Context, renderSpec []byte) error {
+	if err := g.target.ForceCheckout(ctx, g.targetBranch); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(g.target.Path(), g.renderSpecFile), renderSpec)
+}
+
+// mergePristine brings the changes on the pristine branch since the last merge into the worktree of the target branch.
+func (g *GitGeneratorImpl) mergePristine(ctx context.Context, branch string, pristineHash plumbing.Hash, result *api.GenerateResult) error {
+	headHash := g.target.HeadHash(ctx)
+	if headHash == nil {
+		return fmt.Errorf("target branch %s has no commits to merge into", g.targetBranch)
+	}
+	baseHash, err := g.target.MergeBase(ctx, *headHash, pristineHash)
+	if err != nil {
+		return err
+	}
+	if baseHash != nil && *baseHash == pristineHash {
+		aulogging.Logger.Ctx(ctx).Info().Printf("pristine branch %s is already merged", branch)
+		return nil
+	}
+
+	base, err := g.target.ReadTree(ctx, baseHash)
+	if err != nil {
+		return err
+	}
+	ours, err := g.target.ReadTree(ctx, headHash)
+	if err != nil {
+		return err
+	}
+	theirs, err := g.target.ReadTree(ctx, &pristineHash)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range unionOfPaths(base, ours, theirs) {
+		b, inBase := base[p]
+		o, inOurs := ours[p]
+		t, inTheirs := theirs[p]
+		targetPath := filepath.Join(g.target.Path(), filepath.FromSlash(p))
+
+		switch {
+		case sameVersion(b, inBase, t, inTheirs):
+			// generator did not change it, keep what is in the target branch
+		case sameVersion(b, inBase, o, inOurs):
+			if inTheirs {
+				if err := writeFile(targetPath, t); err != nil {
+					return err
+				}
+			} else {
+				if err := os.Remove(targetPath); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				result.PrunedFiles = append(result.PrunedFiles, p)
+			}
+		case sameVersion(o, inOurs, t, inTheirs):
+			// both made the same change
+		case !inOurs || !inTheirs:
+			aulogging.Logger.Ctx(ctx).Warn().Printf("%s was deleted on one side and changed on the other, keeping the target version", p)
+			result.Conflicts = append(result.Conflicts, api.MergeConflict{Path: p, Regions: 1})
+		default:
+			merged, conflicts := textdiff.Merge3(b, o, t, g.targetBranch, branch)
+			if err := writeFile(targetPath, merged); err != nil {
+				return err
+			}
+			if conflicts > 0 {
+				aulogging.Logger.Ctx(ctx).Warn().Printf("%d merge conflicts in %s", conflicts, p)
+				result.Conflicts = append(result.Conflicts, api.MergeConflict{Path: p, Regions: conflicts})
+			}
+		}
+	}
+
+	g.target.MergeOnNextCommit(ctx, branch, pristineHash)
+	return nil
+}
+
+func sameVersion(a []byte, aExists bool, b []byte, bExists bool) bool {
+	return aExists == bExists && bytes.Equal(a, b)
+}
+
+func unionOfPaths(trees ...map[string][]byte) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, tree := range trees {
+		for p := range tree {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func writeFile(path string, contents []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, contents, 0644)
+}
@@ -0,0 +1,115 @@
+package implementation
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/repository/manifest"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+	"os"
+	"path/filepath"
+)
+
+// updateManifest writes the manifest of the files that were just generated into generatedDir.
+//
+// If pruning is enabled, it first deletes files listed in the manifest from the last run that were not
+// rendered this time, unless somebody changed them since.
+func (g *GitGeneratorImpl) updateManifest(ctx context.Context, result *api.GenerateResult, generatedDir string) error {
+	targetPath := g.target.Path()
+	manifestFile := manifest.Instance(ctx, targetPath, g.renderSpecFile)
+
+	if g.pruneOrphans {
+		previous, err := manifestFile.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if previous != nil {
+			if err := g.prune(ctx, result, previous); err != nil {
+				return err
+			}
+		}
+	}
+
+	// hashes are taken from the pure generator output, so human edits merged into the target count as changes
+	current, err := manifest.Build(ctx, generatedDir, g.generatorName, g.source.Revision(ctx), g.renderedFiles)
+	if err != nil {
+		return err
+	}
+	current.SourceUrl = telemetry.SafeUrl(g.sourceOriginalUrl)
+	if g.sourceUrl != g.sourceOriginalUrl {
+		current.SourceUrlRewritten = telemetry.SafeUrl(g.sourceUrl)
+	}
+	if err := manifestFile.Write(ctx, current); err != nil {
+		return err
+	}
+
+	g.manifestFile = manifestFile.RelativePath()
+	return nil
+}
+
+func (g *GitGeneratorImpl) prune(ctx context.Context, result *api.GenerateResult, previous *manifest.Manifest) error {
+	targetPath := g.target.Path()
+
+	stillGenerated := make(map[string]bool)
+	for _, p := range g.renderedFiles {
+		stillGenerated[filepath.ToSlash(p)] = true
+	}
+
+	for _, entry := range previous.Files {
+		if stillGenerated[filepath.ToSlash(entry.Path)] {
+			continue
+		}
+
+		fullPath := filepath.Join(targetPath, entry.Path)
+		inside, err := resolvesInside(targetPath, fullPath)
+		if err != nil {
+			return err
+		}
+		if !inside {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("keeping %s, it is behind a symlink that leads out of the target", entry.Path)
+			continue
+		}
+		currentHash, err := manifest.HashFile(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// already gone
+				continue
+			}
+			return err
+		}
+
+		if currentHash != entry.Sha256 {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("keeping %s, it is no longer generated but was changed since it was", entry.Path)
+			result.ModifiedOrphans = append(result.ModifiedOrphans, entry.Path)
+			continue
+		}
+
+		aulogging.Logger.Ctx(ctx).Info().Printf("deleting %s, it is no longer generated", entry.Path)
+		if err := os.Remove(fullPath); err != nil {
+			return err
+		}
+		result.PrunedFiles = append(result.PrunedFiles, entry.Path)
+	}
+	return nil
+}
+
+// resolvesInside is true if the directory of fullPath, with symlinks resolved, is targetPath or below it,
+// and also if it does not exist, as then there is nothing to delete.
+func resolvesInside(targetPath string, fullPath string) (bool, error) {
+	root, err := filepath.EvalSymlinks(targetPath)
+	if err != nil {
+		return false, err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(fullPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false, nil
+	}
+	return rel == "." || filepath.IsLocal(rel), nil
+}
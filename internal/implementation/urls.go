@@ -0,0 +1,46 @@
+package implementation
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/repository/gittargetrepo"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+	"github.com/mplushnikov/go-generator-git/v2/internal/urlrewrite"
+)
+
+func (g *GitGeneratorImpl) SetUrlRewriting(rewriting *api.UrlRewriting) error {
+	rules, err := urlrewrite.New(rewriting)
+	if err != nil {
+		return err
+	}
+	g.urlRules = rules
+	return nil
+}
+
+func (g *GitGeneratorImpl) RewriteUrl(url string) string {
+	return g.urlRules.Rewrite(url)
+}
+
+// rewriteUrl applies the rules, and logs if they changed the url.
+func (g *GitGeneratorImpl) rewriteUrl(ctx context.Context, url string) string {
+	rewritten := g.urlRules.Rewrite(url)
+	if rewritten != url {
+		aulogging.Logger.Ctx(ctx).Info().Printf("using %s for %s", telemetry.SafeUrl(rewritten), telemetry.SafeUrl(url))
+	}
+	return rewritten
+}
+
+// setOriginalUrls fills in the urls the remotes of the push results were given with.
+func (g *GitGeneratorImpl) setOriginalUrls(result *api.CommitResult) {
+	for i := range result.PushResults {
+		pushResult := &result.PushResults[i]
+		if pushResult.RemoteName == gittargetrepo.REMOTE_NAME {
+			pushResult.OriginalUrl = g.targetOriginalUrl
+		} else if original, ok := g.mirrorOriginalUrls[pushResult.RemoteName]; ok {
+			pushResult.OriginalUrl = original
+		} else {
+			pushResult.OriginalUrl = pushResult.RemoteUrl
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package implementation
+
+import (
+	"context"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/go-git/go-git/v5"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/userregions"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// captureUserRegions reads the user regions of all files in the target, keyed by slash separated relative path.
+func (g *GitGeneratorImpl) captureUserRegions(ctx context.Context) (map[string][]userregions.Region, error) {
+	captured := make(map[string][]userregions.Region)
+	root := g.target.Path()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == git.GitDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !userregions.Contains(contents) {
+			return nil
+		}
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		regions, err := userregions.Extract(contents)
+		if err != nil {
+			return fmt.Errorf("cannot preserve the user regions of %s, fix its markers first: %w", filepath.ToSlash(relativePath), err)
+		}
+		if len(regions) > 0 {
+			captured[filepath.ToSlash(relativePath)] = regions
+		}
+		return nil
+	})
+	aulogging.Logger.Ctx(ctx).Debug().Printf("captured user regions from %d files", len(captured))
+	return captured, err
+}
+
+// restoreUserRegions puts captured user regions back into the rendered files, and reports the ones that have no place left.
+func (g *GitGeneratorImpl) restoreUserRegions(ctx context.Context, captured map[string][]userregions.Region, result *api.GenerateResult) error {
+	for _, file := range result.RenderedFiles {
+		if !file.Success {
+			continue
+		}
+		relativePath := filepath.ToSlash(file.RelativeFilePath)
+		regions, ok := captured[relativePath]
+		if !ok {
+			continue
+		}
+
+		path := filepath.Join(g.target.Path(), filepath.FromSlash(relativePath))
+		generated, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		spliced, orphaned, err := userregions.Splice(generated, regions)
+		if err != nil {
+			return fmt.Errorf("%w: cannot put back the user regions of %s, the generated file has broken markers: %w", api.ErrRenderFailed, relativePath, err)
+		}
+		if err := ioutil.WriteFile(path, spliced, 0644); err != nil {
+			return err
+		}
+		for _, r := range orphaned {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("user region %s no longer exists in %s, its content was dropped", r.Name, relativePath)
+			result.OrphanedRegions = append(result.OrphanedRegions, api.OrphanedRegion{
+				Path:    relativePath,
+				Name:    r.Name,
+				Content: string(r.Content),
+			})
+		}
+	}
+	return nil
+}
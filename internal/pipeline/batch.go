@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"sync"
+)
+
+// RunBatch runs the jobs, each on its own instance obtained from newInstance.
+//
+// Results are in the same order as the jobs. Jobs that were skipped because of StopOnError have a nil result.
+// The error summarizes how many jobs failed or were skipped.
+func RunBatch(ctx context.Context, newInstance func() api.GitApi, jobs []api.Job, options api.BatchOptions) ([]*api.SessionResult, error) {
+	parallelism := options.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	results := make([]*api.SessionResult, len(jobs))
+	var mu sync.Mutex
+	failed := 0
+	stopped := false
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				mu.Lock()
+				skip := stopped
+				mu.Unlock()
+				if skip || ctx.Err() != nil {
+					continue
+				}
+
+				result, err := Run(ctx, newInstance(), jobs[i])
+				mu.Lock()
+				results[i] = result
+				if err != nil {
+					aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("job %d (%s) failed", i+1, jobs[i].Name)
+					failed++
+					stopped = stopped || options.StopOnError
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	skipped := 0
+	for _, r := range results {
+		if r == nil {
+			skipped++
+		}
+	}
+	if failed > 0 || skipped > 0 {
+		return results, fmt.Errorf("%d of %d jobs failed, %d skipped", failed, len(jobs), skipped)
+	}
+	return results, nil
+}
@@ -0,0 +1,267 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"os"
+	"time"
+)
+
+const (
+	defaultSourceBranch = "main"
+	defaultAuthorName   = "go-generator-git"
+	defaultAuthorEmail  = "go-generator-git@localhost"
+)
+
+// Run executes the job on the given instance, which must be fresh, and always cleans up its working directory.
+//
+// The result contains every phase that was started, and the results of the steps that ran. The returned
+// error is the error of the first failing phase.
+func Run(ctx context.Context, gen api.GitApi, job api.Job) (result *api.SessionResult, err error) {
+	job, err = withDefaults(job)
+	result = &api.SessionResult{JobName: job.Name, Generator: job.Generator, RenderSpecFile: job.RenderSpecFile}
+	if err != nil {
+		return result, err
+	}
+
+	r := NewRecorder(ctx, result)
+	r.SetTimeouts(job.Timeouts)
+	gen.SetRetryPolicy(job.Retry)
+	if err := gen.SetTransport(job.Transport); err != nil {
+		return result, err
+	}
+	if err := gen.SetHostKeyVerification(job.HostKeys); err != nil {
+		return result, err
+	}
+	if err := gen.SetUrlRewriting(job.UrlRewriting); err != nil {
+		return result, err
+	}
+	result.Source = api.RepositoryUrl{Original: job.Source.Url, Rewritten: gen.RewriteUrl(job.Source.Url)}
+	result.Target = api.RepositoryUrl{Original: job.Target.Url, Rewritten: gen.RewriteUrl(job.Target.Url)}
+	if job.Credentials != nil {
+		gen.SetCredentialResolver(credentialsFor(job))
+	}
+	for _, hooks := range job.Hooks {
+		gen.AddHooks(hooks)
+	}
+	if job.Progress != nil {
+		gen.SetProgressSink(job.Progress)
+		r.SetProgressSink(job.Progress)
+	}
+	if !r.Phase(api.PhaseCreateWorkdir, func(ctx context.Context) error {
+		return gen.CreateTemporaryWorkdir(ctx, job.WorkdirBase)
+	}) {
+		return result, r.Err()
+	}
+	defer func() {
+		r.Phase(api.PhaseCleanup, func(ctx context.Context) error {
+			return gen.Cleanup(ctx)
+		})
+		err = r.Err()
+	}()
+
+	ok := r.Phase(api.PhaseCloneSource, func(ctx context.Context) error {
+		_, err := gen.CloneSourceRepo(ctx, job.Source.Url, job.Source.Branch, job.Source.Auth)
+		return err
+	}) && r.Phase(api.PhaseCloneTarget, func(ctx context.Context) error {
+		_, err := gen.CloneTargetRepo(ctx, job.Target.Url, job.Target.Branch, job.Target.BaseBranch, job.Target.Auth)
+		if err != nil {
+			return err
+		}
+		return configure(ctx, gen, job)
+	}) && r.Phase(api.PhaseWriteRenderSpec, func(ctx context.Context) error {
+		var err error
+		result.RenderSpec, err = gen.WriteRenderSpecFile(ctx, job.Generator, job.RenderSpecFile, job.Parameters)
+		return err
+	}) && r.Phase(api.PhaseGenerate, func(ctx context.Context) error {
+		var err error
+		result.Generate, err = gen.GenerateWithResult(ctx)
+		if err != nil {
+			return err
+		}
+		result.Changes, err = gen.DiffTarget(ctx)
+		return err
+	})
+	if ok && job.Commit != nil {
+		r.Phase(api.PhaseCommitAndPush, func(ctx context.Context) error {
+			var auth = job.Target.Auth
+			if job.Push == nil {
+				auth = nil
+			} else if job.Push.Auth != nil {
+				auth = job.Push.Auth
+			}
+			var err error
+			result.Commit, err = gen.CommitAndPushWithResult(ctx, job.Commit.AuthorName, job.Commit.AuthorEmail, job.Commit.Message, auth)
+			return err
+		})
+	}
+	return result, r.Err()
+}
+
+func withDefaults(job api.Job) (api.Job, error) {
+	if job.Source.Url == "" || job.Target.Url == "" || job.Target.Branch == "" || job.Generator == "" {
+		return job, errors.New("job must specify source url, target url, target branch, and generator")
+	}
+	if job.WorkdirBase == "" {
+		job.WorkdirBase = os.TempDir()
+	}
+	if job.Source.Branch == "" {
+		job.Source.Branch = defaultSourceBranch
+	}
+	if job.Target.BaseBranch == "" {
+		job.Target.BaseBranch = job.Target.Branch
+	}
+	if job.RenderSpecFile == "" {
+		job.RenderSpecFile = fmt.Sprintf("generated-%s.yaml", job.Generator)
+	}
+	if job.Commit != nil {
+		commit := *job.Commit
+		if commit.AuthorName == "" {
+			commit.AuthorName = defaultAuthorName
+		}
+		if commit.AuthorEmail == "" {
+			commit.AuthorEmail = defaultAuthorEmail
+		}
+		if commit.Message == "" {
+			commit.Message = fmt.Sprintf("regenerate with generator %s", job.Generator)
+		}
+		job.Commit = &commit
+	}
+	return job, nil
+}
+
+// credentialsFor keeps the resolver of the job from enabling pushes if the job does not push.
+func credentialsFor(job api.Job) api.CredentialResolver {
+	if job.Push != nil {
+		return job.Credentials
+	}
+	return api.CredentialResolverFunc(func(ctx context.Context, repoUrl string, access api.Access) (transport.AuthMethod, error) {
+		if access == api.AccessWrite {
+			return nil, nil
+		}
+		return job.Credentials.Resolve(ctx, repoUrl, access)
+	})
+}
+
+// configure passes the settings of the job on to the instance, once the target is cloned.
+func configure(ctx context.Context, gen api.GitApi, job api.Job) error {
+	gen.SetUpdateStrategy(job.UpdateStrategy)
+	gen.SetPristineBranch(job.PristineBranch)
+	gen.SetPruneOrphans(job.PruneOrphans)
+	gen.SetPreserveUserRegions(job.PreserveUserRegions)
+	if job.Commit != nil {
+		gen.SetStagingMode(job.Commit.StagingMode)
+		gen.SetCommitTag(job.Commit.Tag)
+		if job.Push != nil {
+			gen.SetPushPolicy(job.Push.Policy)
+			for _, m := range job.Push.Mirrors {
+				if err := gen.AddPushRemote(ctx, m.Name, m.Url, m.Auth); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Recorder runs the steps of a session as phases, and records their outcome in the session result.
+type Recorder struct {
+	ctx      context.Context
+	result   *api.SessionResult
+	err      error
+	progress api.ProgressSink
+	timeouts map[string]time.Duration
+}
+
+func NewRecorder(ctx context.Context, result *api.SessionResult) *Recorder {
+	return &Recorder{ctx: ctx, result: result}
+}
+
+// SetProgressSink has the recorder report the start and end of every phase to sink.
+func (r *Recorder) SetProgressSink(sink api.ProgressSink) {
+	r.progress = sink
+}
+
+// SetTimeouts limits how long phases may take, keyed by the api.Phase... constants.
+func (r *Recorder) SetTimeouts(timeouts map[string]time.Duration) {
+	r.timeouts = timeouts
+}
+
+// Phase runs a step and records its outcome, returning true if it succeeded.
+//
+// The step gets the context of the recorder, limited by the timeout of the phase. Once that context
+// is done, further steps fail without being run, except for cleanup, which always runs.
+func (r *Recorder) Phase(name string, step func(ctx context.Context) error) bool {
+	started := time.Now()
+	aulogging.Logger.Ctx(r.ctx).Info().Printf("starting phase %s", name)
+	if r.progress != nil {
+		r.progress(api.ProgressEvent{Kind: api.ProgressPhaseStarted, Time: started, Phase: name})
+	}
+	err := r.run(name, step)
+	duration := time.Since(started)
+	r.result.Phases = append(r.result.Phases, api.PhaseResult{
+		Name:     name,
+		Started:  started,
+		Duration: duration,
+		Err:      err,
+	})
+	if r.progress != nil {
+		r.progress(api.ProgressEvent{Kind: api.ProgressPhaseFinished, Time: time.Now(), Phase: name, Duration: duration, Err: err})
+	}
+	if err != nil {
+		aulogging.Logger.Ctx(r.ctx).Warn().WithErr(err).Printf("phase %s failed", name)
+		if r.err == nil {
+			r.err = fmt.Errorf("%s: %w", name, err)
+		}
+		return false
+	}
+	return true
+}
+
+func (r *Recorder) run(name string, step func(ctx context.Context) error) error {
+	ctx := r.ctx
+	if name == api.PhaseCleanup {
+		ctx = detached{ctx}
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+	timeout := r.timeouts[name]
+	if timeout <= 0 {
+		return step(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := step(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+		// go-git does not always pass on why it was interrupted
+		err = fmt.Errorf("timed out after %s: %w: %w", timeout, context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+// detached keeps the values of a context, but is never done, so cleanup still happens after a cancel.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
+
+// Err is the error of the first phase that failed, prefixed with the name of the phase.
+func (r *Recorder) Err() error {
+	return r.err
+}
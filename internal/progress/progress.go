@@ -0,0 +1,94 @@
+// Package progress turns the sideband progress of git remotes into progress events.
+package progress
+
+import (
+	"bytes"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// e.g. 'Receiving objects:  42% (21/50)' or 'Counting objects: 100% (5/5), done.'
+var withPercent = regexp.MustCompile(`^([A-Za-z][A-Za-z ]*):\s+(\d+)% \((\d+)/(\d+)\)`)
+
+// e.g. 'Enumerating objects: 5, done.'
+var withCount = regexp.MustCompile(`^([A-Za-z][A-Za-z ]*):\s+(\d+)`)
+
+// Writer returns a writer for the Progress option of go-git, which passes the parsed lines to sink.
+//
+// Returns nil if sink is nil, so go-git does not request progress at all.
+func Writer(operation string, sink api.ProgressSink) io.Writer {
+	if sink == nil {
+		return nil
+	}
+	return &writer{operation: operation, sink: sink}
+}
+
+type writer struct {
+	operation string
+	sink      api.ProgressSink
+	pending   []byte
+}
+
+// Write splits at both '\r' and '\n', as remotes update counts in place with '\r'.
+func (w *writer) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		end := bytes.IndexAny(w.pending, "\r\n")
+		if end < 0 {
+			return len(p), nil
+		}
+		line := string(w.pending[:end])
+		w.pending = w.pending[end+1:]
+		if event, ok := Parse(w.operation, line); ok {
+			w.sink(event)
+		}
+	}
+}
+
+// Parse turns a line of sideband progress into an event. Empty lines give none.
+func Parse(operation string, line string) (api.ProgressEvent, bool) {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "remote:"))
+	if line == "" {
+		return api.ProgressEvent{}, false
+	}
+	event := api.ProgressEvent{
+		Kind:      api.ProgressTransfer,
+		Time:      time.Now(),
+		Operation: operation,
+		Percent:   -1,
+	}
+	if m := withPercent.FindStringSubmatch(line); m != nil {
+		event.Stage = m[1]
+		event.Percent, _ = strconv.Atoi(m[2])
+		event.Current, _ = strconv.Atoi(m[3])
+		event.Total, _ = strconv.Atoi(m[4])
+	} else if m := withCount.FindStringSubmatch(line); m != nil {
+		event.Stage = m[1]
+		event.Current, _ = strconv.Atoi(m[2])
+		if strings.HasSuffix(line, "done.") {
+			event.Total = event.Current
+			event.Percent = 100
+		}
+	} else {
+		event.Message = line
+	}
+	return event, true
+}
+
+// RenderStarted reports that rendering started. The number of files is not known until it finishes.
+func RenderStarted(sink api.ProgressSink, operation string) {
+	if sink != nil {
+		sink(api.ProgressEvent{Kind: api.ProgressRender, Time: time.Now(), Operation: operation, Percent: -1})
+	}
+}
+
+// RenderFinished reports how many files were rendered.
+func RenderFinished(sink api.ProgressSink, operation string, files int) {
+	if sink != nil {
+		sink(api.ProgressEvent{Kind: api.ProgressRender, Time: time.Now(), Operation: operation, Current: files, Total: files, Percent: 100})
+	}
+}
@@ -0,0 +1,59 @@
+package progress
+
+import (
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	event, ok := Parse("clone source", "Receiving objects:  42% (21/50)")
+	require.True(t, ok)
+	require.Equal(t, api.ProgressTransfer, event.Kind)
+	require.Equal(t, "clone source", event.Operation)
+	require.Equal(t, "Receiving objects", event.Stage)
+	require.Equal(t, 42, event.Percent)
+	require.Equal(t, 21, event.Current)
+	require.Equal(t, 50, event.Total)
+
+	event, ok = Parse("clone source", "Enumerating objects: 5, done.")
+	require.True(t, ok)
+	require.Equal(t, "Enumerating objects", event.Stage)
+	require.Equal(t, 5, event.Current)
+	require.Equal(t, 5, event.Total)
+	require.Equal(t, 100, event.Percent)
+
+	event, ok = Parse("clone source", "Enumerating objects: 17")
+	require.True(t, ok)
+	require.Equal(t, 17, event.Current)
+	require.Equal(t, 0, event.Total)
+	require.Equal(t, -1, event.Percent)
+
+	event, ok = Parse("push origin", "remote: Total 5 (delta 0), reused 0 (delta 0)")
+	require.True(t, ok)
+	require.Equal(t, "", event.Stage)
+	require.Equal(t, "Total 5 (delta 0), reused 0 (delta 0)", event.Message)
+
+	_, ok = Parse("push origin", "  \t")
+	require.False(t, ok)
+}
+
+func TestWriter(t *testing.T) {
+	require.Nil(t, Writer("clone source", nil))
+
+	var events []api.ProgressEvent
+	w := Writer("clone source", func(event api.ProgressEvent) {
+		events = append(events, event)
+	})
+	// counts are updated in place with \r, and chunks do not respect line boundaries
+	for _, chunk := range []string{"Counting objects:  50% (1/2)\rCounting obj", "ects: 100% (2/2), done.\n", "Total 2"} {
+		n, err := w.Write([]byte(chunk))
+		require.Nil(t, err)
+		require.Equal(t, len(chunk), n)
+	}
+
+	require.Len(t, events, 2)
+	require.Equal(t, 50, events[0].Percent)
+	require.Equal(t, 100, events[1].Percent)
+	require.Equal(t, 2, events[1].Current)
+}
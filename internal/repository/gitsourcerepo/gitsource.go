@@ -2,32 +2,138 @@ package gitsourcerepo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/retry"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 )
 
 type GitSourceRepo struct {
-	localPath string
-	repo      *git.Repository
+	localPath   string
+	repo        *git.Repository
+	retryPolicy api.RetryPolicy
 }
 
 func Instance(_ context.Context, localPath string) *GitSourceRepo {
 	return &GitSourceRepo{localPath: localPath}
 }
 
-func (s *GitSourceRepo) Clone(ctx context.Context, gitRepoUrl string, branchName string, auth transport.AuthMethod) error {
-	repo, err := git.PlainCloneContext(ctx, s.localPath, false, &git.CloneOptions{
+// SetRetryPolicy has Clone retry transient failures.
+func (s *GitSourceRepo) SetRetryPolicy(policy api.RetryPolicy) {
+	s.retryPolicy = policy
+}
+
+// Clone clones the given branch, or else the given tag or revision, which is checked out detached.
+// progress receives the sideband progress of the remote, and may be nil.
+func (s *GitSourceRepo) Clone(ctx context.Context, gitRepoUrl string, branchName string, auth transport.AuthMethod, progress io.Writer) (err error) {
+	ctx, span := telemetry.Span(ctx, "clone", telemetry.KeyRepoUrl.String(telemetry.SafeUrl(gitRepoUrl)), telemetry.KeyBranch.String(branchName))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	err = s.clone(ctx, &git.CloneOptions{
 		Auth:          auth,
 		URL:           gitRepoUrl,
 		ReferenceName: plumbing.NewBranchReferenceName(branchName),
 		SingleBranch:  true,
-		Progress:      nil,
+		Progress:      progress,
+	})
+	if errors.Is(err, git.NoMatchingRefSpecError{}) {
+		// not a branch, so we need all branches and tags to find the tag or revision
+		err = s.cloneRevision(ctx, gitRepoUrl, branchName, auth, progress, err)
+	}
+	if err == nil {
+		telemetry.RecordTransfer(ctx, "clone source", telemetry.ObjectsSize(s.localPath))
+	}
+	return err
+}
+
+func (s *GitSourceRepo) clone(ctx context.Context, options *git.CloneOptions) error {
+	var repo *git.Repository
+	err := retry.Do(ctx, s.retryPolicy, "clone source", func() error {
+		var err error
+		repo, err = git.PlainCloneContext(ctx, s.localPath, false, options)
+		return err
+	}, func() error {
+		return os.RemoveAll(s.localPath)
 	})
 	s.repo = repo
 	return err
 }
 
+// cloneRevision clones all of the repository and checks out revision, or returns an error that wraps
+// branchErr if there is no such tag or revision either.
+func (s *GitSourceRepo) cloneRevision(ctx context.Context, gitRepoUrl string, revision string, auth transport.AuthMethod, progress io.Writer, branchErr error) error {
+	if err := os.RemoveAll(s.localPath); err != nil {
+		return err
+	}
+	err := s.clone(ctx, &git.CloneOptions{
+		Auth:       auth,
+		URL:        gitRepoUrl,
+		NoCheckout: true,
+		Progress:   progress,
+	})
+	if err != nil {
+		return err
+	}
+	hash, err := s.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return fmt.Errorf("'%s' is neither a branch, a tag nor a revision of the source repository: %w", revision, branchErr)
+	}
+	worktree, err := s.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Checkout(&git.CheckoutOptions{Hash: *hash})
+}
+
+// Revision returns the commit hash the source clone is at, or the empty string if it cannot be determined.
+func (s *GitSourceRepo) Revision(_ context.Context) string {
+	if s.repo == nil {
+		return ""
+	}
+	head, err := s.repo.Head()
+	if err != nil {
+		return ""
+	}
+	return head.Hash().String()
+}
+
+// ExportRevision writes the files of the given revision to a directory outside the clone,
+// for example to render an earlier version of the generator.
+func (s *GitSourceRepo) ExportRevision(_ context.Context, revision string, directory string) error {
+	hash, err := s.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return err
+	}
+	commit, err := s.repo.CommitObject(*hash)
+	if err != nil {
+		return err
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return err
+	}
+	return files.ForEach(func(f *object.File) error {
+		contents, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(directory, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(targetPath, []byte(contents), 0644)
+	})
+}
+
 func (s *GitSourceRepo) Path() string {
 	return s.localPath
 }
@@ -0,0 +1,74 @@
+package gittargetrepo
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-git/go-git/v5"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/textdiff"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const diffContextLines = 3
+
+// Changes lists the uncommitted changes in the worktree compared to HEAD, sorted by path, with unified diffs.
+func (t *GitTargetRepo) Changes(ctx context.Context) ([]api.FileDiff, error) {
+	worktree, err := t.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for p, s := range status {
+		if s.Worktree != git.Unmodified || s.Staging != git.Unmodified {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var diffs []api.FileDiff
+	for _, p := range paths {
+		before, err := t.ReadFileAtHead(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		after, err := ioutil.ReadFile(filepath.Join(t.localPath, filepath.FromSlash(p)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		d := api.FileDiff{Path: p, Change: api.ChangeModified}
+		aName, bName := "a/"+p, "b/"+p
+		switch {
+		case before == nil && after == nil:
+			continue
+		case before == nil:
+			d.Change = api.ChangeAdded
+			aName = "/dev/null"
+		case after == nil:
+			d.Change = api.ChangeDeleted
+			bName = "/dev/null"
+		case bytes.Equal(before, after):
+			// only the mode changed, or the file is staged but unchanged
+			continue
+		}
+		if isBinary(before) || isBinary(after) {
+			d.Binary = true
+		} else {
+			d.Diff = string(textdiff.Unified(aName, bName, before, after, diffContextLines))
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+func isBinary(contents []byte) bool {
+	return bytes.IndexByte(contents, 0) >= 0
+}
@@ -2,19 +2,63 @@ package gittargetrepo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/retry"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
 type GitTargetRepo struct {
-	localPath string
-	repo      *git.Repository
-	remote    *git.Remote
-	pushFunc  func(auth transport.AuthMethod) error
+	localPath   string
+	repo        *git.Repository
+	remote      *git.Remote
+	pushFunc    func(ctx context.Context, auth transport.AuthMethod, refSpecs []config.RefSpec) error
+	mirrors     []mirrorRemote
+	tag         *tagRequest
+	staging     *stagingRequest
+	merge       *mergeRequest
+	progress    func(operation string) io.Writer
+	retryPolicy api.RetryPolicy
+}
+
+type mergeRequest struct {
+	branch string
+	hash   plumbing.Hash
+}
+
+type stagingRequest struct {
+	paths        map[string]bool
+	failOnOthers bool
+}
+
+type tagRequest struct {
+	name      string
+	message   string
+	signKey   *openpgp.Entity
+	overwrite bool
+}
+
+type mirrorRemote struct {
+	name string
+	url  string
+	auth transport.AuthMethod
 }
 
 // note: push is disabled by default until we enable it
@@ -22,15 +66,15 @@ type GitTargetRepo struct {
 func Instance(_ context.Context, localPath string) *GitTargetRepo {
 	return &GitTargetRepo{
 		localPath: localPath,
-		pushFunc: func(_ transport.AuthMethod) error {
-			return nil
-		},
 	}
 }
 
 const REMOTE_NAME = "origin"
 
 func (t *GitTargetRepo) PrepareInit(ctx context.Context, gitRepoUrl string, gitBranch string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	repo, err := git.PlainInit(t.localPath, false)
 	t.repo = repo
 
@@ -50,13 +94,37 @@ func (t *GitTargetRepo) PrepareInit(ctx context.Context, gitRepoUrl string, gitB
 	return err
 }
 
-func (t *GitTargetRepo) Clone(ctx context.Context, gitRepoUrl string, auth transport.AuthMethod) error {
-	repo, err := git.PlainCloneContext(ctx, t.localPath, false, &git.CloneOptions{
-		Auth:     auth,
-		URL:      gitRepoUrl,
-		Progress: nil,
+// SetRetryPolicy has Clone and the pushes of CommitAndPush retry transient failures.
+func (t *GitTargetRepo) SetRetryPolicy(policy api.RetryPolicy) {
+	t.retryPolicy = policy
+}
+
+// SetProgress has clone and push pass the sideband progress of the remote to the writer returned
+// for the operation, which may be nil.
+func (t *GitTargetRepo) SetProgress(progress func(operation string) io.Writer) {
+	t.progress = progress
+}
+
+func (t *GitTargetRepo) Clone(ctx context.Context, gitRepoUrl string, auth transport.AuthMethod) (err error) {
+	ctx, span := telemetry.Span(ctx, "clone", telemetry.KeyRepoUrl.String(telemetry.SafeUrl(gitRepoUrl)))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	var repo *git.Repository
+	err = retry.Do(ctx, t.retryPolicy, "clone target", func() error {
+		var err error
+		repo, err = git.PlainCloneContext(ctx, t.localPath, false, &git.CloneOptions{
+			Auth:     auth,
+			URL:      gitRepoUrl,
+			Progress: t.progressWriter("clone target"),
+		})
+		return err
+	}, func() error {
+		return os.RemoveAll(t.localPath)
 	})
 	t.repo = repo
+	if err == nil {
+		telemetry.RecordTransfer(ctx, "clone target", telemetry.ObjectsSize(t.localPath))
+	}
 	return err
 }
 
@@ -70,6 +138,9 @@ func (t *GitTargetRepo) GetHashForRevision(ctx context.Context, branchOrTag stri
 }
 
 func (t *GitTargetRepo) Checkout(ctx context.Context, branch string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	worktree, err := t.repo.Worktree()
 	if err != nil {
 		return err
@@ -83,24 +154,140 @@ func (t *GitTargetRepo) Checkout(ctx context.Context, branch string) error {
 	return nil
 }
 
-func (t *GitTargetRepo) CreateBranch(ctx context.Context, shortBranchName string, hash *plumbing.Hash) error {
-	refName := plumbing.ReferenceName("refs/heads/" + shortBranchName)
-	ref := plumbing.NewHashReference(refName, *hash)
-	return t.repo.Storer.SetReference(ref)
+// ForceCheckout checks out a branch, discarding any uncommitted changes to tracked files.
+func (t *GitTargetRepo) ForceCheckout(ctx context.Context, branch string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	worktree, err := t.repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	return worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch), Force: true})
+}
+
+// StartOrphanBranch points HEAD to a branch that does not exist yet, so the next commit has no parents,
+// and empties the index and the worktree.
+func (t *GitTargetRepo) StartOrphanBranch(ctx context.Context, branch string) error {
+	h := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(branch))
+	if err := t.repo.Storer.SetReference(h); err != nil {
+		return err
+	}
+	if err := t.repo.Storer.SetIndex(&index.Index{Version: 2}); err != nil {
+		return err
+	}
+	return t.ClearWorktree(ctx)
 }
 
-func (t *GitTargetRepo) CommitAndPush(ctx context.Context, name string, email string, message string, auth transport.AuthMethod) error {
+// UncommittedPaths lists the files in the worktree that differ from the index: changed and deleted
+// files, and files that are not tracked, including ignored ones. Paths in except are left out.
+func (t *GitTargetRepo) UncommittedPaths(_ context.Context, except ...string) ([]string, error) {
 	worktree, err := t.repo.Worktree()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	for p, s := range status {
+		if s.Worktree != git.Unmodified || s.Staging != git.Unmodified {
+			found[p] = true
+		}
 	}
 
-	_, err = worktree.Add(".")
+	// the status leaves out ignored files
+	idx, err := t.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool)
+	for _, e := range idx.Entries {
+		tracked[e.Name] = true
+	}
+	err = filepath.Walk(t.localPath, func(fullPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == git.GitDirName && fullPath != t.localPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relative, err := filepath.Rel(t.localPath, fullPath)
+		if err != nil {
+			return err
+		}
+		if p := filepath.ToSlash(relative); !tracked[p] {
+			found[p] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range except {
+		delete(found, normalizePath(p))
+	}
+	paths := make([]string, 0, len(found))
+	for p := range found {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ClearWorktree deletes all files in the worktree, tracked or not, leaving only the .git directory.
+func (t *GitTargetRepo) ClearWorktree(_ context.Context) error {
+	entries, err := ioutil.ReadDir(t.localPath)
 	if err != nil {
 		return err
 	}
+	for _, entry := range entries {
+		if entry.Name() == git.GitDirName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(t.localPath, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CommitAll commits every change in the worktree to the current branch, without pushing.
+//
+// Returns nil and no error if there was nothing to commit.
+func (t *GitTargetRepo) CommitAll(ctx context.Context, name string, email string, message string) (*plumbing.Hash, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	worktree, err := t.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := worktree.Add("."); err != nil {
+		return nil, err
+	}
+	if err := stageDeletions(worktree); err != nil {
+		return nil, err
+	}
 
-	_, err = worktree.Commit(message, &git.CommitOptions{
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+	if status.IsClean() {
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	hash, err := worktree.Commit(message, &git.CommitOptions{
 		Author: &object.Signature{
 			Name:  name,
 			Email: email,
@@ -108,32 +295,440 @@ func (t *GitTargetRepo) CommitAndPush(ctx context.Context, name string, email st
 		},
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &hash, nil
+}
 
-	err = t.pushFunc(auth)
+func (t *GitTargetRepo) CreateBranch(ctx context.Context, shortBranchName string, hash *plumbing.Hash) error {
+	refName := plumbing.ReferenceName("refs/heads/" + shortBranchName)
+	ref := plumbing.NewHashReference(refName, *hash)
+	return t.repo.Storer.SetReference(ref)
+}
+
+// ReadFileAtHead returns the committed contents of a file, ignoring changes in the worktree.
+//
+// Returns nil and no error if HEAD does not exist yet or does not contain the file.
+func (t *GitTargetRepo) ReadFileAtHead(ctx context.Context, relativePath string) ([]byte, error) {
+	head, err := t.repo.Head()
+	if err != nil {
+		if err == plumbing.ErrReferenceNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	commit, err := t.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	file, err := commit.File(normalizePath(relativePath))
+	if err != nil {
+		if err == object.ErrFileNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(contents), nil
+}
+
+// AddMirrorRemote configures an additional remote that CommitAndPush pushes the current branch to.
+//
+// Mirrors are pushed to even if push to the origin is not enabled, each with its own auth.
+func (t *GitTargetRepo) AddMirrorRemote(ctx context.Context, name string, gitRepoUrl string, auth transport.AuthMethod) error {
+	if name == REMOTE_NAME {
+		return fmt.Errorf("remote name %s is reserved for the target repo itself", REMOTE_NAME)
+	}
+	for _, m := range t.mirrors {
+		if m.name == name {
+			return fmt.Errorf("duplicate remote name %s", name)
+		}
+	}
+
+	_, err := t.repo.CreateRemote(&config.RemoteConfig{
+		Name: name,
+		URLs: []string{gitRepoUrl},
+	})
 	if err != nil {
 		return err
 	}
 
+	t.mirrors = append(t.mirrors, mirrorRemote{name: name, url: gitRepoUrl, auth: auth})
 	return nil
 }
 
+// TagNextCommit makes CommitAndPush tag the commit it creates, and push the tag alongside the branch.
+//
+// The tag is annotated if message is not empty, and signed if a signKey is given (which requires a message).
+// An existing tag of the same name is an error unless overwrite is set.
+func (t *GitTargetRepo) TagNextCommit(ctx context.Context, name string, message string, signKey *openpgp.Entity, overwrite bool) error {
+	if name == "" {
+		return errors.New("tag name must not be empty")
+	}
+	if signKey != nil && message == "" {
+		return errors.New("signed tags must have a message")
+	}
+	t.tag = &tagRequest{name: name, message: message, signKey: signKey, overwrite: overwrite}
+	return nil
+}
+
+// StageOnly makes CommitAndPush add just the given paths instead of every change in the worktree.
+//
+// Other changed paths are left out of the commit and reported, or CommitAndPush fails if failOnOthers is set.
+func (t *GitTargetRepo) StageOnly(ctx context.Context, relativePaths []string, failOnOthers bool) {
+	paths := make(map[string]bool)
+	for _, p := range relativePaths {
+		paths[normalizePath(p)] = true
+	}
+	t.staging = &stagingRequest{paths: paths, failOnOthers: failOnOthers}
+}
+
+func (t *GitTargetRepo) CommitAndPush(ctx context.Context, name string, email string, message string, auth transport.AuthMethod, policy api.PushPolicy) (*api.CommitResult, error) {
+	result := &api.CommitResult{}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	// an existing tag must stop us before anything is staged or committed
+	if t.tag != nil && !t.tag.overwrite {
+		if err := t.checkTagIsNew(); err != nil {
+			return result, err
+		}
+	}
+
+	worktree, err := t.repo.Worktree()
+	if err != nil {
+		return result, err
+	}
+
+	if t.staging == nil {
+		_, err = worktree.Add(".")
+		if err != nil {
+			return result, err
+		}
+		// adding a directory does not pick up deleted files
+		if err := stageDeletions(worktree); err != nil {
+			return result, err
+		}
+	} else {
+		result.IgnoredPaths, err = t.stageSelected(ctx, worktree)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	commitOptions := &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  name,
+			Email: email,
+			When:  time.Now(),
+		},
+	}
+	var extraRefSpecs []config.RefSpec
+	if t.merge != nil {
+		head, err := t.repo.Head()
+		if err != nil {
+			return result, err
+		}
+		commitOptions.Parents = []plumbing.Hash{head.Hash(), t.merge.hash}
+
+		mergedRef := plumbing.NewBranchReferenceName(t.merge.branch)
+		extraRefSpecs = append(extraRefSpecs, config.RefSpec(fmt.Sprintf("%s:%s", mergedRef, mergedRef)))
+	}
+
+	// the last chance to stop without leaving a commit behind
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	_, span := telemetry.Span(ctx, "commit")
+	hash, err := worktree.Commit(message, commitOptions)
+	telemetry.EndSpan(span, err)
+	if err != nil {
+		return result, err
+	}
+	result.CommitHash = hash.String()
+
+	var tagRefSpecs []config.RefSpec
+	if t.tag != nil {
+		if err := t.createTag(hash, name, email); err != nil {
+			return result, err
+		}
+		result.Tag = t.tag.name
+
+		tagRef := plumbing.NewTagReferenceName(t.tag.name)
+		tagRefSpec := config.RefSpec(fmt.Sprintf("%s:%s", tagRef, tagRef))
+		if t.tag.overwrite {
+			tagRefSpec = "+" + tagRefSpec
+		}
+		tagRefSpecs = append(tagRefSpecs, tagRefSpec)
+	}
+
+	if t.pushFunc != nil {
+		var refSpecs []config.RefSpec
+		if len(tagRefSpecs) > 0 {
+			// pushing the tag requires explicit refspecs, so we must also spell out the default for branches
+			refSpecs = append([]config.RefSpec{config.DefaultPushRefSpec}, tagRefSpecs...)
+		}
+		_, span := t.pushSpan(ctx, REMOTE_NAME)
+		err = retry.Do(ctx, t.retryPolicy, "push "+REMOTE_NAME, func() error {
+			return t.pushFunc(ctx, auth, refSpecs)
+		}, nil)
+		telemetry.EndSpan(span, err)
+		result.PushResults = append(result.PushResults, api.PushResult{
+			RemoteName: REMOTE_NAME,
+			RemoteUrl:  t.remoteUrl(REMOTE_NAME),
+			Success:    err == nil,
+			Err:        err,
+		})
+	}
+
+	if len(t.mirrors) > 0 {
+		head, err := t.repo.Head()
+		if err != nil {
+			return result, err
+		}
+		refSpec := config.RefSpec(fmt.Sprintf("%s:%s", head.Name(), head.Name()))
+		for _, m := range t.mirrors {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			_, span := t.pushSpan(ctx, m.name)
+			err := retry.Do(ctx, t.retryPolicy, "push "+m.name, func() error {
+				err := t.repo.PushContext(ctx, &git.PushOptions{
+					RemoteName: m.name,
+					RefSpecs:   append(append([]config.RefSpec{refSpec}, extraRefSpecs...), tagRefSpecs...),
+					Auth:       m.auth,
+					Progress:   t.progressWriter("push " + m.name),
+				})
+				if err == git.NoErrAlreadyUpToDate {
+					return nil
+				}
+				return err
+			}, nil)
+			telemetry.EndSpan(span, err)
+			result.PushResults = append(result.PushResults, api.PushResult{
+				RemoteName: m.name,
+				RemoteUrl:  m.url,
+				Success:    err == nil,
+				Err:        err,
+			})
+		}
+	}
+
+	return result, pushError(result.PushResults, policy)
+}
+
 func (t *GitTargetRepo) EnablePush() {
-	t.pushFunc = func(auth transport.AuthMethod) error {
+	t.pushFunc = func(ctx context.Context, auth transport.AuthMethod, refSpecs []config.RefSpec) error {
 		if nil != t.remote {
-			return t.remote.Push(&git.PushOptions{
+			return t.remote.PushContext(ctx, &git.PushOptions{
 				RemoteName: REMOTE_NAME,
+				RefSpecs:   refSpecs,
 				Auth:       auth,
+				Progress:   t.progressWriter("push " + REMOTE_NAME),
 			})
 		} else {
-			return t.repo.Push(&git.PushOptions{
-				Auth: auth,
+			return t.repo.PushContext(ctx, &git.PushOptions{
+				RefSpecs: refSpecs,
+				Auth:     auth,
+				Progress: t.progressWriter("push " + REMOTE_NAME),
 			})
 		}
 	}
 }
 
+func (t *GitTargetRepo) pushSpan(ctx context.Context, remoteName string) (context.Context, trace.Span) {
+	return telemetry.Span(ctx, "push", telemetry.KeyRemote.String(remoteName), telemetry.KeyRepoUrl.String(telemetry.SafeUrl(t.remoteUrl(remoteName))))
+}
+
+// progressWriter is nil, not a nil *writer, if there is nothing to report to, so go-git knows.
+func (t *GitTargetRepo) progressWriter(operation string) io.Writer {
+	if t.progress == nil {
+		return nil
+	}
+	return t.progress(operation)
+}
+
 func (t *GitTargetRepo) Path() string {
 	return t.localPath
 }
+
+// internal helpers
+
+func (t *GitTargetRepo) stageSelected(ctx context.Context, worktree *git.Worktree) ([]string, error) {
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var ignored []string
+	var toAdd []string
+	for p, fileStatus := range status {
+		if fileStatus.Worktree == git.Unmodified {
+			continue
+		}
+		if t.staging.paths[normalizePath(p)] {
+			toAdd = append(toAdd, p)
+		} else {
+			ignored = append(ignored, p)
+		}
+	}
+	sort.Strings(ignored)
+	sort.Strings(toAdd)
+
+	if t.staging.failOnOthers && len(ignored) > 0 {
+		return ignored, fmt.Errorf("refusing to commit, found changes to files that were not generated: %s: %w", strings.Join(ignored, ", "), api.ErrUnexpectedChanges)
+	}
+
+	for _, p := range toAdd {
+		if err := ctx.Err(); err != nil {
+			return ignored, err
+		}
+		if _, err := worktree.Add(p); err != nil {
+			return ignored, err
+		}
+	}
+	return ignored, nil
+}
+
+func stageDeletions(worktree *git.Worktree) error {
+	status, err := worktree.Status()
+	if err != nil {
+		return err
+	}
+	for p, fileStatus := range status {
+		if fileStatus.Worktree == git.Deleted {
+			if _, err := worktree.Add(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func normalizePath(relativePath string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(relativePath)), "./")
+}
+
+// checkTagIsNew returns an error if the requested tag already exists.
+func (t *GitTargetRepo) checkTagIsNew() error {
+	existing, err := t.repo.Tag(t.tag.name)
+	if err == git.ErrTagNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	existingCommit, err := t.resolveTagToCommit(existing)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("tag %s already exists and points to %s", t.tag.name, existingCommit.String())
+}
+
+// createTag tags hash, replacing an existing tag, which checkTagIsNew only lets through with overwrite.
+func (t *GitTargetRepo) createTag(hash plumbing.Hash, name string, email string) error {
+	if _, err := t.repo.Tag(t.tag.name); err == nil {
+		if err := t.repo.DeleteTag(t.tag.name); err != nil {
+			return err
+		}
+	} else if err != git.ErrTagNotFound {
+		return err
+	}
+
+	var opts *git.CreateTagOptions
+	if t.tag.message != "" {
+		opts = &git.CreateTagOptions{
+			Tagger: &object.Signature{
+				Name:  name,
+				Email: email,
+				When:  time.Now(),
+			},
+			Message: t.tag.message,
+			SignKey: t.tag.signKey,
+		}
+	}
+	_, err := t.repo.CreateTag(t.tag.name, hash, opts)
+	return err
+}
+
+func (t *GitTargetRepo) resolveTagToCommit(ref *plumbing.Reference) (plumbing.Hash, error) {
+	tagObject, err := t.repo.TagObject(ref.Hash())
+	switch err {
+	case nil:
+		commit, err := tagObject.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return commit.Hash, nil
+	case plumbing.ErrObjectNotFound:
+		// lightweight tag
+		return ref.Hash(), nil
+	default:
+		return plumbing.ZeroHash, err
+	}
+}
+
+func (t *GitTargetRepo) remoteUrl(name string) string {
+	remote, err := t.repo.Remote(name)
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
+
+func pushError(results []api.PushResult, policy api.PushPolicy) error {
+	var failed []string
+	var errs []error
+	for _, r := range results {
+		if !r.Success {
+			failed = append(failed, fmt.Sprintf("%s: %s", r.RemoteName, r.Err.Error()))
+			errs = append(errs, r.Err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if policy == api.PushToAny && len(failed) < len(results) {
+		return nil
+	}
+	if len(results) == 1 {
+		return errs[0]
+	}
+	return &pushFailures{
+		message: fmt.Sprintf("push failed for %d of %d remotes (%s)", len(failed), len(results), strings.Join(failed, "; ")),
+		errs:    errs,
+	}
+}
+
+// pushFailures keeps the errors of the failed remotes, so errors.Is and errors.As find out why they failed.
+//
+// It implements Is and As rather than Unwrap() []error, which errors.Is only follows since Go 1.20.
+type pushFailures struct {
+	message string
+	errs    []error
+}
+
+func (p *pushFailures) Error() string {
+	return p.message
+}
+
+func (p *pushFailures) Is(target error) bool {
+	for _, err := range p.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pushFailures) As(target interface{}) bool {
+	for _, err := range p.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
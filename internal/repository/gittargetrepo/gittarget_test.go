@@ -0,0 +1,290 @@
+package gittargetrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createRemote sets up a bare repository with a single commit on branch main and returns its path
+func createRemote(t *testing.T) string {
+	return testrepo.Create(t, map[string]string{"README.md": "# seed\n"})
+}
+
+func cloneAndChange(t *testing.T, remotePath string) *GitTargetRepo {
+	ctx := context.TODO()
+	target := Instance(ctx, filepath.Join(t.TempDir(), "target"))
+	require.Nil(t, target.Clone(ctx, remotePath, nil))
+	require.Nil(t, os.WriteFile(filepath.Join(target.Path(), "generated.txt"), []byte("generated\n"), 0644))
+	return target
+}
+
+func branchHash(t *testing.T, repoPath string, branch string) string {
+	repo, err := git.PlainOpen(repoPath)
+	require.Nil(t, err)
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return ""
+	}
+	return ref.Hash().String()
+}
+
+func createMirror(t *testing.T, origin string) string {
+	mirror := filepath.Join(t.TempDir(), "mirror.git")
+	_, err := git.PlainClone(mirror, true, &git.CloneOptions{URL: origin})
+	require.Nil(t, err)
+	return mirror
+}
+
+func TestCommitAndPush_MirrorsWithoutOrigin(t *testing.T) {
+	ctx := context.TODO()
+	origin := createRemote(t)
+	mirror1 := createMirror(t, origin)
+	mirror2 := createMirror(t, origin)
+	target := cloneAndChange(t, origin)
+	require.Nil(t, target.AddMirrorRemote(ctx, "gitea", mirror1, nil))
+	require.Nil(t, target.AddMirrorRemote(ctx, "gitlab", mirror2, nil))
+
+	result, err := target.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAll)
+	require.Nil(t, err)
+	require.NotEmpty(t, result.CommitHash)
+	require.Equal(t, 2, len(result.PushResults))
+	require.Equal(t, "gitea", result.PushResults[0].RemoteName)
+	require.Equal(t, mirror1, result.PushResults[0].RemoteUrl)
+	require.True(t, result.PushResults[0].Success)
+	require.Equal(t, "gitlab", result.PushResults[1].RemoteName)
+	require.True(t, result.PushResults[1].Success)
+	require.Equal(t, result.CommitHash, branchHash(t, mirror1, "main"))
+	require.Equal(t, result.CommitHash, branchHash(t, mirror2, "main"))
+	require.NotEqual(t, result.CommitHash, branchHash(t, origin, "main"))
+}
+
+func TestCommitAndPush_PushToAll(t *testing.T) {
+	ctx := context.TODO()
+	origin := createRemote(t)
+	target := cloneAndChange(t, origin)
+
+	mirror := createMirror(t, origin)
+	require.Nil(t, target.AddMirrorRemote(ctx, "mirror", mirror, nil))
+	require.Nil(t, target.AddMirrorRemote(ctx, "broken", filepath.Join(t.TempDir(), "does-not-exist"), nil))
+
+	target.EnablePush()
+	result, err := target.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAll)
+	require.NotNil(t, err)
+	require.Equal(t, 3, len(result.PushResults))
+	require.Equal(t, REMOTE_NAME, result.PushResults[0].RemoteName)
+	require.True(t, result.PushResults[0].Success)
+	require.True(t, result.PushResults[1].Success)
+	require.False(t, result.PushResults[2].Success)
+	require.Equal(t, result.CommitHash, branchHash(t, origin, "main"))
+	require.Equal(t, result.CommitHash, branchHash(t, mirror, "main"))
+}
+
+func TestCommitAndPush_PushToAny(t *testing.T) {
+	ctx := context.TODO()
+	origin := createRemote(t)
+	target := cloneAndChange(t, origin)
+	require.Nil(t, target.AddMirrorRemote(ctx, "broken", filepath.Join(t.TempDir(), "does-not-exist"), nil))
+
+	target.EnablePush()
+	result, err := target.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAny)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(result.PushResults))
+	require.True(t, result.PushResults[0].Success)
+	require.False(t, result.PushResults[1].Success)
+}
+
+func TestPushError_FindsTheCauses(t *testing.T) {
+	noRefSpec := &git.NoMatchingRefSpecError{}
+	err := pushError([]api.PushResult{
+		{RemoteName: "origin", Err: api.ErrPushRejected},
+		{RemoteName: "mirror", Err: fmt.Errorf("mirror: %w", noRefSpec)},
+		{RemoteName: "backup", Success: true},
+	}, api.PushToAll)
+	require.ErrorContains(t, err, "push failed for 2 of 3 remotes")
+	require.True(t, errors.Is(err, api.ErrPushRejected))
+	require.False(t, errors.Is(err, api.ErrAuthentication))
+	var found *git.NoMatchingRefSpecError
+	require.True(t, errors.As(err, &found))
+	require.Same(t, noRefSpec, found)
+}
+
+func TestAddMirrorRemote_Invalid(t *testing.T) {
+	ctx := context.TODO()
+	target := cloneAndChange(t, createRemote(t))
+	require.NotNil(t, target.AddMirrorRemote(ctx, REMOTE_NAME, "https://example.com/repo.git", nil))
+	require.Nil(t, target.AddMirrorRemote(ctx, "mirror", "https://example.com/repo.git", nil))
+	require.NotNil(t, target.AddMirrorRemote(ctx, "mirror", "https://example.com/other.git", nil))
+}
+
+func tagCommit(t *testing.T, repoPath string, tag string) string {
+	repo, err := git.PlainOpen(repoPath)
+	require.Nil(t, err)
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		return ""
+	}
+	if tagObject, err := repo.TagObject(ref.Hash()); err == nil {
+		return tagObject.Target.String()
+	}
+	return ref.Hash().String()
+}
+
+func TestCommitAndPush_LightweightTag(t *testing.T) {
+	ctx := context.TODO()
+	origin := createRemote(t)
+	target := cloneAndChange(t, origin)
+	require.Nil(t, target.TagNextCommit(ctx, "scaffold-v1", "", nil, false))
+
+	target.EnablePush()
+	result, err := target.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAll)
+	require.Nil(t, err)
+	require.Equal(t, "scaffold-v1", result.Tag)
+	require.Equal(t, result.CommitHash, tagCommit(t, origin, "scaffold-v1"))
+	require.Equal(t, result.CommitHash, branchHash(t, origin, "main"))
+}
+
+func TestCommitAndPush_SignedAnnotatedTag(t *testing.T) {
+	ctx := context.TODO()
+	origin := createRemote(t)
+	target := cloneAndChange(t, origin)
+	key, err := openpgp.NewEntity("somebody", "", "somebody@example.com", nil)
+	require.Nil(t, err)
+	require.Nil(t, target.TagNextCommit(ctx, "generated/main/abc1234", "generated from abc1234", key, false))
+
+	target.EnablePush()
+	result, err := target.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAll)
+	require.Nil(t, err)
+	require.Equal(t, result.CommitHash, tagCommit(t, origin, "generated/main/abc1234"))
+
+	repo, err := git.PlainOpen(origin)
+	require.Nil(t, err)
+	ref, err := repo.Tag("generated/main/abc1234")
+	require.Nil(t, err)
+	tagObject, err := repo.TagObject(ref.Hash())
+	require.Nil(t, err)
+	require.Equal(t, "generated from abc1234\n", tagObject.Message)
+	require.NotEmpty(t, tagObject.PGPSignature)
+}
+
+func TestCommitAndPush_ExistingTag(t *testing.T) {
+	ctx := context.TODO()
+	origin := createRemote(t)
+
+	first := cloneAndChange(t, origin)
+	require.Nil(t, first.TagNextCommit(ctx, "scaffold-v1", "", nil, false))
+	first.EnablePush()
+	firstResult, err := first.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAll)
+	require.Nil(t, err)
+
+	second := cloneAndChange(t, origin)
+	require.Nil(t, os.WriteFile(filepath.Join(second.Path(), "generated.txt"), []byte("regenerated\n"), 0644))
+	require.Nil(t, second.TagNextCommit(ctx, "scaffold-v1", "", nil, false))
+	second.EnablePush()
+	secondResult, err := second.CommitAndPush(ctx, "somebody", "somebody@example.com", "regenerate", nil, api.PushToAll)
+	require.ErrorContains(t, err, "tag scaffold-v1 already exists")
+	require.Equal(t, "", secondResult.CommitHash)
+	require.Equal(t, firstResult.CommitHash, branchHash(t, second.Path(), "main"))
+	worktree, err := second.repo.Worktree()
+	require.Nil(t, err)
+	status, err := worktree.Status()
+	require.Nil(t, err)
+	require.Equal(t, git.Unmodified, status.File("generated.txt").Staging)
+	require.Equal(t, git.Modified, status.File("generated.txt").Worktree)
+	require.Equal(t, firstResult.CommitHash, tagCommit(t, origin, "scaffold-v1"))
+
+	third := cloneAndChange(t, origin)
+	require.Nil(t, os.WriteFile(filepath.Join(third.Path(), "generated.txt"), []byte("regenerated again\n"), 0644))
+	require.Nil(t, third.TagNextCommit(ctx, "scaffold-v1", "", nil, true))
+	third.EnablePush()
+	thirdResult, err := third.CommitAndPush(ctx, "somebody", "somebody@example.com", "regenerate", nil, api.PushToAll)
+	require.Nil(t, err)
+	require.Equal(t, thirdResult.CommitHash, tagCommit(t, origin, "scaffold-v1"))
+}
+
+func TestTagNextCommit_Invalid(t *testing.T) {
+	ctx := context.TODO()
+	target := cloneAndChange(t, createRemote(t))
+	key, err := openpgp.NewEntity("somebody", "", "somebody@example.com", nil)
+	require.Nil(t, err)
+	require.NotNil(t, target.TagNextCommit(ctx, "", "", nil, false))
+	require.NotNil(t, target.TagNextCommit(ctx, "signed", "", key, false))
+}
+
+func committedFiles(t *testing.T, repoPath string, commitHash string) []string {
+	repo, err := git.PlainOpen(repoPath)
+	require.Nil(t, err)
+	commit, err := repo.CommitObject(plumbing.NewHash(commitHash))
+	require.Nil(t, err)
+	var files []string
+	iter, err := commit.Files()
+	require.Nil(t, err)
+	require.Nil(t, iter.ForEach(func(f *object.File) error {
+		files = append(files, f.Name)
+		return nil
+	}))
+	return files
+}
+
+func TestCommitAndPush_StageOnly(t *testing.T) {
+	ctx := context.TODO()
+	target := cloneAndChange(t, createRemote(t))
+	require.Nil(t, os.MkdirAll(filepath.Join(target.Path(), "internal"), 0755))
+	require.Nil(t, os.WriteFile(filepath.Join(target.Path(), "internal", "gen.go"), []byte("package internal\n"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(target.Path(), "stray.log"), []byte("leftover\n"), 0644))
+
+	target.StageOnly(ctx, []string{"generated.txt", "./internal/gen.go", "not-rendered.txt"}, false)
+	result, err := target.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAll)
+	require.Nil(t, err)
+	require.Equal(t, []string{"stray.log"}, result.IgnoredPaths)
+	require.ElementsMatch(t, []string{"README.md", "generated.txt", "internal/gen.go"}, committedFiles(t, target.Path(), result.CommitHash))
+}
+
+func TestCommitAndPush_StageOnlyStrict(t *testing.T) {
+	ctx := context.TODO()
+	target := cloneAndChange(t, createRemote(t))
+	require.Nil(t, os.WriteFile(filepath.Join(target.Path(), "stray.log"), []byte("leftover\n"), 0644))
+
+	target.StageOnly(ctx, []string{"generated.txt"}, true)
+	result, err := target.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAll)
+	require.NotNil(t, err)
+	require.Equal(t, "", result.CommitHash)
+	require.Equal(t, []string{"stray.log"}, result.IgnoredPaths)
+}
+
+func TestCommitAndPush_StagesDeletions(t *testing.T) {
+	ctx := context.TODO()
+	target := cloneAndChange(t, createRemote(t))
+	require.Nil(t, os.Remove(filepath.Join(target.Path(), "README.md")))
+
+	result, err := target.CommitAndPush(ctx, "somebody", "somebody@example.com", "generate", nil, api.PushToAll)
+	require.Nil(t, err)
+	require.Equal(t, []string{"generated.txt"}, committedFiles(t, target.Path(), result.CommitHash))
+}
+
+func TestChanges(t *testing.T) {
+	target := cloneAndChange(t, createRemote(t))
+	require.Nil(t, os.WriteFile(filepath.Join(target.Path(), "README.md"), []byte("# changed\n"), 0644))
+
+	diffs, err := target.Changes(context.TODO())
+	require.Nil(t, err)
+	require.Equal(t, []api.FileDiff{
+		{Path: "README.md", Change: api.ChangeModified, Diff: "--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-# seed\n+# changed\n"},
+		{Path: "generated.txt", Change: api.ChangeAdded, Diff: "--- /dev/null\n+++ b/generated.txt\n@@ -0,0 +1 @@\n+generated\n"},
+	}, diffs)
+
+	require.Nil(t, os.Remove(filepath.Join(target.Path(), "README.md")))
+	diffs, err = target.Changes(context.TODO())
+	require.Nil(t, err)
+	require.Equal(t, api.ChangeDeleted, diffs[0].Change)
+}
@@ -0,0 +1,70 @@
+package gittargetrepo
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// MergeBase returns the best common ancestor of two commits, or nil if they have no common history.
+func (t *GitTargetRepo) MergeBase(_ context.Context, first plumbing.Hash, second plumbing.Hash) (*plumbing.Hash, error) {
+	firstCommit, err := t.repo.CommitObject(first)
+	if err != nil {
+		return nil, err
+	}
+	secondCommit, err := t.repo.CommitObject(second)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := firstCommit.MergeBase(secondCommit)
+	if err != nil {
+		return nil, err
+	}
+	if len(bases) == 0 {
+		return nil, nil
+	}
+	return &bases[0].Hash, nil
+}
+
+// ReadTree returns the contents of all files in a commit, keyed by their slash separated path.
+//
+// A nil hash stands for a commit without any files.
+func (t *GitTargetRepo) ReadTree(_ context.Context, hash *plumbing.Hash) (map[string][]byte, error) {
+	contents := make(map[string][]byte)
+	if hash == nil {
+		return contents, nil
+	}
+	commit, err := t.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return nil, err
+	}
+	err = files.ForEach(func(f *object.File) error {
+		c, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		contents[f.Name] = []byte(c)
+		return nil
+	})
+	return contents, err
+}
+
+// MergeOnNextCommit makes the next CommitAndPush record the tip of the given branch as a second parent,
+// so the commit becomes a merge commit, and pushes that branch along with the current one.
+func (t *GitTargetRepo) MergeOnNextCommit(_ context.Context, branch string, hash plumbing.Hash) {
+	t.merge = &mergeRequest{branch: branch, hash: hash}
+}
+
+// HeadHash returns the commit HEAD points to, or nil if HEAD does not exist yet.
+func (t *GitTargetRepo) HeadHash(_ context.Context) *plumbing.Hash {
+	head, err := t.repo.Head()
+	if err != nil {
+		return nil
+	}
+	hash := head.Hash()
+	return &hash
+}
@@ -0,0 +1,114 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest lists the files a render spec produced the last time it was rendered, so later
+// runs can find out which files are no longer generated. It also records where the generator
+// came from, with SourceUrlRewritten only set if url rewriting changed SourceUrl.
+type Manifest struct {
+	Generator          string      `yaml:"generator"`
+	SourceRevision     string      `yaml:"source_revision,omitempty"`
+	SourceUrl          string      `yaml:"source_url,omitempty"`
+	SourceUrlRewritten string      `yaml:"source_url_rewritten,omitempty"`
+	Files              []FileEntry `yaml:"files"`
+}
+
+type FileEntry struct {
+	Path   string `yaml:"path"`
+	Sha256 string `yaml:"sha256"`
+}
+
+type ManifestFile struct {
+	targetPath   string
+	relativePath string
+}
+
+// note: the manifest for 'generated-main.yaml' is 'generated-main.manifest.yaml' in the same directory
+
+func Instance(_ context.Context, targetPath string, renderSpecFile string) *ManifestFile {
+	return &ManifestFile{targetPath: targetPath, relativePath: FileName(renderSpecFile)}
+}
+
+const suffix = ".manifest.yaml"
+
+func FileName(renderSpecFile string) string {
+	return strings.TrimSuffix(renderSpecFile, filepath.Ext(renderSpecFile)) + suffix
+}
+
+func IsManifest(fileName string) bool {
+	return strings.HasSuffix(fileName, suffix)
+}
+
+// Read returns nil and no error if there is no manifest yet.
+//
+// The manifest is committed in the target, so anyone who can push there can edit it. Read refuses
+// manifests with paths that are absolute or point outside the target.
+func (f *ManifestFile) Read(_ context.Context) (*Manifest, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(f.targetPath, f.relativePath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := yaml.Unmarshal(contents, m); err != nil {
+		return nil, err
+	}
+	for _, entry := range m.Files {
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+			return nil, fmt.Errorf("manifest %s lists '%s', which is not a path inside the target", f.relativePath, entry.Path)
+		}
+	}
+	return m, nil
+}
+
+func (f *ManifestFile) Write(_ context.Context, m *Manifest) error {
+	contents, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(f.targetPath, f.relativePath), contents, 0644)
+}
+
+func (f *ManifestFile) RelativePath() string {
+	return f.relativePath
+}
+
+// Build hashes the given files, which are relative to targetPath.
+func Build(_ context.Context, targetPath string, generator string, sourceRevision string, relativePaths []string) (*Manifest, error) {
+	m := &Manifest{Generator: generator, SourceRevision: sourceRevision}
+	for _, p := range relativePaths {
+		hash, err := HashFile(filepath.Join(targetPath, p))
+		if err != nil {
+			return m, err
+		}
+		m.Files = append(m.Files, FileEntry{Path: p, Sha256: hash})
+	}
+	return m, nil
+}
+
+func HashFile(path string) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return Hash(contents), nil
+}
+
+func Hash(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
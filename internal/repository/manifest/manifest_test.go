@@ -0,0 +1,49 @@
+package manifest
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	require.Equal(t, "generated-main.manifest.yaml", FileName("generated-main.yaml"))
+	require.Equal(t, "sub/generated-api.manifest.yaml", FileName("sub/generated-api.yaml"))
+	require.True(t, IsManifest(FileName("generated-main.yaml")))
+	require.False(t, IsManifest("generated-main.yaml"))
+}
+
+func TestWriteAndRead(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+
+	file := Instance(ctx, dir, "generated-main.yaml")
+	missing, err := file.Read(ctx)
+	require.Nil(t, err)
+	require.Nil(t, missing)
+
+	m, err := Build(ctx, dir, "main", "abc", []string{"a.txt"})
+	require.Nil(t, err)
+	require.Nil(t, file.Write(ctx, m))
+
+	actual, err := file.Read(ctx)
+	require.Nil(t, err)
+	require.Equal(t, m, actual)
+	require.Equal(t, Hash([]byte("a")), actual.Files[0].Sha256)
+}
+
+func TestRead_PathsOutsideTheTarget(t *testing.T) {
+	ctx := context.TODO()
+	for _, p := range []string{"../outside.txt", "sub/../../outside.txt", "/etc/passwd", ""} {
+		dir := t.TempDir()
+		contents := "generator: main\nfiles:\n- path: '" + p + "'\n  sha256: abc\n"
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "generated-main.manifest.yaml"), []byte(contents), 0644))
+
+		_, err := Instance(ctx, dir, "generated-main.yaml").Read(ctx)
+		require.NotNil(t, err, p)
+		require.Contains(t, err.Error(), "not a path inside the target")
+	}
+}
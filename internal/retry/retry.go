@@ -0,0 +1,77 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/errorkind"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+	defaultMultiplier     = 2.0
+)
+
+// sleep waits for d, or until ctx is done. Replaced by tests.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Do runs attempt until it succeeds, fails with an error that is not transient, the attempts of the
+// policy are used up, or ctx is done, and returns the error of the last attempt.
+//
+// cleanup, which may be nil, runs before every retry, e.g. to delete a half-finished clone.
+func Do(ctx context.Context, policy api.RetryPolicy, operation string, attempt func() error, cleanup func() error) error {
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= policy.MaxAttempts || !errorkind.Transient(err) {
+			return err
+		}
+
+		wait := Backoff(policy, n)
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("%s failed (attempt %d of %d), retrying in %s", operation, n, policy.MaxAttempts, wait)
+		if cleanup != nil {
+			if cleanupErr := cleanup(); cleanupErr != nil {
+				aulogging.Logger.Ctx(ctx).Warn().WithErr(cleanupErr).Printf("cannot clean up after failed %s", operation)
+				return err
+			}
+		}
+		if sleepErr := sleep(ctx, wait); sleepErr != nil {
+			return fmt.Errorf("%w while waiting to retry %s: %w", sleepErr, operation, err)
+		}
+	}
+}
+
+// Backoff is the wait after the given failed attempt, counting from 1, including jitter.
+func Backoff(policy api.RetryPolicy, attempt int) time.Duration {
+	initial := policy.InitialBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	max := policy.MaxBackoff
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+
+	wait := math.Min(float64(initial)*math.Pow(multiplier, float64(attempt-1)), float64(max))
+	if policy.Jitter > 0 {
+		wait *= 1 + policy.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(wait)
+}
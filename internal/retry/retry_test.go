@@ -0,0 +1,94 @@
+package retry
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+func recordSleeps(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	original := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleep = original })
+	return &waits
+}
+
+func TestDo_RetriesTransientErrors(t *testing.T) {
+	waits := recordSleeps(t)
+	policy := api.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, Multiplier: 2}
+
+	attempts, cleanups := 0, 0
+	err := Do(context.TODO(), policy, "clone", func() error {
+		attempts++
+		if attempts < 4 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}, func() error {
+		cleanups++
+		return nil
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, 4, attempts)
+	require.Equal(t, 3, cleanups)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, *waits)
+}
+
+func TestDo_GivesUpAfterMaxAttempts(t *testing.T) {
+	recordSleeps(t)
+	attempts := 0
+	err := Do(context.TODO(), api.RetryPolicy{MaxAttempts: 3}, "push", func() error {
+		attempts++
+		return io.ErrUnexpectedEOF
+	}, nil)
+
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, 3, attempts)
+}
+
+func TestDo_DoesNotRetryPermanentErrors(t *testing.T) {
+	waits := recordSleeps(t)
+	attempts := 0
+	err := Do(context.TODO(), api.DefaultRetryPolicy(), "clone", func() error {
+		attempts++
+		return transport.ErrAuthenticationRequired
+	}, nil)
+
+	require.ErrorIs(t, err, transport.ErrAuthenticationRequired)
+	require.Equal(t, 1, attempts)
+	require.Empty(t, *waits)
+}
+
+func TestDo_StopsWhenCancelled(t *testing.T) {
+	recordSleeps(t)
+	ctx, cancel := context.WithCancel(context.TODO())
+	attempts := 0
+	err := Do(ctx, api.DefaultRetryPolicy(), "push", func() error {
+		attempts++
+		cancel()
+		return io.ErrUnexpectedEOF
+	}, nil)
+
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, 1, attempts)
+}
+
+func TestBackoff_Jitter(t *testing.T) {
+	policy := api.RetryPolicy{InitialBackoff: time.Second, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		wait := Backoff(policy, 2)
+		require.GreaterOrEqual(t, wait, 1600*time.Millisecond)
+		require.LessOrEqual(t, wait, 2400*time.Millisecond)
+	}
+	require.Equal(t, 30*time.Second, Backoff(api.RetryPolicy{}, 10))
+}
@@ -0,0 +1,38 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/errorkind"
+)
+
+var classes = map[error]string{
+	api.ErrWrongOrder:         "wrong-order",
+	api.ErrAuthentication:     "auth",
+	api.ErrHostKeyRejected:    "host-key-rejected",
+	api.ErrRepositoryNotFound: "not-found",
+	api.ErrBranchNotFound:     "not-found",
+	api.ErrPushRejected:       "push-rejected",
+	api.ErrInvalidParameters:  "invalid-parameters",
+	api.ErrRenderFailed:       "render-failed",
+	api.ErrUnexpectedChanges:  "unexpected-changes",
+	api.ErrHookVeto:           "hook-veto",
+}
+
+// ErrorClass sorts errors into a few classes for metrics, so failures can be told apart
+// without their (unbounded) messages.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	if class, ok := classes[errorkind.Of(err)]; ok {
+		return class
+	}
+	return "other"
+}
@@ -0,0 +1,191 @@
+// Package telemetry records OpenTelemetry spans and metrics for the phases of a session.
+//
+// By default, the global providers of the otel package are used, at the time a span or measurement is
+// made. SetTracerProvider and SetMeterProvider override them for this library only.
+package telemetry
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const InstrumentationName = "github.com/mplushnikov/go-generator-git/v2"
+
+// attribute keys
+const (
+	KeyPhase        = attribute.Key("generator_git.phase")
+	KeyOutcome      = attribute.Key("generator_git.outcome")
+	KeyErrorClass   = attribute.Key("generator_git.error.class")
+	KeyRepoUrl      = attribute.Key("generator_git.repo.url")
+	KeyBranch       = attribute.Key("generator_git.branch")
+	KeyGenerator    = attribute.Key("generator_git.generator")
+	KeyRenderSpec   = attribute.Key("generator_git.render_spec_file")
+	KeyOperation    = attribute.Key("generator_git.operation")
+	KeyRemote       = attribute.Key("generator_git.remote")
+	KeyFilesCount   = attribute.Key("generator_git.files.rendered")
+	KeyPrunedCount  = attribute.Key("generator_git.files.pruned")
+	KeyConflicts    = attribute.Key("generator_git.files.conflicts")
+	KeyCommitHash   = attribute.Key("generator_git.commit.hash")
+	KeyPushed       = attribute.Key("generator_git.pushed")
+	KeyTransferSize = attribute.Key("generator_git.transfer.bytes")
+)
+
+var (
+	mu             sync.Mutex
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	instrumentsFor metric.MeterProvider
+	cached         *instruments
+)
+
+// SetTracerProvider overrides the tracer provider, nil goes back to the global one.
+func SetTracerProvider(provider trace.TracerProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	tracerProvider = provider
+}
+
+// SetMeterProvider overrides the meter provider, nil goes back to the global one.
+func SetMeterProvider(provider metric.MeterProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	meterProvider = provider
+}
+
+func tracer() trace.Tracer {
+	mu.Lock()
+	provider := tracerProvider
+	mu.Unlock()
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(InstrumentationName)
+}
+
+type instruments struct {
+	phaseDuration metric.Float64Histogram
+	transferBytes metric.Int64Counter
+	failures      metric.Int64Counter
+	commits       metric.Int64Counter
+}
+
+// metrics returns the instruments of the current meter provider, creating them when it changed.
+func metrics() *instruments {
+	mu.Lock()
+	defer mu.Unlock()
+	provider := meterProvider
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	if cached != nil && instrumentsFor == provider {
+		return cached
+	}
+
+	meter := provider.Meter(InstrumentationName)
+	i := &instruments{}
+	// errors only come from invalid instrument definitions, and leave a working no-op instrument
+	i.phaseDuration, _ = meter.Float64Histogram("generator_git.phase.duration",
+		metric.WithDescription("Duration of the phases of a session"), metric.WithUnit("s"))
+	i.transferBytes, _ = meter.Int64Counter("generator_git.transfer.bytes",
+		metric.WithDescription("Bytes of git objects received by clones"), metric.WithUnit("By"))
+	i.failures, _ = meter.Int64Counter("generator_git.failures",
+		metric.WithDescription("Failed phases, by error class"))
+	i.commits, _ = meter.Int64Counter("generator_git.commits",
+		metric.WithDescription("Commits created in target repositories"))
+	instrumentsFor, cached = provider, i
+	return i
+}
+
+// Phase is a span for a phase of the session, which also records the phase metrics when it ends.
+type Phase struct {
+	name    string
+	started time.Time
+	span    trace.Span
+	ctx     context.Context
+}
+
+// StartPhase starts a span named after the phase, see the Phase* constants in package api.
+func StartPhase(ctx context.Context, phase string, attrs ...attribute.KeyValue) (context.Context, *Phase) {
+	ctx, span := tracer().Start(ctx, "generator-git "+phase, trace.WithAttributes(append(attrs, KeyPhase.String(phase))...))
+	return ctx, &Phase{name: phase, started: time.Now(), span: span, ctx: ctx}
+}
+
+func (p *Phase) SetAttributes(attrs ...attribute.KeyValue) {
+	p.span.SetAttributes(attrs...)
+}
+
+// End ends the span, with an error status if err is not nil, and records the duration and failure metrics.
+func (p *Phase) End(err error) {
+	outcome := "success"
+	attrs := []attribute.KeyValue{KeyPhase.String(p.name)}
+	if err != nil {
+		outcome = "failure"
+		class := ErrorClass(err)
+		p.span.RecordError(err)
+		p.span.SetStatus(codes.Error, err.Error())
+		p.span.SetAttributes(KeyErrorClass.String(class))
+		metrics().failures.Add(p.ctx, 1, metric.WithAttributes(KeyPhase.String(p.name), KeyErrorClass.String(class)))
+	}
+	metrics().phaseDuration.Record(p.ctx, time.Since(p.started).Seconds(), metric.WithAttributes(append(attrs, KeyOutcome.String(outcome))...))
+	p.span.End()
+}
+
+// Span starts a span for a step within a phase. End it with EndSpan.
+func Span(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "generator-git "+name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan ends a span started with Span, with an error status if err is not nil.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(KeyErrorClass.String(ErrorClass(err)))
+	}
+	span.End()
+}
+
+// RecordTransfer counts bytes received by a git operation.
+func RecordTransfer(ctx context.Context, operation string, bytes int64) {
+	trace.SpanFromContext(ctx).SetAttributes(KeyTransferSize.Int64(bytes))
+	metrics().transferBytes.Add(ctx, bytes, metric.WithAttributes(KeyOperation.String(operation)))
+}
+
+// RecordCommit counts a commit created in a target repository.
+func RecordCommit(ctx context.Context, pushed bool) {
+	metrics().commits.Add(ctx, 1, metric.WithAttributes(KeyPushed.Bool(pushed)))
+}
+
+// SafeUrl removes credentials, query and fragment from a repository url, so it can go into telemetry.
+// scp-like urls (user@host:path) are kept, as they contain no secrets.
+func SafeUrl(repoUrl string) string {
+	parsed, err := url.Parse(repoUrl)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return repoUrl
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// ObjectsSize is the size of the git objects of a fresh clone, which is about what was received.
+func ObjectsSize(repoPath string) int64 {
+	var size int64
+	_ = filepath.Walk(filepath.Join(repoPath, ".git", "objects"), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
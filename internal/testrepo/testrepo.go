@@ -0,0 +1,79 @@
+// Package testrepo creates local git repositories for tests, so they need no git server.
+package testrepo
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Create creates a bare repository with a single commit on branch main containing the given files,
+// and returns its path, which works as a clone url.
+func Create(t *testing.T, files map[string]string) string {
+	seedPath := filepath.Join(t.TempDir(), "seed")
+	seed, err := git.PlainInit(seedPath, false)
+	require.Nil(t, err)
+	require.Nil(t, seed.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))))
+	CommitFiles(t, seed, seedPath, files, "initial commit")
+
+	remotePath := filepath.Join(t.TempDir(), "remote.git")
+	_, err = git.PlainClone(remotePath, true, &git.CloneOptions{URL: seedPath})
+	require.Nil(t, err)
+	return remotePath
+}
+
+// PushCommit adds a commit to branch main of a bare repository created by Create, and returns its hash.
+//
+// Files with empty content are deleted.
+func PushCommit(t *testing.T, remotePath string, files map[string]string, message string) string {
+	clonePath := filepath.Join(t.TempDir(), "clone")
+	repo, err := git.PlainClone(clonePath, false, &git.CloneOptions{URL: remotePath})
+	require.Nil(t, err)
+	hash := CommitFiles(t, repo, clonePath, files, message)
+	require.Nil(t, repo.Push(&git.PushOptions{}))
+	return hash
+}
+
+// CommitFiles writes the files into the worktree of repo at path and commits them. Files with empty
+// content are deleted.
+func CommitFiles(t *testing.T, repo *git.Repository, path string, files map[string]string, message string) string {
+	worktree, err := repo.Worktree()
+	require.Nil(t, err)
+	for name, contents := range files {
+		if contents == "" {
+			_, err = worktree.Remove(name)
+			require.Nil(t, err)
+			continue
+		}
+		fullPath := filepath.Join(path, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.Nil(t, os.WriteFile(fullPath, []byte(contents), 0644))
+		_, err = worktree.Add(name)
+		require.Nil(t, err)
+	}
+	hash, err := worktree.Commit(message, &git.CommitOptions{Author: &object.Signature{Name: "fixture", Email: "fixture@example.com", When: time.Now()}})
+	require.Nil(t, err)
+	return hash.String()
+}
+
+// ReadFile reads a file from the tip of a branch in a repository, returning "" if it does not exist.
+func ReadFile(t *testing.T, repoPath string, branch string, name string) string {
+	repo, err := git.PlainOpen(repoPath)
+	require.Nil(t, err)
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	require.Nil(t, err)
+	commit, err := repo.CommitObject(ref.Hash())
+	require.Nil(t, err)
+	file, err := commit.File(name)
+	if err != nil {
+		return ""
+	}
+	contents, err := file.Contents()
+	require.Nil(t, err)
+	return contents
+}
@@ -0,0 +1,113 @@
+package textdiff
+
+import (
+	"sort"
+	"strings"
+)
+
+type sideHunk struct {
+	Hunk
+	side int
+}
+
+// Merge3 merges the changes that turned base into ours and into theirs, line by line.
+//
+// Where both sides changed the same lines differently, the result contains both versions between
+// git style conflict markers, labelled with oursLabel and theirsLabel. The number of conflicting
+// regions is returned alongside the merged text.
+func Merge3(base []byte, ours []byte, theirs []byte, oursLabel string, theirsLabel string) ([]byte, int) {
+	baseLines := Lines(base)
+	sides := [2][]string{Lines(ours), Lines(theirs)}
+
+	var hunks []sideHunk
+	for side := range sides {
+		for _, h := range Diff(baseLines, sides[side]) {
+			hunks = append(hunks, sideHunk{Hunk: h, side: side})
+		}
+	}
+	sort.SliceStable(hunks, func(i, j int) bool {
+		if hunks[i].AStart != hunks[j].AStart {
+			return hunks[i].AStart < hunks[j].AStart
+		}
+		return hunks[i].AEnd < hunks[j].AEnd
+	})
+
+	var result []string
+	conflicts := 0
+	basePos := 0
+	for i := 0; i < len(hunks); {
+		regionStart := hunks[i].AStart
+		regionEnd := hunks[i].AEnd
+		j := i + 1
+		for j < len(hunks) && hunks[j].AStart <= regionEnd {
+			if hunks[j].AEnd > regionEnd {
+				regionEnd = hunks[j].AEnd
+			}
+			j++
+		}
+		region := hunks[i:j]
+
+		result = append(result, baseLines[basePos:regionStart]...)
+
+		var contents [2][]string
+		var changed [2]bool
+		for side := range sides {
+			var first, last *sideHunk
+			for k := range region {
+				if region[k].side == side {
+					if first == nil {
+						first = &region[k]
+					}
+					last = &region[k]
+				}
+			}
+			if first == nil {
+				contents[side] = baseLines[regionStart:regionEnd]
+				continue
+			}
+			changed[side] = true
+			contents[side] = sides[side][first.BStart-(first.AStart-regionStart) : last.BEnd+(regionEnd-last.AEnd)]
+		}
+
+		switch {
+		case !changed[1]:
+			result = append(result, contents[0]...)
+		case !changed[0] || equalLines(contents[0], contents[1]):
+			result = append(result, contents[1]...)
+		default:
+			conflicts++
+			result = append(result, "<<<<<<< "+oursLabel+"\n")
+			result = appendTerminated(result, contents[0])
+			result = append(result, "=======\n")
+			result = appendTerminated(result, contents[1])
+			result = append(result, ">>>>>>> "+theirsLabel+"\n")
+		}
+
+		basePos = regionEnd
+		i = j
+	}
+	result = append(result, baseLines[basePos:]...)
+
+	return Join(result), conflicts
+}
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendTerminated makes sure a conflict marker following the lines starts on a line of its own.
+func appendTerminated(result []string, lines []string) []string {
+	result = append(result, lines...)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		result[len(result)-1] += "\n"
+	}
+	return result
+}
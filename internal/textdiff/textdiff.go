@@ -0,0 +1,192 @@
+package textdiff
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Lines splits text into lines, keeping the line terminators, so joining them reproduces the text exactly.
+func Lines(text []byte) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	parts := strings.SplitAfter(string(text), "\n")
+	if parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return parts
+}
+
+func Join(lines []string) []byte {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(l)
+	}
+	return buf.Bytes()
+}
+
+// Hunk describes a change that replaces the lines a[AStart:AEnd] by the lines b[BStart:BEnd].
+type Hunk struct {
+	AStart int
+	AEnd   int
+	BStart int
+	BEnd   int
+}
+
+// maxEditCost bounds the work of one Diff, counted in steps along the diagonals of the edit graph.
+// Inputs that need more are treated as replaced as a whole, so Merge3 reports them as a single conflict
+// instead of taking forever.
+var maxEditCost = 1 << 24
+
+// Diff computes the hunks that turn a into b, in ascending order.
+func Diff(a []string, b []string) []Hunk {
+	// common prefix and suffix do not need to go through the expensive part
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	matches, ok := lcs(a, b)
+	if !ok {
+		return []Hunk{{AStart: prefix, AEnd: prefix + len(a), BStart: prefix, BEnd: prefix + len(b)}}
+	}
+
+	var hunks []Hunk
+	aPos, bPos := 0, 0
+	for _, m := range append(matches, match{len(a), len(b)}) {
+		if m.a > aPos || m.b > bPos {
+			hunks = append(hunks, Hunk{AStart: prefix + aPos, AEnd: prefix + m.a, BStart: prefix + bPos, BEnd: prefix + m.b})
+		}
+		aPos, bPos = m.a+1, m.b+1
+	}
+	return hunks
+}
+
+type match struct {
+	a int
+	b int
+}
+
+// differ finds a longest common subsequence with the linear space variant of the Myers algorithm,
+// which splits the problem at the middle snake of an optimal path and recurses into both halves.
+type differ struct {
+	a      []string
+	b      []string
+	vf     []int
+	vb     []int
+	budget int
+
+	matches []match
+}
+
+// lcs returns the matching line pairs of a longest common subsequence, in ascending order, or false
+// if finding it would exceed maxEditCost.
+func lcs(a []string, b []string) ([]match, bool) {
+	size := len(a) + len(b) + 3
+	d := &differ{a: a, b: b, vf: make([]int, size), vb: make([]int, size), budget: maxEditCost}
+	if !d.compare(0, len(a), 0, len(b)) {
+		return nil, false
+	}
+	return d.matches, true
+}
+
+func (d *differ) compare(aLo int, aHi int, bLo int, bHi int) bool {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.matches = append(d.matches, match{aLo, bLo})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-1-suffix] == d.b[bHi-1-suffix] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	if aLo < aHi && bLo < bHi {
+		x, y, u, v, ok := d.middleSnake(aLo, aHi, bLo, bHi)
+		if !ok || !d.compare(aLo, x, bLo, y) {
+			return false
+		}
+		for ; x < u; x, y = x+1, y+1 {
+			d.matches = append(d.matches, match{x, y})
+		}
+		if !d.compare(u, aHi, v, bHi) {
+			return false
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.matches = append(d.matches, match{aHi + i, bHi + i})
+	}
+	return true
+}
+
+// middleSnake runs the search from both corners of the edit graph until the paths meet, and returns the
+// diagonal run (x, y) to (u, v) in the middle of an optimal path, in the coordinates of a and b.
+func (d *differ) middleSnake(aLo int, aHi int, bLo int, bHi int) (x int, y int, u int, v int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	// diagonal k is at index k+offset, and k-1 and k+1 are read for all k in [-maxD, maxD]
+	offset := maxD + 1
+	d.vf[offset+1] = 0
+	d.vb[offset+1] = 0
+
+	for e := 0; e <= maxD; e++ {
+		d.budget -= 2*e + 2
+		if d.budget < 0 {
+			return 0, 0, 0, 0, false
+		}
+
+		// forward, vf holds the furthest x reached on each diagonal k = x - y
+		for k := -e; k <= e; k += 2 {
+			var fx int
+			if k == -e || (k != e && d.vf[offset+k-1] < d.vf[offset+k+1]) {
+				fx = d.vf[offset+k+1]
+			} else {
+				fx = d.vf[offset+k-1] + 1
+			}
+			fy := fx - k
+			startX, startY := fx, fy
+			for fx < n && fy < m && d.a[aLo+fx] == d.b[bLo+fy] {
+				fx++
+				fy++
+			}
+			d.budget -= fx - startX
+			d.vf[offset+k] = fx
+			// the backward search is at e-1, and has reached diagonal k if it is within its range
+			if back := delta - k; odd && back >= -(e-1) && back <= e-1 && fx+d.vb[offset+back] >= n {
+				return aLo + startX, bLo + startY, aLo + fx, bLo + fy, true
+			}
+		}
+
+		// backward, on the reversed inputs, so vb holds how far from the end each diagonal got
+		for k := -e; k <= e; k += 2 {
+			var bx int
+			if k == -e || (k != e && d.vb[offset+k-1] < d.vb[offset+k+1]) {
+				bx = d.vb[offset+k+1]
+			} else {
+				bx = d.vb[offset+k-1] + 1
+			}
+			by := bx - k
+			startX, startY := bx, by
+			for bx < n && by < m && d.a[aHi-1-bx] == d.b[bHi-1-by] {
+				bx++
+				by++
+			}
+			d.budget -= bx - startX
+			d.vb[offset+k] = bx
+			if forward := delta - k; !odd && forward >= -e && forward <= e && bx+d.vf[offset+forward] >= n {
+				return aHi - bx, bHi - by, aHi - startX, bHi - startY, true
+			}
+		}
+	}
+	// not reached, the paths always meet by maxD
+	return 0, 0, 0, 0, false
+}
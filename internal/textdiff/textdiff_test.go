@@ -0,0 +1,159 @@
+package textdiff
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func lines(s ...string) []byte {
+	return []byte(strings.Join(s, "\n") + "\n")
+}
+
+func TestLines(t *testing.T) {
+	require.Nil(t, Lines(nil))
+	require.Equal(t, []string{"a\n", "b"}, Lines([]byte("a\nb")))
+	require.Equal(t, "a\nb\n", string(Join(Lines([]byte("a\nb\n")))))
+}
+
+func TestDiff(t *testing.T) {
+	a := Lines(lines("a", "b", "c", "d", "e"))
+	b := Lines(lines("a", "x", "c", "e", "f"))
+	require.Equal(t, []Hunk{
+		{AStart: 1, AEnd: 2, BStart: 1, BEnd: 2},
+		{AStart: 3, AEnd: 4, BStart: 3, BEnd: 3},
+		{AStart: 5, AEnd: 5, BStart: 4, BEnd: 5},
+	}, Diff(a, b))
+	require.Nil(t, Diff(a, a))
+	require.Equal(t, []Hunk{{AStart: 0, AEnd: 0, BStart: 0, BEnd: 5}}, Diff(nil, a))
+}
+
+// lcsLength is the textbook quadratic solution, to check that Diff finds a minimal edit script.
+func lcsLength(a []string, b []string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else if table[i+1][j] > table[i][j+1] {
+				table[i][j] = table[i+1][j]
+			} else {
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+	return table[0][0]
+}
+
+func TestDiff_Minimal(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		result := make([]string, random.Intn(30))
+		for i := range result {
+			result[i] = string(rune('a' + random.Intn(4)))
+		}
+		return result
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		var patched []string
+		changed, aPos := 0, 0
+		for _, h := range Diff(a, b) {
+			patched = append(append(patched, a[aPos:h.AStart]...), b[h.BStart:h.BEnd]...)
+			changed += h.AEnd - h.AStart + h.BEnd - h.BStart
+			aPos = h.AEnd
+		}
+		patched = append(patched, a[aPos:]...)
+		require.Equal(t, strings.Join(b, ""), strings.Join(patched, ""), "%v %v", a, b)
+		require.Equal(t, len(a)+len(b)-2*lcsLength(a, b), changed, "%v %v", a, b)
+	}
+}
+
+func TestDiff_LargeRewrite(t *testing.T) {
+	a := make([]string, 5000)
+	b := make([]string, 5000)
+	for i := range a {
+		a[i] = fmt.Sprintf("old %d\n", i)
+		b[i] = fmt.Sprintf("new %d\n", i)
+	}
+	require.Equal(t, []Hunk{{AStart: 0, AEnd: 5000, BStart: 0, BEnd: 5000}}, Diff(a, b))
+}
+
+func TestDiff_TooExpensive(t *testing.T) {
+	defer func(previous int) { maxEditCost = previous }(maxEditCost)
+	maxEditCost = 100
+
+	var a, b []string
+	for i := 0; i < 10; i++ {
+		a = append(a, fmt.Sprintf("old %d\n", i), "common\n")
+		b = append(b, fmt.Sprintf("new %d\n", i), "common\n")
+	}
+	require.Equal(t, []Hunk{{AStart: 0, AEnd: 19, BStart: 0, BEnd: 19}}, Diff(a, b))
+
+	merged, conflicts := Merge3(Join(a), Join(a), Join(b), "target", "generated")
+	require.Equal(t, 0, conflicts)
+	require.Equal(t, Join(b), merged)
+
+	ours := append([]string{"human\n"}, a[1:]...)
+	merged, conflicts = Merge3(Join(a), Join(ours), Join(b), "target", "generated")
+	require.Equal(t, 1, conflicts)
+	require.True(t, strings.HasPrefix(string(merged), "<<<<<<< target\nhuman\n"))
+}
+
+func TestMerge3_NonOverlapping(t *testing.T) {
+	base := lines("package main", "", "func a() {}", "", "func b() {}")
+	ours := lines("package main", "", "func a() { human() }", "", "func b() {}")
+	theirs := lines("package main", "", "func a() {}", "", "func b() {}", "", "func c() {}")
+
+	merged, conflicts := Merge3(base, ours, theirs, "target", "generated")
+	require.Equal(t, 0, conflicts)
+	require.Equal(t, string(lines("package main", "", "func a() { human() }", "", "func b() {}", "", "func c() {}")), string(merged))
+}
+
+func TestMerge3_SameChangeOnBothSides(t *testing.T) {
+	base := lines("a", "b", "c")
+	both := lines("a", "x", "c")
+
+	merged, conflicts := Merge3(base, both, both, "target", "generated")
+	require.Equal(t, 0, conflicts)
+	require.Equal(t, string(both), string(merged))
+}
+
+func TestMerge3_Conflict(t *testing.T) {
+	base := lines("a", "b", "c")
+	ours := lines("a", "human", "c")
+	theirs := lines("a", "generated", "c")
+
+	merged, conflicts := Merge3(base, ours, theirs, "target", "generated")
+	require.Equal(t, 1, conflicts)
+	require.Equal(t, string(lines("a", "<<<<<<< target", "human", "=======", "generated", ">>>>>>> generated", "c")), string(merged))
+}
+
+func TestMerge3_ConflictWithoutTrailingNewline(t *testing.T) {
+	merged, conflicts := Merge3([]byte("a"), []byte("b"), []byte("c"), "target", "generated")
+	require.Equal(t, 1, conflicts)
+	require.Equal(t, "<<<<<<< target\nb\n=======\nc\n>>>>>>> generated\n", string(merged))
+}
+
+func TestUnified(t *testing.T) {
+	a := lines("a", "b", "c", "d", "e", "f", "g", "h", "i", "j")
+	b := lines("a", "B", "c", "d", "e", "f", "g", "h", "i", "j", "k")
+	require.Equal(t, `--- a/file
++++ b/file
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+@@ -10 +10,2 @@
+ j
++k
+`, string(Unified("a/file", "b/file", a, b, 1)))
+	require.Nil(t, Unified("a", "b", a, a, 3))
+	require.Equal(t, "--- /dev/null\n+++ b/new\n@@ -0,0 +1 @@\n+x\n", string(Unified("/dev/null", "b/new", nil, []byte("x\n"), 3)))
+}
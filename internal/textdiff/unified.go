@@ -0,0 +1,81 @@
+package textdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Unified formats the changes that turn a into b as a unified diff with the given number of context lines.
+//
+// Returns an empty result if a and b are equal.
+func Unified(aName string, bName string, a []byte, b []byte, context int) []byte {
+	aLines := Lines(a)
+	bLines := Lines(b)
+	hunks := Diff(aLines, bLines)
+	if len(hunks) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(hunks); {
+		// merge hunks whose context would overlap
+		end := start + 1
+		for end < len(hunks) && hunks[end].AStart-hunks[end-1].AEnd <= 2*context {
+			end++
+		}
+		first, last := hunks[start], hunks[end-1]
+		aFrom := max(first.AStart-context, 0)
+		aTo := min(last.AEnd+context, len(aLines))
+		bFrom := first.BStart - (first.AStart - aFrom)
+		bTo := last.BEnd + (aTo - last.AEnd)
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", rangeHeader(aFrom, aTo), rangeHeader(bFrom, bTo))
+		pos := aFrom
+		for _, h := range hunks[start:end] {
+			writeLines(&buf, " ", aLines[pos:h.AStart])
+			writeLines(&buf, "-", aLines[h.AStart:h.AEnd])
+			writeLines(&buf, "+", bLines[h.BStart:h.BEnd])
+			pos = h.AEnd
+		}
+		writeLines(&buf, " ", aLines[pos:aTo])
+		start = end
+	}
+	return buf.Bytes()
+}
+
+func rangeHeader(from int, to int) string {
+	count := to - from
+	if count == 0 {
+		return fmt.Sprintf("%d,0", from)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", from+1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, count)
+}
+
+func writeLines(buf *bytes.Buffer, prefix string, lines []string) {
+	for _, l := range lines {
+		buf.WriteString(prefix)
+		buf.WriteString(l)
+		if !strings.HasSuffix(l, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
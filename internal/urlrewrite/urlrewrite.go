@@ -0,0 +1,63 @@
+package urlrewrite
+
+import (
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"sort"
+	"strings"
+)
+
+// Rules rewrites urls as described by api.UrlRewriting. A nil *Rules leaves urls unchanged.
+type Rules struct {
+	aliases   map[string]string
+	insteadOf []prefixRule
+}
+
+type prefixRule struct {
+	prefix      string
+	replacement string
+}
+
+// New validates the rules. Returns nil for nil rules.
+func New(rewriting *api.UrlRewriting) (*Rules, error) {
+	if rewriting == nil {
+		return nil, nil
+	}
+	r := &Rules{aliases: make(map[string]string)}
+	for alias, url := range rewriting.Aliases {
+		if alias == "" || url == "" {
+			return nil, fmt.Errorf("invalid alias '%s' for '%s', both must be set", alias, url)
+		}
+		r.aliases[alias] = url
+	}
+	for prefix, replacement := range rewriting.InsteadOf {
+		if prefix == "" {
+			return nil, fmt.Errorf("invalid insteadOf rule for '%s', the prefix must be set", replacement)
+		}
+		r.insteadOf = append(r.insteadOf, prefixRule{prefix: prefix, replacement: replacement})
+	}
+	// longest prefix first, as with git
+	sort.Slice(r.insteadOf, func(i, j int) bool {
+		if len(r.insteadOf[i].prefix) != len(r.insteadOf[j].prefix) {
+			return len(r.insteadOf[i].prefix) > len(r.insteadOf[j].prefix)
+		}
+		return r.insteadOf[i].prefix < r.insteadOf[j].prefix
+	})
+	return r, nil
+}
+
+// Rewrite resolves an alias, then replaces the longest matching insteadOf prefix.
+func (r *Rules) Rewrite(url string) string {
+	if r == nil {
+		return url
+	}
+	if aliased, ok := r.aliases[url]; ok {
+		url = aliased
+	}
+	for _, rule := range r.insteadOf {
+		if strings.HasPrefix(url, rule.prefix) {
+			return rule.replacement + strings.TrimPrefix(url, rule.prefix)
+		}
+	}
+	return url
+}
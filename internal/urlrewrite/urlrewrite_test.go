@@ -0,0 +1,42 @@
+package urlrewrite
+
+import (
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	r, err := New(&api.UrlRewriting{
+		Aliases: map[string]string{
+			"templates/go-rest": "https://github.com/some-org/tpl-go-rest",
+			"internal/service":  "ssh://git@git.corp.example.com/team/service.git",
+		},
+		InsteadOf: map[string]string{
+			"https://github.com/":          "ssh://git@mirror.corp.example.com/github/",
+			"https://github.com/some-org/": "ssh://git@mirror.corp.example.com/some-org/",
+		},
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, "ssh://git@mirror.corp.example.com/some-org/tpl-go-rest", r.Rewrite("templates/go-rest"))
+	require.Equal(t, "ssh://git@mirror.corp.example.com/github/other/repo", r.Rewrite("https://github.com/other/repo"))
+	require.Equal(t, "ssh://git@git.corp.example.com/team/service.git", r.Rewrite("internal/service"))
+	require.Equal(t, "https://gitlab.com/other/repo", r.Rewrite("https://gitlab.com/other/repo"))
+	// aliases only match whole urls
+	require.Equal(t, "templates/go-rest/sub", r.Rewrite("templates/go-rest/sub"))
+
+	var none *Rules
+	require.Equal(t, "templates/go-rest", none.Rewrite("templates/go-rest"))
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(&api.UrlRewriting{InsteadOf: map[string]string{"": "https://example.com/"}})
+	require.NotNil(t, err)
+	_, err = New(&api.UrlRewriting{Aliases: map[string]string{"short": ""}})
+	require.NotNil(t, err)
+
+	r, err := New(nil)
+	require.Nil(t, err)
+	require.Nil(t, r)
+}
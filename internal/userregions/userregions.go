@@ -0,0 +1,146 @@
+package userregions
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/internal/textdiff"
+	"strings"
+)
+
+const (
+	BeginMarker = "BEGIN USER CODE:"
+	EndMarker   = "END USER CODE"
+)
+
+// Region is the content between a begin and an end marker, markers excluded.
+type Region struct {
+	Name    string
+	Content []byte
+}
+
+// Contains is a quick check whether a file has any user regions at all.
+func Contains(contents []byte) bool {
+	return bytes.Contains(contents, []byte(BeginMarker))
+}
+
+// UnterminatedError reports a region without an end marker, or with the begin marker of another region
+// before its end marker. Guessing where such a region ends would either take generated code for user code,
+// or drop the rest of the file.
+type UnterminatedError struct {
+	Name string
+
+	// 1-based line of the begin marker
+	Line int
+}
+
+func (e *UnterminatedError) Error() string {
+	return fmt.Sprintf("user region %s starting in line %d has no '%s' marker", e.Name, e.Line, EndMarker)
+}
+
+// span is a region with the indices of its marker lines.
+type span struct {
+	name  string
+	begin int
+	end   int
+}
+
+func parse(lines []string) ([]span, error) {
+	var spans []span
+	var current *span
+	for i, line := range lines {
+		if name, ok := beginName(line); ok {
+			if current != nil {
+				return nil, &UnterminatedError{Name: current.name, Line: current.begin + 1}
+			}
+			current = &span{name: name, begin: i}
+		} else if current != nil && isEnd(line) {
+			current.end = i
+			spans = append(spans, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		return nil, &UnterminatedError{Name: current.name, Line: current.begin + 1}
+	}
+	return spans, nil
+}
+
+// Extract returns the user regions of a file in the order they appear.
+//
+// Markers may be embedded in any comment syntax, e.g. "// BEGIN USER CODE: handlers" or
+// "<!-- BEGIN USER CODE: head -->". The region name is the first word after the colon.
+// If a name occurs more than once, only its first region is returned. Unterminated regions
+// give an *UnterminatedError.
+func Extract(contents []byte) ([]Region, error) {
+	lines := textdiff.Lines(contents)
+	spans, err := parse(lines)
+	if err != nil {
+		return nil, err
+	}
+	var regions []Region
+	seen := make(map[string]bool)
+	for _, s := range spans {
+		if seen[s.name] {
+			continue
+		}
+		seen[s.name] = true
+		regions = append(regions, Region{Name: s.name, Content: textdiff.Join(lines[s.begin+1 : s.end])})
+	}
+	return regions, nil
+}
+
+// Splice replaces the content of the user regions in generated with the captured regions of the same name.
+//
+// Regions in generated that were not captured keep their generated content. Returns the result and the
+// captured regions that did not find a region of the same name in generated. Unterminated regions in
+// generated give an *UnterminatedError.
+func Splice(generated []byte, captured []Region) ([]byte, []Region, error) {
+	lines := textdiff.Lines(generated)
+	spans, err := parse(lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := make(map[string]Region)
+	for _, r := range captured {
+		byName[r.Name] = r
+	}
+
+	used := make(map[string]bool)
+	var result []string
+	pos := 0
+	for _, s := range spans {
+		r, found := byName[s.name]
+		if !found || used[s.name] {
+			continue
+		}
+		used[s.name] = true
+		result = append(result, lines[pos:s.begin+1]...)
+		result = append(result, textdiff.Lines(r.Content)...)
+		pos = s.end
+	}
+	result = append(result, lines[pos:]...)
+
+	var orphaned []Region
+	for _, r := range captured {
+		if !used[r.Name] {
+			orphaned = append(orphaned, r)
+		}
+	}
+	return textdiff.Join(result), orphaned, nil
+}
+
+func beginName(line string) (string, bool) {
+	idx := strings.Index(line, BeginMarker)
+	if idx < 0 {
+		return "", false
+	}
+	fields := strings.Fields(line[idx+len(BeginMarker):])
+	if len(fields) == 0 {
+		return "", false
+	}
+	return fields[0], true
+}
+
+func isEnd(line string) bool {
+	return strings.Contains(line, EndMarker)
+}
@@ -0,0 +1,90 @@
+package userregions
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const existing = `package main
+
+// BEGIN USER CODE: imports
+import "fmt"
+// END USER CODE
+
+func main() {
+	// BEGIN USER CODE: main
+	fmt.Println("hello")
+	// END USER CODE
+}
+`
+
+func extract(t *testing.T, contents string) []Region {
+	regions, err := Extract([]byte(contents))
+	require.Nil(t, err)
+	return regions
+}
+
+func TestExtract(t *testing.T) {
+	require.Equal(t, []Region{
+		{Name: "imports", Content: []byte("import \"fmt\"\n")},
+		{Name: "main", Content: []byte("\tfmt.Println(\"hello\")\n")},
+	}, extract(t, existing))
+	require.Nil(t, extract(t, "no regions\n"))
+	require.Equal(t, []Region{{Name: "head", Content: []byte("<meta>\n")}},
+		extract(t, "<!-- BEGIN USER CODE: head -->\n<meta>\n<!-- END USER CODE -->\n"))
+}
+
+func TestExtract_Unterminated(t *testing.T) {
+	missingEnd := "// BEGIN USER CODE: main\nhuman()\ngenerated()\n"
+	_, err := Extract([]byte(missingEnd))
+	require.Equal(t, &UnterminatedError{Name: "main", Line: 1}, err)
+
+	endOfNextRegion := "// BEGIN USER CODE: imports\nimport \"fmt\"\nfunc main() {\n// BEGIN USER CODE: main\n// END USER CODE\n}\n"
+	_, err = Extract([]byte(endOfNextRegion))
+	require.Equal(t, &UnterminatedError{Name: "imports", Line: 1}, err)
+}
+
+func TestSplice(t *testing.T) {
+	generated := `package main
+
+// BEGIN USER CODE: imports
+// END USER CODE
+
+func main() {
+	// BEGIN USER CODE: main
+	// put your code here
+	// END USER CODE
+	generated()
+}
+`
+	spliced, orphaned, err := Splice([]byte(generated), extract(t, existing))
+	require.Nil(t, err)
+	require.Empty(t, orphaned)
+	require.Equal(t, `package main
+
+// BEGIN USER CODE: imports
+import "fmt"
+// END USER CODE
+
+func main() {
+	// BEGIN USER CODE: main
+	fmt.Println("hello")
+	// END USER CODE
+	generated()
+}
+`, string(spliced))
+}
+
+func TestSplice_Orphaned(t *testing.T) {
+	generated := "// BEGIN USER CODE: imports\n// END USER CODE\n"
+	spliced, orphaned, err := Splice([]byte(generated), extract(t, existing))
+	require.Nil(t, err)
+	require.Equal(t, "// BEGIN USER CODE: imports\nimport \"fmt\"\n// END USER CODE\n", string(spliced))
+	require.Equal(t, []Region{{Name: "main", Content: []byte("\tfmt.Println(\"hello\")\n")}}, orphaned)
+}
+
+func TestSplice_Unterminated(t *testing.T) {
+	generated := "func main() {\n\t// BEGIN USER CODE: main\n\t// put your code here\n}\n\nfunc generated() {}\n"
+	_, _, err := Splice([]byte(generated), extract(t, existing))
+	require.Equal(t, &UnterminatedError{Name: "main", Line: 2}, err)
+}
@@ -0,0 +1,575 @@
+// Package jobfile reads generator jobs from yaml files, so a repository describing which service uses
+// which generator with which parameters can drive regenerations.
+//
+//	defaults:
+//	  source:
+//	    url: https://github.com/some-org/generator-repo
+//	    ref: main
+//	  commit:
+//	    author_name: platform-bot
+//	    author_email: platform-bot@example.com
+//	jobs:
+//	  - name: order-service
+//	    target:
+//	      url: https://github.com/some-org/order-service
+//	      branch: main
+//	    generator: main
+//	    parameters:
+//	      serviceName: order-service
+//	    push: {}
+//	    timeouts:
+//	      commit-and-push: 5m
+//
+// Settings under defaults apply to every job that does not set them itself. A job turns off the push of
+// the defaults with push: false.
+package jobfile
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/urlrewrite"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type File struct {
+	Defaults Entry   `yaml:"defaults"`
+	Jobs     []Entry `yaml:"jobs"`
+}
+
+type Entry struct {
+	Name                string                 `yaml:"name"`
+	Source              Source                 `yaml:"source"`
+	Target              Target                 `yaml:"target"`
+	Generator           string                 `yaml:"generator"`
+	RenderSpecFile      string                 `yaml:"render_spec_file"`
+	Parameters          map[string]interface{} `yaml:"parameters"`
+	UpdateStrategy      string                 `yaml:"update_strategy"`
+	PruneOrphans        *bool                  `yaml:"prune_orphans"`
+	PreserveUserRegions *bool                  `yaml:"preserve_user_regions"`
+	Commit              *Commit                `yaml:"commit"`
+	Push                *Push                  `yaml:"push"`
+
+	// durations like 2m30s, keyed by phase, e.g. clone-target
+	Timeouts map[string]string `yaml:"timeouts"`
+
+	Retry *Retry `yaml:"retry"`
+
+	// http(s) settings per host, the first entry that matches a host applies
+	Transport []Transport `yaml:"transport"`
+
+	HostKeys *HostKeys `yaml:"host_keys"`
+
+	UrlRewriting *UrlRewriting `yaml:"url_rewriting"`
+}
+
+// Retry configures api.RetryPolicy, unset values take the defaults of api.DefaultRetryPolicy.
+type Retry struct {
+	MaxAttempts    int      `yaml:"max_attempts"`
+	InitialBackoff string   `yaml:"initial_backoff"`
+	MaxBackoff     string   `yaml:"max_backoff"`
+	Multiplier     float64  `yaml:"multiplier"`
+	Jitter         *float64 `yaml:"jitter"`
+}
+
+// Transport configures api.HostTransport. The files are read when the jobs are created.
+type Transport struct {
+	Host               string `yaml:"host"`
+	CAFile             string `yaml:"ca_file"`
+	ClientCertFile     string `yaml:"client_cert_file"`
+	ClientKeyFile      string `yaml:"client_key_file"`
+	Proxy              string `yaml:"proxy"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// HostKeys configures api.HostKeyVerification.
+type HostKeys struct {
+	// known_hosts lines
+	KnownHosts      string              `yaml:"known_hosts"`
+	KnownHostsFiles []string            `yaml:"known_hosts_files"`
+	Fingerprints    map[string][]string `yaml:"fingerprints"`
+	TrustOnFirstUse string              `yaml:"trust_on_first_use"`
+}
+
+// UrlRewriting configures api.UrlRewriting, so urls in the jobs may be aliases.
+type UrlRewriting struct {
+	Aliases   map[string]string `yaml:"aliases"`
+	InsteadOf map[string]string `yaml:"instead_of"`
+}
+
+type Source struct {
+	Url string `yaml:"url"`
+
+	// branch, tag or commit hash, see api.SourceSpec.Branch
+	Ref string `yaml:"ref"`
+}
+
+type Target struct {
+	Url        string `yaml:"url"`
+	Branch     string `yaml:"branch"`
+	BaseBranch string `yaml:"base_branch"`
+}
+
+type Commit struct {
+	AuthorName  string `yaml:"author_name"`
+	AuthorEmail string `yaml:"author_email"`
+	Message     string `yaml:"message"`
+	Staging     string `yaml:"staging"`
+	Tag         *Tag   `yaml:"tag"`
+}
+
+type Tag struct {
+	Name      string `yaml:"name"`
+	Message   string `yaml:"message"`
+	Overwrite bool   `yaml:"overwrite"`
+}
+
+type Push struct {
+	Policy  string   `yaml:"policy"`
+	Mirrors []Mirror `yaml:"mirrors"`
+
+	// set by push: false
+	Off bool `yaml:"-"`
+}
+
+// UnmarshalYAML also accepts push: true, which is the same as push: {}, and push: false, which lets a
+// job turn off the push of the defaults.
+func (p *Push) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var enabled bool
+	if err := unmarshal(&enabled); err == nil {
+		*p = Push{Off: !enabled}
+		return nil
+	}
+	type plain Push
+	return unmarshal((*plain)(p))
+}
+
+type Mirror struct {
+	Name string `yaml:"name"`
+	Url  string `yaml:"url"`
+}
+
+// ValidationError lists everything that is wrong with a job file.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid job file: " + strings.Join(e.Problems, "; ")
+}
+
+// AuthResolver returns the auth method to use for a repository url, or nil for none. It is given
+// the url after url_rewriting, because that is where the credentials are needed.
+//
+// Job files never contain credentials, so they have to come from somewhere else.
+type AuthResolver func(ctx context.Context, url string) (transport.AuthMethod, error)
+
+var updateStrategies = map[string]api.UpdateStrategy{
+	"":                api.UpdateOverwrite,
+	"overwrite":       api.UpdateOverwrite,
+	"merge":           api.UpdateMerge,
+	"pristine-branch": api.UpdatePristineBranch,
+}
+
+// ParseUpdateStrategy returns the strategy for a name as used in update_strategy, and false for unknown
+// names. The empty name is api.UpdateOverwrite.
+func ParseUpdateStrategy(name string) (api.UpdateStrategy, bool) {
+	strategy, ok := updateStrategies[name]
+	return strategy, ok
+}
+
+var stagingModes = map[string]api.StagingMode{
+	"":                      api.StageAll,
+	"all":                   api.StageAll,
+	"generated-only":        api.StageGeneratedOnly,
+	"generated-only-strict": api.StageGeneratedOnlyStrict,
+}
+
+var pushPolicies = map[string]api.PushPolicy{
+	"":    api.PushToAll,
+	"all": api.PushToAll,
+	"any": api.PushToAny,
+}
+
+var phases = map[string]bool{
+	api.PhaseCreateWorkdir:   true,
+	api.PhaseCloneSource:     true,
+	api.PhaseCloneTarget:     true,
+	api.PhaseWriteRenderSpec: true,
+	api.PhaseGenerate:        true,
+	api.PhaseCommitAndPush:   true,
+	api.PhaseCleanup:         true,
+}
+
+// Load reads and validates a job file.
+func Load(path string) (*File, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(contents)
+}
+
+// Parse reads and validates the contents of a job file. Unknown keys are an error.
+func Parse(contents []byte) (*File, error) {
+	f := &File{}
+	if err := yaml.UnmarshalStrict(contents, f); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// Validate checks every job, with defaults applied, and reports all problems at once.
+func (f *File) Validate() error {
+	var problems []string
+	if len(f.Jobs) == 0 {
+		problems = append(problems, "no jobs")
+	}
+	names := make(map[string]bool)
+	for i := range f.Jobs {
+		e := f.entry(i)
+		where := fmt.Sprintf("job %d", i+1)
+		if e.Name != "" {
+			where = fmt.Sprintf("job %d (%s)", i+1, e.Name)
+			if names[e.Name] {
+				problems = append(problems, where+": duplicate name")
+			}
+			names[e.Name] = true
+		}
+		problem := func(msg string) {
+			problems = append(problems, where+": "+msg)
+		}
+
+		if e.Source.Url == "" {
+			problem("source.url is required")
+		}
+		if e.Target.Url == "" {
+			problem("target.url is required")
+		}
+		if e.Target.Branch == "" {
+			problem("target.branch is required")
+		}
+		if e.Generator == "" {
+			problem("generator is required")
+		}
+		if e.RenderSpecFile != "" && !filepath.IsLocal(filepath.FromSlash(e.RenderSpecFile)) {
+			problem(fmt.Sprintf("render_spec_file '%s' is not a path inside the target", e.RenderSpecFile))
+		}
+		if _, ok := ParseUpdateStrategy(e.UpdateStrategy); !ok {
+			problem(fmt.Sprintf("unknown update_strategy '%s'", e.UpdateStrategy))
+		}
+		if e.Commit != nil {
+			if _, ok := stagingModes[e.Commit.Staging]; !ok {
+				problem(fmt.Sprintf("unknown commit.staging '%s'", e.Commit.Staging))
+			}
+			if e.Commit.Tag != nil && e.Commit.Tag.Name == "" {
+				problem("commit.tag.name is required")
+			}
+		}
+		if e.Push != nil {
+			if e.Commit == nil {
+				problem("push requires commit")
+			}
+			if _, ok := pushPolicies[e.Push.Policy]; !ok {
+				problem(fmt.Sprintf("unknown push.policy '%s'", e.Push.Policy))
+			}
+			for _, m := range e.Push.Mirrors {
+				if m.Name == "" || m.Url == "" {
+					problem("push.mirrors need a name and a url")
+				}
+			}
+		}
+		if e.Retry != nil {
+			if e.Retry.MaxAttempts < 0 {
+				problem("retry.max_attempts must not be negative")
+			}
+			for _, backoff := range [][2]string{{"initial_backoff", e.Retry.InitialBackoff}, {"max_backoff", e.Retry.MaxBackoff}} {
+				if d, err := time.ParseDuration(backoff[1]); backoff[1] != "" && (err != nil || d <= 0) {
+					problem(fmt.Sprintf("retry.%s: '%s' is not a positive duration", backoff[0], backoff[1]))
+				}
+			}
+			if e.Retry.Multiplier != 0 && e.Retry.Multiplier < 1 {
+				problem("retry.multiplier must be at least 1")
+			}
+			if e.Retry.Jitter != nil && (*e.Retry.Jitter < 0 || *e.Retry.Jitter > 1) {
+				problem("retry.jitter must be between 0 and 1")
+			}
+		}
+		for _, t := range e.Transport {
+			if _, err := path.Match(t.Host, ""); err != nil || t.Host == "" {
+				problem(fmt.Sprintf("transport: invalid host pattern '%s'", t.Host))
+			}
+			if (t.ClientCertFile == "") != (t.ClientKeyFile == "") {
+				problem(fmt.Sprintf("transport %s: client_cert_file and client_key_file go together", t.Host))
+			}
+			if proxy, err := url.Parse(t.Proxy); t.Proxy != "" && (err != nil || proxy.Scheme == "" || proxy.Host == "") {
+				problem(fmt.Sprintf("transport %s: invalid proxy '%s'", t.Host, t.Proxy))
+			}
+		}
+		if e.HostKeys != nil {
+			for _, pattern := range sortedKeys(e.HostKeys.Fingerprints) {
+				if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+					problem(fmt.Sprintf("host_keys.fingerprints: invalid host pattern '%s'", pattern))
+				}
+			}
+		}
+		if e.UrlRewriting != nil {
+			for _, alias := range sortedKeys(e.UrlRewriting.Aliases) {
+				if alias == "" || e.UrlRewriting.Aliases[alias] == "" {
+					problem(fmt.Sprintf("url_rewriting.aliases: '%s' needs a name and a url", alias))
+				}
+			}
+			if _, ok := e.UrlRewriting.InsteadOf[""]; ok {
+				problem("url_rewriting.instead_of: the prefix must not be empty")
+			}
+		}
+		for _, phase := range sortedKeys(e.Timeouts) {
+			value := e.Timeouts[phase]
+			if !phases[phase] {
+				problem(fmt.Sprintf("timeouts: unknown phase '%s'", phase))
+			} else if timeout, err := time.ParseDuration(value); err != nil || timeout <= 0 {
+				problem(fmt.Sprintf("timeouts.%s: '%s' is not a positive duration", phase, value))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ToJobs converts the entries to jobs, with defaults applied and auth methods obtained from resolveAuth,
+// which may be nil.
+func (f *File) ToJobs(ctx context.Context, resolveAuth AuthResolver) ([]api.Job, error) {
+	if resolveAuth == nil {
+		resolveAuth = func(context.Context, string) (transport.AuthMethod, error) {
+			return nil, nil
+		}
+	}
+
+	var jobs []api.Job
+	for i := range f.Jobs {
+		e := f.entry(i)
+		job := api.Job{
+			Name:                e.Name,
+			Source:              api.SourceSpec{Url: e.Source.Url, Branch: e.Source.Ref},
+			Target:              api.TargetSpec{Url: e.Target.Url, Branch: e.Target.Branch, BaseBranch: e.Target.BaseBranch},
+			Generator:           e.Generator,
+			RenderSpecFile:      e.RenderSpecFile,
+			Parameters:          e.Parameters,
+			UpdateStrategy:      updateStrategies[e.UpdateStrategy],
+			PruneOrphans:        e.PruneOrphans != nil && *e.PruneOrphans,
+			PreserveUserRegions: e.PreserveUserRegions != nil && *e.PreserveUserRegions,
+		}
+		for phase, value := range e.Timeouts {
+			if job.Timeouts == nil {
+				job.Timeouts = make(map[string]time.Duration)
+			}
+			// validated already
+			job.Timeouts[phase], _ = time.ParseDuration(value)
+		}
+		if e.Retry != nil {
+			job.Retry = e.Retry.policy()
+		}
+
+		if e.UrlRewriting != nil {
+			job.UrlRewriting = &api.UrlRewriting{Aliases: e.UrlRewriting.Aliases, InsteadOf: e.UrlRewriting.InsteadOf}
+		}
+		if e.HostKeys != nil {
+			job.HostKeys = &api.HostKeyVerification{
+				KnownHostsFiles: e.HostKeys.KnownHostsFiles,
+				Fingerprints:    e.HostKeys.Fingerprints,
+				TrustOnFirstUse: e.HostKeys.TrustOnFirstUse,
+			}
+			if e.HostKeys.KnownHosts != "" {
+				job.HostKeys.KnownHosts = []byte(e.HostKeys.KnownHosts)
+			}
+		}
+
+		rules, err := urlrewrite.New(job.UrlRewriting)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range e.Transport {
+			hostTransport, err := t.hostTransport()
+			if err != nil {
+				return nil, err
+			}
+			job.Transport = append(job.Transport, hostTransport)
+		}
+		if job.Source.Auth, err = resolveAuth(ctx, rules.Rewrite(job.Source.Url)); err != nil {
+			return nil, err
+		}
+		if job.Target.Auth, err = resolveAuth(ctx, rules.Rewrite(job.Target.Url)); err != nil {
+			return nil, err
+		}
+
+		if e.Commit != nil {
+			job.Commit = &api.CommitSpec{
+				AuthorName:  e.Commit.AuthorName,
+				AuthorEmail: e.Commit.AuthorEmail,
+				Message:     e.Commit.Message,
+				StagingMode: stagingModes[e.Commit.Staging],
+			}
+			if e.Commit.Tag != nil {
+				job.Commit.Tag = &api.TagSpec{Name: e.Commit.Tag.Name, Message: e.Commit.Tag.Message, Overwrite: e.Commit.Tag.Overwrite}
+			}
+		}
+		if e.Push != nil {
+			job.Push = &api.PushSpec{Policy: pushPolicies[e.Push.Policy]}
+			for _, m := range e.Push.Mirrors {
+				auth, err := resolveAuth(ctx, rules.Rewrite(m.Url))
+				if err != nil {
+					return nil, err
+				}
+				job.Push.Mirrors = append(job.Push.Mirrors, api.RemoteSpec{Name: m.Name, Url: m.Url, Auth: auth})
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// entry returns job i with the defaults filled in.
+func (f *File) entry(i int) Entry {
+	e := f.Jobs[i]
+	d := f.Defaults
+	e.Source.Url = or(e.Source.Url, d.Source.Url)
+	e.Source.Ref = or(e.Source.Ref, d.Source.Ref)
+	e.Target.Url = or(e.Target.Url, d.Target.Url)
+	e.Target.Branch = or(e.Target.Branch, d.Target.Branch)
+	e.Target.BaseBranch = or(e.Target.BaseBranch, d.Target.BaseBranch)
+	e.Generator = or(e.Generator, d.Generator)
+	e.RenderSpecFile = or(e.RenderSpecFile, d.RenderSpecFile)
+	e.UpdateStrategy = or(e.UpdateStrategy, d.UpdateStrategy)
+	if e.PruneOrphans == nil {
+		e.PruneOrphans = d.PruneOrphans
+	}
+	if e.PreserveUserRegions == nil {
+		e.PreserveUserRegions = d.PreserveUserRegions
+	}
+	if d.Parameters != nil {
+		parameters := make(map[string]interface{})
+		for k, v := range d.Parameters {
+			parameters[k] = v
+		}
+		for k, v := range e.Parameters {
+			parameters[k] = v
+		}
+		e.Parameters = parameters
+	}
+	if d.Commit != nil {
+		commit := *d.Commit
+		if e.Commit != nil {
+			commit.AuthorName = or(e.Commit.AuthorName, d.Commit.AuthorName)
+			commit.AuthorEmail = or(e.Commit.AuthorEmail, d.Commit.AuthorEmail)
+			commit.Message = or(e.Commit.Message, d.Commit.Message)
+			commit.Staging = or(e.Commit.Staging, d.Commit.Staging)
+			if e.Commit.Tag != nil {
+				commit.Tag = e.Commit.Tag
+			}
+		}
+		e.Commit = &commit
+	}
+	if e.Retry == nil {
+		e.Retry = d.Retry
+	}
+	if e.Transport == nil {
+		e.Transport = d.Transport
+	}
+	if e.HostKeys == nil {
+		e.HostKeys = d.HostKeys
+	}
+	if e.UrlRewriting == nil {
+		e.UrlRewriting = d.UrlRewriting
+	}
+	if d.Timeouts != nil {
+		timeouts := make(map[string]string)
+		for k, v := range d.Timeouts {
+			timeouts[k] = v
+		}
+		for k, v := range e.Timeouts {
+			timeouts[k] = v
+		}
+		e.Timeouts = timeouts
+	}
+	if e.Push == nil && d.Push != nil {
+		push := *d.Push
+		e.Push = &push
+	}
+	if e.Push != nil && e.Push.Off {
+		e.Push = nil
+	}
+	return e
+}
+
+// policy converts to api.RetryPolicy, the values must have been validated.
+func (r *Retry) policy() api.RetryPolicy {
+	policy := api.DefaultRetryPolicy()
+	if r.MaxAttempts != 0 {
+		policy.MaxAttempts = r.MaxAttempts
+	}
+	if r.InitialBackoff != "" {
+		policy.InitialBackoff, _ = time.ParseDuration(r.InitialBackoff)
+	}
+	if r.MaxBackoff != "" {
+		policy.MaxBackoff, _ = time.ParseDuration(r.MaxBackoff)
+	}
+	if r.Multiplier != 0 {
+		policy.Multiplier = r.Multiplier
+	}
+	if r.Jitter != nil {
+		policy.Jitter = *r.Jitter
+	}
+	return policy
+}
+
+// hostTransport converts to api.HostTransport, reading the certificate files.
+func (t Transport) hostTransport() (api.HostTransport, error) {
+	hostTransport := api.HostTransport{Host: t.Host, Proxy: t.Proxy, InsecureSkipVerify: t.InsecureSkipVerify}
+	for _, file := range []struct {
+		path     string
+		contents *[]byte
+	}{
+		{t.CAFile, &hostTransport.CABundle},
+		{t.ClientCertFile, &hostTransport.ClientCertificate},
+		{t.ClientKeyFile, &hostTransport.ClientKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+		contents, err := ioutil.ReadFile(file.path)
+		if err != nil {
+			return hostTransport, fmt.Errorf("transport %s: %w", t.Host, err)
+		}
+		*file.contents = contents
+	}
+	return hostTransport, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func or(value string, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,403 @@
+package jobfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const twoJobs = `defaults:
+  source:
+    url: https://example.com/generator
+    ref: v1
+  parameters:
+    team: platform
+  commit:
+    author_name: bot
+    author_email: bot@example.com
+jobs:
+  - name: order-service
+    target:
+      url: https://example.com/order-service
+      branch: main
+    generator: main
+    parameters:
+      serviceName: order-service
+    update_strategy: merge
+    push:
+      policy: any
+      mirrors:
+        - name: backup
+          url: https://backup.example.com/order-service
+  - name: billing-service
+    source:
+      ref: v2
+    target:
+      url: https://example.com/billing-service
+      branch: feature/regenerate
+      base_branch: main
+    generator: main
+    commit:
+      message: regenerate billing
+      staging: generated-only
+`
+
+func TestParseAndConvert(t *testing.T) {
+	f, err := Parse([]byte(twoJobs))
+	require.Nil(t, err)
+
+	auth := &githttp.BasicAuth{Username: "bot"}
+	var resolved []string
+	jobs, err := f.ToJobs(context.TODO(), func(_ context.Context, url string) (transport.AuthMethod, error) {
+		resolved = append(resolved, url)
+		return auth, nil
+	})
+	require.Nil(t, err)
+	require.Len(t, jobs, 2)
+	require.Len(t, resolved, 5)
+
+	order := jobs[0]
+	require.Equal(t, "order-service", order.Name)
+	require.Equal(t, api.SourceSpec{Url: "https://example.com/generator", Branch: "v1", Auth: auth}, order.Source)
+	require.Equal(t, map[string]interface{}{"team": "platform", "serviceName": "order-service"}, order.Parameters)
+	require.Equal(t, api.UpdateMerge, order.UpdateStrategy)
+	require.Equal(t, &api.CommitSpec{AuthorName: "bot", AuthorEmail: "bot@example.com"}, order.Commit)
+	require.Equal(t, api.PushToAny, order.Push.Policy)
+	require.Equal(t, []api.RemoteSpec{{Name: "backup", Url: "https://backup.example.com/order-service", Auth: auth}}, order.Push.Mirrors)
+
+	billing := jobs[1]
+	require.Equal(t, "v2", billing.Source.Branch)
+	require.Equal(t, api.TargetSpec{Url: "https://example.com/billing-service", Branch: "feature/regenerate", BaseBranch: "main", Auth: auth}, billing.Target)
+	require.Equal(t, &api.CommitSpec{AuthorName: "bot", AuthorEmail: "bot@example.com", Message: "regenerate billing", StagingMode: api.StageGeneratedOnly}, billing.Commit)
+	require.Nil(t, billing.Push)
+}
+
+func TestParse_PushDefaultsCanBeTurnedOff(t *testing.T) {
+	f, err := Parse([]byte(`defaults:
+  source:
+    url: https://example.com/generator
+  generator: main
+  commit: {}
+  push: true
+jobs:
+  - target:
+      url: https://example.com/a
+      branch: main
+  - target:
+      url: https://example.com/b
+      branch: main
+    push: false
+  - target:
+      url: https://example.com/c
+      branch: main
+    push:
+      policy: any
+`))
+	require.Nil(t, err)
+	jobs, err := f.ToJobs(context.TODO(), nil)
+	require.Nil(t, err)
+	require.Equal(t, &api.PushSpec{}, jobs[0].Push)
+	require.Nil(t, jobs[1].Push)
+	require.Equal(t, &api.PushSpec{Policy: api.PushToAny}, jobs[2].Push)
+}
+
+func TestParse_ReportsAllProblems(t *testing.T) {
+	_, err := Parse([]byte(`jobs:
+  - name: a
+    target:
+      url: https://example.com/a
+    update_strategy: sometimes
+  - name: a
+    source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/b
+      branch: main
+    generator: main
+    render_spec_file: ../../etc/generated.yaml
+    push: {}
+`))
+	validationErr := &ValidationError{}
+	require.True(t, errors.As(err, &validationErr))
+	require.Equal(t, []string{
+		"job 1 (a): source.url is required",
+		"job 1 (a): target.branch is required",
+		"job 1 (a): generator is required",
+		"job 1 (a): unknown update_strategy 'sometimes'",
+		"job 2 (a): duplicate name",
+		"job 2 (a): render_spec_file '../../etc/generated.yaml' is not a path inside the target",
+		"job 2 (a): push requires commit",
+	}, validationErr.Problems)
+}
+
+func TestParseUpdateStrategy(t *testing.T) {
+	for name, expected := range map[string]api.UpdateStrategy{
+		"":                api.UpdateOverwrite,
+		"overwrite":       api.UpdateOverwrite,
+		"merge":           api.UpdateMerge,
+		"pristine-branch": api.UpdatePristineBranch,
+	} {
+		strategy, ok := ParseUpdateStrategy(name)
+		require.True(t, ok, name)
+		require.Equal(t, expected, strategy, name)
+	}
+	_, ok := ParseUpdateStrategy("rebase")
+	require.False(t, ok)
+}
+
+func TestParse_UnknownKeys(t *testing.T) {
+	_, err := Parse([]byte("jobs:\n  - generatr: main\n"))
+	require.NotNil(t, err)
+}
+
+func TestParse_Timeouts(t *testing.T) {
+	f, err := Parse([]byte(`defaults:
+  timeouts:
+    clone-source: 1m
+    commit-and-push: 5m
+jobs:
+  - source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/a
+      branch: main
+    generator: main
+    timeouts:
+      commit-and-push: 30s
+`))
+	require.Nil(t, err)
+	jobs, err := f.ToJobs(context.TODO(), nil)
+	require.Nil(t, err)
+	require.Equal(t, map[string]time.Duration{
+		api.PhaseCloneSource:   time.Minute,
+		api.PhaseCommitAndPush: 30 * time.Second,
+	}, jobs[0].Timeouts)
+
+	_, err = Parse([]byte(`jobs:
+  - source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/a
+      branch: main
+    generator: main
+    timeouts:
+      push: 1m
+      generate: soon
+`))
+	validationErr := &ValidationError{}
+	require.True(t, errors.As(err, &validationErr))
+	require.Equal(t, []string{
+		"job 1: timeouts.generate: 'soon' is not a positive duration",
+		"job 1: timeouts: unknown phase 'push'",
+	}, validationErr.Problems)
+}
+
+func TestParse_Retry(t *testing.T) {
+	f, err := Parse([]byte(`defaults:
+  retry:
+    max_attempts: 3
+    initial_backoff: 2s
+    jitter: 0
+jobs:
+  - source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/a
+      branch: main
+    generator: main
+  - source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/b
+      branch: main
+    generator: main
+    retry:
+      max_attempts: 1
+`))
+	require.Nil(t, err)
+	jobs, err := f.ToJobs(context.TODO(), nil)
+	require.Nil(t, err)
+	require.Equal(t, api.RetryPolicy{MaxAttempts: 3, InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second, Multiplier: 2}, jobs[0].Retry)
+	require.Equal(t, 1, jobs[1].Retry.MaxAttempts)
+
+	_, err = Parse([]byte(`jobs:
+  - source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/a
+      branch: main
+    generator: main
+    retry:
+      max_backoff: never
+      multiplier: 0.5
+`))
+	validationErr := &ValidationError{}
+	require.True(t, errors.As(err, &validationErr))
+	require.Equal(t, []string{
+		"job 1: retry.max_backoff: 'never' is not a positive duration",
+		"job 1: retry.multiplier must be at least 1",
+	}, validationErr.Problems)
+}
+
+func TestParse_Transport(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.Nil(t, os.WriteFile(caFile, []byte("ca certificates"), 0644))
+
+	f, err := Parse([]byte(fmt.Sprintf(`defaults:
+  transport:
+    - host: "*.corp.example.com"
+      ca_file: %s
+      proxy: http://proxy.corp.example.com:3128
+jobs:
+  - source:
+      url: https://git.corp.example.com/generator
+    target:
+      url: https://git.corp.example.com/a
+      branch: main
+    generator: main
+  - source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/b
+      branch: main
+    generator: main
+    transport:
+      - host: example.com
+        insecure_skip_verify: true
+`, caFile)))
+	require.Nil(t, err)
+	jobs, err := f.ToJobs(context.TODO(), nil)
+	require.Nil(t, err)
+	require.Equal(t, []api.HostTransport{{Host: "*.corp.example.com", CABundle: []byte("ca certificates"), Proxy: "http://proxy.corp.example.com:3128"}}, jobs[0].Transport)
+	require.Equal(t, []api.HostTransport{{Host: "example.com", InsecureSkipVerify: true}}, jobs[1].Transport)
+
+	_, err = Parse([]byte(`jobs:
+  - source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/a
+      branch: main
+    generator: main
+    transport:
+      - host: "[example.com"
+      - host: example.com
+        client_cert_file: client.pem
+        proxy: proxy:3128
+`))
+	validationErr := &ValidationError{}
+	require.True(t, errors.As(err, &validationErr))
+	require.Equal(t, []string{
+		"job 1: transport: invalid host pattern '[example.com'",
+		"job 1: transport example.com: client_cert_file and client_key_file go together",
+		"job 1: transport example.com: invalid proxy 'proxy:3128'",
+	}, validationErr.Problems)
+}
+
+func TestParse_HostKeys(t *testing.T) {
+	f, err := Parse([]byte(`defaults:
+  host_keys:
+    known_hosts: |
+      git.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl
+    fingerprints:
+      github.com: ["SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU"]
+jobs:
+  - source:
+      url: ssh://git@git.example.com/generator
+    target:
+      url: ssh://git@git.example.com/a
+      branch: main
+    generator: main
+  - source:
+      url: ssh://git@git.example.com/generator
+    target:
+      url: ssh://git@git.example.com/b
+      branch: main
+    generator: main
+    host_keys:
+      trust_on_first_use: /var/lib/generator/known_hosts
+`))
+	require.Nil(t, err)
+	jobs, err := f.ToJobs(context.TODO(), nil)
+	require.Nil(t, err)
+	require.Equal(t, &api.HostKeyVerification{
+		KnownHosts:   []byte("git.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n"),
+		Fingerprints: map[string][]string{"github.com": {"SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU"}},
+	}, jobs[0].HostKeys)
+	require.Equal(t, &api.HostKeyVerification{TrustOnFirstUse: "/var/lib/generator/known_hosts"}, jobs[1].HostKeys)
+
+	_, err = Parse([]byte(`jobs:
+  - source:
+      url: https://example.com/generator
+    target:
+      url: https://example.com/a
+      branch: main
+    generator: main
+    host_keys:
+      fingerprints:
+        "[github.com": ["SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU"]
+`))
+	validationErr := &ValidationError{}
+	require.True(t, errors.As(err, &validationErr))
+	require.Equal(t, []string{"job 1: host_keys.fingerprints: invalid host pattern '[github.com'"}, validationErr.Problems)
+}
+
+func TestParse_UrlRewriting(t *testing.T) {
+	f, err := Parse([]byte(`defaults:
+  url_rewriting:
+    aliases:
+      templates/go-rest: https://github.com/some-org/tpl-go-rest
+    instead_of:
+      "https://github.com/": "ssh://git@mirror.corp.example.com/github/"
+  source:
+    url: templates/go-rest
+jobs:
+  - target:
+      url: https://github.com/some-org/a
+      branch: main
+    generator: main
+`))
+	require.Nil(t, err)
+	var resolved []string
+	jobs, err := f.ToJobs(context.TODO(), func(_ context.Context, url string) (transport.AuthMethod, error) {
+		resolved = append(resolved, url)
+		return nil, nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		"ssh://git@mirror.corp.example.com/github/some-org/tpl-go-rest",
+		"ssh://git@mirror.corp.example.com/github/some-org/a",
+	}, resolved)
+	require.Equal(t, "templates/go-rest", jobs[0].Source.Url)
+	require.Equal(t, &api.UrlRewriting{
+		Aliases:   map[string]string{"templates/go-rest": "https://github.com/some-org/tpl-go-rest"},
+		InsteadOf: map[string]string{"https://github.com/": "ssh://git@mirror.corp.example.com/github/"},
+	}, jobs[0].UrlRewriting)
+
+	_, err = Parse([]byte(`jobs:
+  - source:
+      url: templates/go-rest
+    target:
+      url: https://example.com/a
+      branch: main
+    generator: main
+    url_rewriting:
+      aliases:
+        templates/go-rest: ""
+      instead_of:
+        "": "ssh://git@mirror.corp.example.com/"
+`))
+	validationErr := &ValidationError{}
+	require.True(t, errors.As(err, &validationErr))
+	require.Equal(t, []string{
+		"job 1: url_rewriting.aliases: 'templates/go-rest' needs a name and a url",
+		"job 1: url_rewriting.instead_of: the prefix must not be empty",
+	}, validationErr.Problems)
+}
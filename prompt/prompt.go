@@ -0,0 +1,130 @@
+// Package prompt asks for the parameters of a generator on a terminal, or any other pair of streams.
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Prompter struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+// New creates a Prompter that reads answers from in, one per line, and writes questions to out.
+func New(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{reader: bufio.NewReader(in), out: out}
+}
+
+// Parameters asks for every variable of the generator spec, in alphabetical order, and returns the parameters
+// to pass to WriteRenderSpecFile.
+//
+// Variables in fixed are not asked for, and are returned as they are. Values in prefilled, usually taken
+// from the render spec already in the target, replace the defaults of the spec. An empty answer keeps the
+// default, and answers are checked against the pattern of the variable right away, asking again until
+// they match. Variables with structured defaults cannot be entered as text and keep their default.
+//
+// If the input ends, the remaining variables keep their defaults, or an error is returned for the first
+// one that has none.
+func (p *Prompter) Parameters(spec *genlibapi.GeneratorSpec, fixed map[string]interface{}, prefilled map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	var names []string
+	for name := range spec.Variables {
+		if value, ok := fixed[name]; ok {
+			result[name] = value
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	eof := false
+	for _, name := range names {
+		variable := spec.Variables[name]
+		value, hasValue := prefilled[name]
+		if hasValue {
+			result[name] = value
+		} else if variable.DefaultValue != nil {
+			value, hasValue = variable.DefaultValue, true
+		}
+		if hasValue && isStructured(value) {
+			continue
+		}
+		if eof {
+			if !hasValue {
+				return result, fmt.Errorf("input ended without a value for required parameter %s", name)
+			}
+			continue
+		}
+
+		answer, ended, err := p.ask(name, variable, value, hasValue)
+		if err != nil {
+			return result, err
+		}
+		eof = ended
+		if answer != "" {
+			result[name] = answer
+		} else if !hasValue {
+			return result, fmt.Errorf("input ended without a value for required parameter %s", name)
+		}
+	}
+	return result, nil
+}
+
+// ask returns the validated answer, or "" to keep the default, and whether the input ended.
+func (p *Prompter) ask(name string, variable genlibapi.VariableSpec, value interface{}, hasValue bool) (string, bool, error) {
+	var pattern *regexp.Regexp
+	if variable.ValidationPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(variable.ValidationPattern); err != nil {
+			return "", false, fmt.Errorf("invalid pattern for parameter %s: %w", name, err)
+		}
+	}
+
+	for {
+		fmt.Fprint(p.out, name)
+		if variable.Description != "" {
+			fmt.Fprintf(p.out, " (%s)", variable.Description)
+		}
+		if hasValue {
+			fmt.Fprintf(p.out, " [%v]", value)
+		}
+		fmt.Fprint(p.out, ": ")
+
+		line, err := p.reader.ReadString('\n')
+		ended := err == io.EOF
+		if err != nil && !ended {
+			return "", false, err
+		}
+		if ended {
+			fmt.Fprintln(p.out)
+		}
+
+		answer := strings.TrimSpace(line)
+		switch {
+		case answer == "" && !hasValue && !ended:
+			fmt.Fprintln(p.out, "  a value is required")
+		case answer != "" && pattern != nil && !pattern.MatchString(answer):
+			fmt.Fprintf(p.out, "  '%s' does not match %s\n", answer, variable.ValidationPattern)
+			if ended {
+				return "", true, fmt.Errorf("invalid value for parameter %s", name)
+			}
+		default:
+			return answer, ended, nil
+		}
+	}
+}
+
+func isStructured(value interface{}) bool {
+	switch value.(type) {
+	case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,61 @@
+package prompt
+
+import (
+	"bytes"
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func spec() *genlibapi.GeneratorSpec {
+	return &genlibapi.GeneratorSpec{
+		Variables: map[string]genlibapi.VariableSpec{
+			"owner":       {Description: "The owning team"},
+			"serviceName": {Description: "The name of the service", ValidationPattern: "^[a-z-]+$", DefaultValue: "demo-service"},
+		},
+	}
+}
+
+func TestParameters_DefaultsAndAnswers(t *testing.T) {
+	out := &bytes.Buffer{}
+	result, err := New(strings.NewReader("platform\n\n"), out).Parameters(spec(), nil, nil)
+	require.Nil(t, err)
+	// accepting the default of the spec leaves it to the generator
+	require.Equal(t, map[string]interface{}{"owner": "platform"}, result)
+	require.Equal(t, "owner (The owning team): serviceName (The name of the service) [demo-service]: ", out.String())
+}
+
+func TestParameters_ValidatesInline(t *testing.T) {
+	out := &bytes.Buffer{}
+	result, err := New(strings.NewReader("\nplatform\nNot Valid\nbilling\n"), out).Parameters(spec(), nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{"owner": "platform", "serviceName": "billing"}, result)
+	require.Contains(t, out.String(), "owner (The owning team):   a value is required\nowner (The owning team): ")
+	require.Contains(t, out.String(), "  'Not Valid' does not match ^[a-z-]+$\nserviceName")
+}
+
+func TestParameters_Prefilled(t *testing.T) {
+	out := &bytes.Buffer{}
+	prefilled := map[string]interface{}{"owner": "platform", "serviceName": "billing", "layout": map[interface{}]interface{}{"a": 1}}
+	result, err := New(strings.NewReader("\n\n"), out).Parameters(spec(), nil, prefilled)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{"owner": "platform", "serviceName": "billing"}, result)
+	require.Equal(t, "owner (The owning team) [platform]: serviceName (The name of the service) [billing]: ", out.String())
+}
+
+func TestParameters_FixedAreNotAsked(t *testing.T) {
+	out := &bytes.Buffer{}
+	result, err := New(strings.NewReader(""), out).Parameters(spec(), map[string]interface{}{"owner": "platform"}, nil)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{"owner": "platform"}, result)
+	require.Equal(t, "serviceName (The name of the service) [demo-service]: \n", out.String())
+}
+
+func TestParameters_InputEnds(t *testing.T) {
+	_, err := New(strings.NewReader(""), &bytes.Buffer{}).Parameters(spec(), nil, nil)
+	require.EqualError(t, err, "input ended without a value for required parameter owner")
+
+	_, err = New(strings.NewReader("platform\nNot Valid"), &bytes.Buffer{}).Parameters(spec(), nil, nil)
+	require.EqualError(t, err, "invalid value for parameter serviceName")
+}
@@ -6,6 +6,10 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/mplushnikov/go-generator-git/v2/api"
 	"github.com/mplushnikov/go-generator-git/v2/internal/implementation"
+	"github.com/mplushnikov/go-generator-git/v2/internal/pipeline"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var Instance api.GitApi
@@ -40,14 +44,120 @@ func WriteRenderSpecFile(ctx context.Context, generatorName string, renderSpecFi
 	return Instance.WriteRenderSpecFile(ctx, generatorName, renderSpecFile, parameters)
 }
 
+func SetUpdateStrategy(strategy api.UpdateStrategy) {
+	Instance.SetUpdateStrategy(strategy)
+}
+
+func SetPristineBranch(spec api.PristineBranchSpec) {
+	Instance.SetPristineBranch(spec)
+}
+
+func SetPruneOrphans(enabled bool) {
+	Instance.SetPruneOrphans(enabled)
+}
+
+func SetPreserveUserRegions(enabled bool) {
+	Instance.SetPreserveUserRegions(enabled)
+}
+
 func Generate(ctx context.Context) (*genlibapi.Response, error) {
 	return Instance.Generate(ctx)
 }
 
+func GenerateWithResult(ctx context.Context) (*api.GenerateResult, error) {
+	return Instance.GenerateWithResult(ctx)
+}
+
+func DetectDrift(ctx context.Context) (*api.DriftResult, error) {
+	return Instance.DetectDrift(ctx)
+}
+
+func DiffTarget(ctx context.Context) ([]api.FileDiff, error) {
+	return Instance.DiffTarget(ctx)
+}
+
+func AddPushRemote(ctx context.Context, name string, gitRepoUrl string, auth transport.AuthMethod) error {
+	return Instance.AddPushRemote(ctx, name, gitRepoUrl, auth)
+}
+
+func SetPushPolicy(policy api.PushPolicy) {
+	Instance.SetPushPolicy(policy)
+}
+
+func SetStagingMode(mode api.StagingMode) {
+	Instance.SetStagingMode(mode)
+}
+
+func SetCommitTag(tag *api.TagSpec) {
+	Instance.SetCommitTag(tag)
+}
+
+func SetProgressSink(sink api.ProgressSink) {
+	Instance.SetProgressSink(sink)
+}
+
+func AddHooks(hooks api.Hooks) {
+	Instance.AddHooks(hooks)
+}
+
+func SetCredentialResolver(resolver api.CredentialResolver) {
+	Instance.SetCredentialResolver(resolver)
+}
+
+func SetRetryPolicy(policy api.RetryPolicy) {
+	Instance.SetRetryPolicy(policy)
+}
+
+func SetTransport(hosts []api.HostTransport) error {
+	return Instance.SetTransport(hosts)
+}
+
+func SetHostKeyVerification(verification *api.HostKeyVerification) error {
+	return Instance.SetHostKeyVerification(verification)
+}
+
+func SetUrlRewriting(rewriting *api.UrlRewriting) error {
+	return Instance.SetUrlRewriting(rewriting)
+}
+
+func RewriteUrl(url string) string {
+	return Instance.RewriteUrl(url)
+}
+
+// SetTracerProvider sets the provider for the spans of all instances. By default, and when set to nil,
+// the global provider of otel is used.
+func SetTracerProvider(provider trace.TracerProvider) {
+	telemetry.SetTracerProvider(provider)
+}
+
+// SetMeterProvider sets the provider for the metrics of all instances. By default, and when set to nil,
+// the global provider of otel is used.
+func SetMeterProvider(provider metric.MeterProvider) {
+	telemetry.SetMeterProvider(provider)
+}
+
 func CommitAndPush(ctx context.Context, name string, email string, message string, auth transport.AuthMethod) error {
 	return Instance.CommitAndPush(ctx, name, email, message, auth)
 }
 
+func CommitAndPushWithResult(ctx context.Context, name string, email string, message string, auth transport.AuthMethod) (*api.CommitResult, error) {
+	return Instance.CommitAndPushWithResult(ctx, name, email, message, auth)
+}
+
 func Cleanup(ctx context.Context) error {
 	return Instance.Cleanup(ctx)
 }
+
+// Run executes a whole generator session on a fresh instance, so it is thread safe.
+//
+// The working directory is always cleaned up. The result contains the outcome of every phase that was started.
+func Run(ctx context.Context, job api.Job) (*api.SessionResult, error) {
+	return pipeline.Run(ctx, ThreadsafeInstance(), job)
+}
+
+// RunBatch runs several jobs, each on a fresh instance, see api.BatchOptions.
+//
+// Results are in the same order as the jobs, with nil for jobs that were skipped.
+func RunBatch(ctx context.Context, jobs []api.Job, options api.BatchOptions) ([]*api.SessionResult, error) {
+	return pipeline.RunBatch(ctx, ThreadsafeInstance, jobs, options)
+}
@@ -0,0 +1,49 @@
+package report
+
+import (
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"sort"
+)
+
+const (
+	driftModified  = "modified"
+	driftMissing   = "missing"
+	driftAdded     = "added"
+	driftUnchanged = "unchanged"
+)
+
+type driftFinding struct {
+	path string
+	kind string
+}
+
+func (f driftFinding) message() string {
+	switch f.kind {
+	case driftModified:
+		return "generated file was changed by hand"
+	case driftMissing:
+		return "generated file was deleted"
+	case driftAdded:
+		return "generator would add this file"
+	default:
+		return "generated file is unchanged"
+	}
+}
+
+// driftFindings lists every file DetectDrift looked at, sorted by path.
+func driftFindings(d *api.DriftResult) []driftFinding {
+	var findings []driftFinding
+	add := func(paths []string, kind string) {
+		for _, p := range paths {
+			findings = append(findings, driftFinding{path: p, kind: kind})
+		}
+	}
+	add(d.Modified, driftModified)
+	add(d.Missing, driftMissing)
+	add(d.Added, driftAdded)
+	add(d.Unchanged, driftUnchanged)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].path < findings[j].path
+	})
+	return findings
+}
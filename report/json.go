@@ -0,0 +1,224 @@
+package report
+
+import (
+	"encoding/json"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"io"
+	"time"
+)
+
+// the JSON format mirrors api.SessionResult, with errors turned into their messages
+
+type jsonSession struct {
+	JobName        string          `json:"jobName,omitempty"`
+	Generator      string          `json:"generator,omitempty"`
+	RenderSpecFile string          `json:"renderSpecFile,omitempty"`
+	Source         *jsonRepository `json:"source,omitempty"`
+	Target         *jsonRepository `json:"target,omitempty"`
+	Success        bool            `json:"success"`
+	Phases         []jsonPhase     `json:"phases"`
+	Parameters     *jsonParameters `json:"parameters,omitempty"`
+	Generate       *jsonGenerate   `json:"generate,omitempty"`
+	Changes        []jsonChange    `json:"changes,omitempty"`
+	Drift          *jsonDrift      `json:"drift,omitempty"`
+	Commit         *jsonCommit     `json:"commit,omitempty"`
+}
+
+type jsonRepository struct {
+	Url string `json:"url"`
+
+	// only if url rewriting changed Url
+	RewrittenUrl string `json:"rewrittenUrl,omitempty"`
+}
+
+type jsonPhase struct {
+	Name       string    `json:"name"`
+	Started    time.Time `json:"started"`
+	DurationMs int64     `json:"durationMs"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type jsonParameters struct {
+	Success bool     `json:"success"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type jsonGenerate struct {
+	Success         bool                 `json:"success"`
+	Errors          []string             `json:"errors,omitempty"`
+	RenderedFiles   []jsonFile           `json:"renderedFiles"`
+	PrunedFiles     []string             `json:"prunedFiles,omitempty"`
+	ModifiedOrphans []string             `json:"modifiedOrphans,omitempty"`
+	Conflicts       []jsonConflict       `json:"conflicts,omitempty"`
+	OrphanedRegions []jsonOrphanedRegion `json:"orphanedRegions,omitempty"`
+}
+
+type jsonFile struct {
+	Path    string   `json:"path"`
+	Success bool     `json:"success"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type jsonConflict struct {
+	Path    string `json:"path"`
+	Regions int    `json:"regions"`
+}
+
+type jsonOrphanedRegion struct {
+	Path    string `json:"path"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+type jsonChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	Diff   string `json:"diff,omitempty"`
+	Binary bool   `json:"binary,omitempty"`
+}
+
+type jsonDrift struct {
+	HasDrift        bool     `json:"hasDrift"`
+	Score           float64  `json:"score"`
+	RenderSpecFiles []string `json:"renderSpecFiles"`
+	Modified        []string `json:"modified"`
+	Missing         []string `json:"missing"`
+	Added           []string `json:"added"`
+	Unchanged       []string `json:"unchanged"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+type jsonCommit struct {
+	CommitHash   string     `json:"commitHash,omitempty"`
+	Tag          string     `json:"tag,omitempty"`
+	PrunedFiles  []string   `json:"prunedFiles,omitempty"`
+	IgnoredPaths []string   `json:"ignoredPaths,omitempty"`
+	Pushes       []jsonPush `json:"pushes,omitempty"`
+}
+
+type jsonPush struct {
+	RemoteName string `json:"remoteName"`
+	RemoteUrl  string `json:"remoteUrl"`
+
+	// only if url rewriting changed it into RemoteUrl
+	OriginalUrl string `json:"originalUrl,omitempty"`
+
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// WriteJSON writes the session result as an indented JSON document.
+func WriteJSON(w io.Writer, session *api.SessionResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(toJSON(session))
+}
+
+func toJSON(s *api.SessionResult) *jsonSession {
+	result := &jsonSession{
+		JobName:        s.JobName,
+		Generator:      s.Generator,
+		RenderSpecFile: s.RenderSpecFile,
+		Success:        s.Success(),
+		Phases:         []jsonPhase{},
+		Source:         toJSONRepository(s.Source),
+		Target:         toJSONRepository(s.Target),
+	}
+	for _, p := range s.Phases {
+		result.Phases = append(result.Phases, jsonPhase{
+			Name:       p.Name,
+			Started:    p.Started,
+			DurationMs: p.Duration.Milliseconds(),
+			Success:    p.Err == nil,
+			Error:      errorString(p.Err),
+		})
+	}
+	if s.RenderSpec != nil {
+		result.Parameters = &jsonParameters{Success: s.RenderSpec.Success, Errors: errorStrings(s.RenderSpec.Errors)}
+	}
+	if s.Generate != nil && s.Generate.Response != nil {
+		g := &jsonGenerate{
+			Success:         s.Generate.Success,
+			Errors:          errorStrings(s.Generate.Errors),
+			RenderedFiles:   []jsonFile{},
+			PrunedFiles:     s.Generate.PrunedFiles,
+			ModifiedOrphans: s.Generate.ModifiedOrphans,
+		}
+		for _, c := range s.Generate.Conflicts {
+			g.Conflicts = append(g.Conflicts, jsonConflict{Path: c.Path, Regions: c.Regions})
+		}
+		for _, r := range s.Generate.OrphanedRegions {
+			g.OrphanedRegions = append(g.OrphanedRegions, jsonOrphanedRegion{Path: r.Path, Name: r.Name, Content: r.Content})
+		}
+		for _, f := range s.Generate.RenderedFiles {
+			g.RenderedFiles = append(g.RenderedFiles, jsonFile{Path: f.RelativeFilePath, Success: f.Success, Errors: errorStrings(f.Errors)})
+		}
+		result.Generate = g
+	}
+	for _, c := range s.Changes {
+		result.Changes = append(result.Changes, jsonChange{Path: c.Path, Change: string(c.Change), Diff: c.Diff, Binary: c.Binary})
+	}
+	if s.Drift != nil {
+		result.Drift = &jsonDrift{
+			HasDrift:        s.Drift.HasDrift(),
+			Score:           s.Drift.Score(),
+			RenderSpecFiles: nonNil(s.Drift.RenderSpecFiles),
+			Modified:        nonNil(s.Drift.Modified),
+			Missing:         nonNil(s.Drift.Missing),
+			Added:           nonNil(s.Drift.Added),
+			Unchanged:       nonNil(s.Drift.Unchanged),
+			Errors:          errorStrings(s.Drift.Errors),
+		}
+	}
+	if s.Commit != nil {
+		c := &jsonCommit{
+			CommitHash:   s.Commit.CommitHash,
+			Tag:          s.Commit.Tag,
+			PrunedFiles:  s.Commit.PrunedFiles,
+			IgnoredPaths: s.Commit.IgnoredPaths,
+		}
+		for _, p := range s.Commit.PushResults {
+			push := jsonPush{RemoteName: p.RemoteName, RemoteUrl: p.RemoteUrl, Success: p.Success, Error: errorString(p.Err)}
+			if p.OriginalUrl != p.RemoteUrl {
+				push.OriginalUrl = p.OriginalUrl
+			}
+			c.Pushes = append(c.Pushes, push)
+		}
+		result.Commit = c
+	}
+	return result
+}
+
+func toJSONRepository(url api.RepositoryUrl) *jsonRepository {
+	if url.Original == "" {
+		return nil
+	}
+	repository := &jsonRepository{Url: url.Original}
+	if url.Rewritten != url.Original {
+		repository.RewrittenUrl = url.Rewritten
+	}
+	return repository
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func errorStrings(errs []error) []string {
+	var result []string
+	for _, err := range errs {
+		result = append(result, errorString(err))
+	}
+	return result
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
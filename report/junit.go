@@ -0,0 +1,141 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the session result as JUnit XML.
+//
+// There is one test suite each for the phases, the parameters, the rendered files, and the drift findings,
+// as far as the session contains them. Every phase, parameter error, rendered file, and file checked for
+// drift becomes a test case.
+func WriteJUnit(w io.Writer, session *api.SessionResult) error {
+	suites := &junitTestSuites{Name: "go-generator-git"}
+	addSuite := func(suite junitTestSuite) {
+		for _, tc := range suite.TestCases {
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if len(session.Phases) > 0 {
+		suite := junitTestSuite{Name: "phases"}
+		for _, p := range session.Phases {
+			tc := junitTestCase{Name: p.Name, ClassName: "phases", Time: seconds(p.Duration.Seconds())}
+			if p.Err != nil {
+				tc.Failure = &junitFailure{Message: p.Err.Error(), Type: "error"}
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		addSuite(suite)
+	}
+
+	if session.RenderSpec != nil {
+		suite := junitTestSuite{Name: "parameters"}
+		for i, err := range session.RenderSpec.Errors {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      fmt.Sprintf("parameter error %d", i+1),
+				ClassName: "parameters",
+				Failure:   &junitFailure{Message: err.Error(), Type: "validation"},
+			})
+		}
+		if len(suite.TestCases) == 0 {
+			suite.TestCases = append(suite.TestCases, junitTestCase{Name: "parameters are valid", ClassName: "parameters"})
+		}
+		addSuite(suite)
+	}
+
+	if session.Generate != nil && session.Generate.Response != nil {
+		suite := junitTestSuite{Name: "render"}
+		for _, err := range session.Generate.Errors {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      "render",
+				ClassName: "render",
+				Failure:   &junitFailure{Message: err.Error(), Type: "error"},
+			})
+		}
+		conflicts := make(map[string]int)
+		for _, c := range session.Generate.Conflicts {
+			conflicts[c.Path] = c.Regions
+		}
+		for _, f := range session.Generate.RenderedFiles {
+			tc := junitTestCase{Name: f.RelativeFilePath, ClassName: "render"}
+			if !f.Success {
+				tc.Failure = &junitFailure{Message: "rendering failed", Type: "error", Text: joinErrors(f.Errors)}
+			} else if n := conflicts[f.RelativeFilePath]; n > 0 {
+				tc.Failure = &junitFailure{Message: fmt.Sprintf("%d merge conflicts", n), Type: "conflict"}
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		addSuite(suite)
+	}
+
+	if session.Drift != nil {
+		suite := junitTestSuite{Name: "drift"}
+		for _, finding := range driftFindings(session.Drift) {
+			tc := junitTestCase{Name: finding.path, ClassName: "drift"}
+			if finding.kind != driftUnchanged {
+				tc.Failure = &junitFailure{Message: finding.message(), Type: finding.kind}
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		addSuite(suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+func joinErrors(errs []error) string {
+	return strings.Join(errorStrings(errs), "\n")
+}
@@ -0,0 +1,31 @@
+// Package report writes the outcome of a generator session in machine-readable formats,
+// so CI systems and code scanning tools can show it without custom glue code.
+package report
+
+import (
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"io"
+)
+
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatJUnit Format = "junit"
+	FormatSARIF Format = "sarif"
+)
+
+// Write writes the session result in the given format.
+func Write(w io.Writer, format Format, session *api.SessionResult) error {
+	switch format {
+	case FormatJSON:
+		return WriteJSON(w, session)
+	case FormatJUnit:
+		return WriteJUnit(w, session)
+	case FormatSARIF:
+		return WriteSARIF(w, session)
+	default:
+		return fmt.Errorf("unknown report format '%s'", format)
+	}
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	genlibapi "github.com/StephanHCB/go-generator-lib/api"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func session() *api.SessionResult {
+	return &api.SessionResult{
+		JobName:        "order-service",
+		Generator:      "main",
+		RenderSpecFile: "generated-main.yaml",
+		Source:         api.RepositoryUrl{Original: "templates/main", Rewritten: "ssh://git@mirror.example.com/templates/main.git"},
+		Target:         api.RepositoryUrl{Original: "https://example.com/repo", Rewritten: "https://example.com/repo"},
+		Phases: []api.PhaseResult{
+			{Name: "clone-source", Started: time.Unix(0, 0).UTC(), Duration: 1500 * time.Millisecond},
+			{Name: "generate", Started: time.Unix(2, 0).UTC(), Duration: time.Second, Err: errors.New("rendering failed")},
+		},
+		RenderSpec: &genlibapi.Response{Success: true},
+		Generate: &api.GenerateResult{
+			Response: &genlibapi.Response{
+				Success: false,
+				RenderedFiles: []genlibapi.FileResult{
+					{Success: true, RelativeFilePath: "README.md"},
+					{Success: false, RelativeFilePath: "main.go", Errors: []error{errors.New("undefined variable")}},
+				},
+			},
+			Conflicts: []api.MergeConflict{{Path: "README.md", Regions: 2}},
+		},
+		Drift: &api.DriftResult{Modified: []string{"main.go"}, Unchanged: []string{"README.md"}},
+		Commit: &api.CommitResult{
+			CommitHash:  "abc123",
+			PushResults: []api.PushResult{{RemoteName: "origin", RemoteUrl: "https://example.com/repo", Success: false, Err: errors.New("denied")}},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, Write(&buf, FormatJSON, session()))
+
+	parsed := jsonSession{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &parsed))
+	require.False(t, parsed.Success)
+	require.Equal(t, "order-service", parsed.JobName)
+	require.Equal(t, int64(1500), parsed.Phases[0].DurationMs)
+	require.Equal(t, "rendering failed", parsed.Phases[1].Error)
+	require.Equal(t, []string{"undefined variable"}, parsed.Generate.RenderedFiles[1].Errors)
+	require.Equal(t, jsonConflict{Path: "README.md", Regions: 2}, parsed.Generate.Conflicts[0])
+	require.Equal(t, 0.5, parsed.Drift.Score)
+	require.Equal(t, "denied", parsed.Commit.Pushes[0].Error)
+	require.Equal(t, &jsonRepository{Url: "templates/main", RewrittenUrl: "ssh://git@mirror.example.com/templates/main.git"}, parsed.Source)
+	require.Equal(t, &jsonRepository{Url: "https://example.com/repo"}, parsed.Target)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, Write(&buf, FormatJUnit, session()))
+
+	parsed := junitTestSuites{}
+	require.Nil(t, xml.Unmarshal(buf.Bytes(), &parsed))
+	require.Equal(t, 7, parsed.Tests)
+	require.Equal(t, 4, parsed.Failures)
+
+	render := parsed.Suites[2]
+	require.Equal(t, "render", render.Name)
+	require.Equal(t, "README.md", render.TestCases[0].Name)
+	require.Equal(t, "conflict", render.TestCases[0].Failure.Type)
+	require.Equal(t, "undefined variable", render.TestCases[1].Failure.Text)
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, Write(&buf, FormatSARIF, session()))
+
+	parsed := sarifLog{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &parsed))
+	require.Equal(t, "2.1.0", parsed.Version)
+	var ruleIds []string
+	for _, r := range parsed.Runs[0].Results {
+		ruleIds = append(ruleIds, r.RuleId)
+	}
+	require.Equal(t, []string{"phase-failed", "render-failed", "merge-conflict", "drift-modified"}, ruleIds)
+	require.Equal(t, "main.go", parsed.Runs[0].Results[1].Locations[0].PhysicalLocation.ArtifactLocation.Uri)
+}
+
+func TestWrite_UnknownFormat(t *testing.T) {
+	require.NotNil(t, Write(&bytes.Buffer{}, "html", session()))
+}
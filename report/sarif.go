@@ -0,0 +1,140 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"io"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "go-generator-git"
+	toolInfoUri  = "https://github.com/mplushnikov/go-generator-git"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+// the rules a SARIF report can refer to, in the order they are listed in the report
+var sarifRules = []sarifRule{
+	{Id: "phase-failed", ShortDescription: sarifMessage{Text: "A step of the generator session failed"}},
+	{Id: "invalid-parameter", ShortDescription: sarifMessage{Text: "A generator parameter is invalid"}},
+	{Id: "render-failed", ShortDescription: sarifMessage{Text: "A file could not be rendered"}},
+	{Id: "merge-conflict", ShortDescription: sarifMessage{Text: "Generated changes conflict with changes in the target"}},
+	{Id: "orphaned-user-region", ShortDescription: sarifMessage{Text: "A user region no longer exists in the generated file"}},
+	{Id: "drift-" + driftModified, ShortDescription: sarifMessage{Text: "A generated file was changed by hand"}},
+	{Id: "drift-" + driftMissing, ShortDescription: sarifMessage{Text: "A generated file was deleted"}},
+	{Id: "drift-" + driftAdded, ShortDescription: sarifMessage{Text: "The generator would add a file"}},
+}
+
+// WriteSARIF writes the problems found in the session result as a SARIF 2.1.0 log.
+//
+// Failed phases, parameter and render errors are reported as errors, merge conflicts, orphaned
+// user regions and drift as warnings. Successful files are not listed.
+func WriteSARIF(w io.Writer, session *api.SessionResult) error {
+	var results []sarifResult
+	add := func(ruleId string, level string, message string, path string) {
+		r := sarifResult{RuleId: ruleId, Level: level, Message: sarifMessage{Text: message}}
+		if path != "" {
+			r.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{Uri: path}}}}
+		}
+		results = append(results, r)
+	}
+
+	for _, p := range session.Phases {
+		if p.Err != nil {
+			add("phase-failed", "error", fmt.Sprintf("%s failed: %s", p.Name, p.Err.Error()), "")
+		}
+	}
+	if session.RenderSpec != nil {
+		for _, err := range session.RenderSpec.Errors {
+			add("invalid-parameter", "error", err.Error(), session.RenderSpecFile)
+		}
+	}
+	if session.Generate != nil && session.Generate.Response != nil {
+		for _, err := range session.Generate.Errors {
+			add("render-failed", "error", err.Error(), session.RenderSpecFile)
+		}
+		for _, f := range session.Generate.RenderedFiles {
+			if !f.Success {
+				add("render-failed", "error", "rendering failed: "+joinErrors(f.Errors), f.RelativeFilePath)
+			}
+		}
+		for _, c := range session.Generate.Conflicts {
+			add("merge-conflict", "warning", fmt.Sprintf("%d merge conflicts", c.Regions), c.Path)
+		}
+		for _, r := range session.Generate.OrphanedRegions {
+			add("orphaned-user-region", "warning", fmt.Sprintf("user region %s no longer exists, its content was dropped", r.Name), r.Path)
+		}
+	}
+	if session.Drift != nil {
+		for _, finding := range driftFindings(session.Drift) {
+			if finding.kind != driftUnchanged {
+				add("drift-"+finding.kind, "warning", finding.message(), finding.path)
+			}
+		}
+	}
+	if results == nil {
+		results = []sarifResult{}
+	}
+
+	log := &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: toolName, InformationUri: toolInfoUri, Rules: sarifRules}},
+			Results: results,
+		}},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
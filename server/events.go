@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"net/http"
+	"time"
+)
+
+const (
+	// how many events of a job are kept for subscribers that connect later
+	maxKeptEvents = 500
+
+	// how many events a subscriber may lag behind before it misses some
+	subscriberBuffer = 100
+)
+
+type eventJSON struct {
+	Kind       api.ProgressKind `json:"kind"`
+	Time       time.Time        `json:"time"`
+	Phase      string           `json:"phase,omitempty"`
+	DurationMs *int64           `json:"durationMs,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	Operation  string           `json:"operation,omitempty"`
+	Stage      string           `json:"stage,omitempty"`
+	Current    *int             `json:"current,omitempty"`
+	Total      *int             `json:"total,omitempty"`
+	Percent    *int             `json:"percent,omitempty"`
+	Message    string           `json:"message,omitempty"`
+}
+
+func toEventJSON(event api.ProgressEvent) eventJSON {
+	result := eventJSON{
+		Kind:      event.Kind,
+		Time:      event.Time,
+		Phase:     event.Phase,
+		Operation: event.Operation,
+		Stage:     event.Stage,
+		Message:   event.Message,
+	}
+	switch event.Kind {
+	case api.ProgressPhaseFinished:
+		durationMs := event.Duration.Milliseconds()
+		result.DurationMs = &durationMs
+		if event.Err != nil {
+			result.Error = event.Err.Error()
+		}
+	case api.ProgressTransfer, api.ProgressRender:
+		result.Current, result.Total, result.Percent = &event.Current, &event.Total, &event.Percent
+	}
+	return result
+}
+
+// publish keeps the event, and passes it on to the subscribers of the job. Subscribers that
+// cannot keep up miss events rather than slowing down the job.
+func (s *Server) publish(id string, event api.ProgressEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.active[id]
+	if !ok {
+		return
+	}
+	a.events = append(a.events, event)
+	if len(a.events) > maxKeptEvents {
+		a.events = a.events[len(a.events)-maxKeptEvents:]
+	}
+	for ch := range a.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// subscribe returns the events so far and a channel for the ones to come, which is closed when the job ends.
+// It returns false if the job is not queued or running.
+func (s *Server) subscribe(id string) (*activeJob, chan api.ProgressEvent, []api.ProgressEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.active[id]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	ch := make(chan api.ProgressEvent, subscriberBuffer)
+	a.subscribers[ch] = true
+	return a, ch, append([]api.ProgressEvent{}, a.events...), true
+}
+
+func (s *Server) unsubscribe(a *activeJob, ch chan api.ProgressEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a retired job has closed its channels already
+	if a.subscribers[ch] {
+		delete(a.subscribers, ch)
+		close(ch)
+	}
+}
+
+// handleEvents streams the progress of a job as server-sent events, named after the event kind.
+// The stream ends with a 'status' event carrying the job status once the job has ended.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+	if _, err := s.Get(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	// subscribe before answering, so clients that got the headers do not miss anything
+	a, ch, history, active := s.subscribe(id)
+	if active {
+		defer s.unsubscribe(a, ch)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range history {
+		writeEvent(w, string(event.Kind), toEventJSON(event))
+	}
+	flusher.Flush()
+	if active && !s.stream(r.Context(), w, flusher, ch) {
+		return
+	}
+
+	// the job has ended, so the store has its final status
+	record, err := s.Get(context.Background(), id)
+	if err != nil {
+		writeEvent(w, "error", errorJSON{Error: err.Error()})
+	} else {
+		writeEvent(w, "status", toStatus(record, false))
+	}
+	flusher.Flush()
+}
+
+// stream passes events on until the channel is closed, or returns false if the client went away first.
+func (s *Server) stream(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, ch chan api.ProgressEvent) bool {
+	for {
+		select {
+		case event, open := <-ch:
+			if !open {
+				return true
+			}
+			writeEvent(w, string(event.Kind), toEventJSON(event))
+			flusher.Flush()
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, name string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+}
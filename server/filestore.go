@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// FileStore keeps every record as a json file in a directory, so records survive restarts.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+var validId = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// NewFileStore creates the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) Save(_ context.Context, record *Record) error {
+	path, err := f.path(record.ID)
+	if err != nil {
+		return err
+	}
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// write and rename, so readers never see half a record
+	tmp, err := ioutil.TempFile(f.dir, ".tmp-"+record.ID+"-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(contents); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *FileStore) Load(_ context.Context, id string) (*Record, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return readRecord(path)
+}
+
+func (f *FileStore) List(_ context.Context) ([]*Record, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	result := []*Record{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		record, err := readRecord(filepath.Join(f.dir, entry.Name()))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				// deleted while listing
+				continue
+			}
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	sortRecords(result)
+	return result, nil
+}
+
+func (f *FileStore) Delete(_ context.Context, id string) error {
+	path, err := f.path(id)
+	if err != nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *FileStore) path(id string) (string, error) {
+	if !validId.MatchString(id) {
+		return "", errors.New("invalid job id '" + id + "'")
+	}
+	return filepath.Join(f.dir, id+".json"), nil
+}
+
+func readRecord(path string) (*Record, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	record := &Record{}
+	if err := json.Unmarshal(contents, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mplushnikov/go-generator-git/v2/jobfile"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// limit for submitted job entries
+const maxRequestBytes = 1 << 20
+
+type statusJSON struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name,omitempty"`
+	Generator string      `json:"generator"`
+	TargetUrl string      `json:"targetUrl"`
+	Status    Status      `json:"status"`
+	Submitted time.Time   `json:"submitted"`
+	Started   *time.Time  `json:"started,omitempty"`
+	Finished  *time.Time  `json:"finished,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Result    interface{} `json:"result,omitempty"`
+}
+
+type errorJSON struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.handleSubmit(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.handleList(w, r)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		s.withRecord(w, r, parts[1], func(record *Record) {
+			writeJSON(w, http.StatusOK, toStatus(record, true))
+		})
+	case len(parts) == 3 && parts[2] == "files" && r.Method == http.MethodGet:
+		s.withFinishedRecord(w, r, parts[1], func(record *Record) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"id": record.ID, "files": nonNil(record.RenderedFiles)})
+		})
+	case len(parts) == 3 && parts[2] == "diff" && r.Method == http.MethodGet:
+		s.withFinishedRecord(w, r, parts[1], func(record *Record) {
+			writeDiff(w, r, record)
+		})
+	case len(parts) == 3 && parts[2] == "errors" && r.Method == http.MethodGet:
+		s.withRecord(w, r, parts[1], func(record *Record) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"id": record.ID, "status": record.Status, "errors": nonNil(record.Errors)})
+		})
+	case len(parts) == 3 && parts[2] == "events" && r.Method == http.MethodGet:
+		s.handleEvents(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "cancel" && r.Method == http.MethodPost:
+		record, err := s.Cancel(r.Context(), parts[1])
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, toStatus(record, false))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not supported for %s", r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	entry := jobfile.Entry{}
+	// yaml is a superset of json, so this reads both, with the same keys as in job files
+	if err := yaml.UnmarshalStrict(body, &entry); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("cannot read job: %w", err))
+		return
+	}
+
+	record, err := s.Submit(r.Context(), entry)
+	if err != nil {
+		var validationErr *jobfile.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			writeJSON(w, http.StatusBadRequest, errorJSON{Error: "invalid job", Problems: validationErr.Problems})
+		case errors.Is(err, ErrQueueFull), errors.Is(err, ErrClosed):
+			writeError(w, http.StatusServiceUnavailable, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+record.ID)
+	writeJSON(w, http.StatusAccepted, toStatus(record, false))
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	records, err := s.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	result := []statusJSON{}
+	for _, record := range records {
+		result = append(result, toStatus(record, false))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": result})
+}
+
+func (s *Server) withRecord(w http.ResponseWriter, r *http.Request, id string, handle func(record *Record)) {
+	record, err := s.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	handle(record)
+}
+
+// withFinishedRecord answers 409 while the job has not finished, as there are no results yet.
+func (s *Server) withFinishedRecord(w http.ResponseWriter, r *http.Request, id string, handle func(record *Record)) {
+	s.withRecord(w, r, id, func(record *Record) {
+		if !record.Status.Done() {
+			writeError(w, http.StatusConflict, fmt.Errorf("job %s is %s", record.ID, record.Status))
+			return
+		}
+		handle(record)
+	})
+}
+
+func writeDiff(w http.ResponseWriter, r *http.Request, record *Record) {
+	if r.URL.Query().Get("format") != "patch" {
+		changes := record.Changes
+		if changes == nil {
+			changes = []Change{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": record.ID, "changes": changes})
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	for _, c := range record.Changes {
+		if c.Binary {
+			fmt.Fprintf(w, "Binary files a/%s and b/%s differ\n", c.Path, c.Path)
+			continue
+		}
+		fmt.Fprint(w, c.Diff)
+	}
+}
+
+func toStatus(record *Record, withResult bool) statusJSON {
+	result := statusJSON{
+		ID:        record.ID,
+		Name:      record.Name,
+		Generator: record.Generator,
+		TargetUrl: record.TargetUrl,
+		Status:    record.Status,
+		Submitted: record.Submitted,
+		Started:   record.Started,
+		Finished:  record.Finished,
+		Error:     record.Error,
+	}
+	if withResult && len(record.Result) > 0 {
+		result.Result = json.RawMessage(record.Result)
+	}
+	return result
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorJSON{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(value)
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,376 @@
+// Package server runs generator jobs behind a REST API, so a web portal can trigger generation
+// without wrapping the library itself.
+//
+//	POST /jobs                submit a job, answers 202 with the job status
+//	GET  /jobs                list all jobs
+//	GET  /jobs/{id}           status of a job
+//	GET  /jobs/{id}/files     rendered files
+//	GET  /jobs/{id}/diff      changes to the target, ?format=patch for a plain unified diff
+//	GET  /jobs/{id}/errors    all errors of the job
+//	GET  /jobs/{id}/events    progress of a job as server-sent events
+//	POST /jobs/{id}/cancel    cancel a queued or running job
+//
+// Jobs are submitted as a single job file entry (see package jobfile) in json or yaml. Requests never
+// contain credentials, they come from Options.ResolveAuth.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/pipeline"
+	"github.com/mplushnikov/go-generator-git/v2/jobfile"
+	"path"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkers   = 2
+	defaultQueueSize = 100
+)
+
+// ErrQueueFull is returned by Submit when the queue has no room for another job.
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrClosed is returned by Submit after Close.
+var ErrClosed = errors.New("server is shutting down")
+
+type Options struct {
+	// where to keep job records, defaults to a MemoryStore
+	Store JobStore
+
+	// number of jobs that run at the same time, defaults to 2
+	Workers int
+
+	// number of jobs that may wait for a worker, defaults to 100. Submissions beyond that are rejected.
+	QueueSize int
+
+	// settings for every job that does not set them itself, e.g. the source repository or the commit author
+	Defaults jobfile.Entry
+
+	// obtains credentials for repository urls, may be nil
+	ResolveAuth jobfile.AuthResolver
+
+	// asked for credentials ResolveAuth has none for, may be nil, see api.Job.Credentials
+	Credentials api.CredentialResolver
+
+	// base path for the working directories of the jobs, defaults to the system temp directory
+	WorkdirBase string
+
+	// creates a fresh instance for every job, defaults to generatorgit.ThreadsafeInstance
+	NewInstance func() api.GitApi
+
+	// protocols jobs may use for the source, target and mirrors, checked after url rewriting. Defaults to
+	// http, https and ssh, so that submitted jobs cannot clone from or push to repositories on the disk of
+	// the server. Add "file" only if every client may do that.
+	AllowedSchemes []string
+
+	// hosts jobs may use, as path.Match patterns like "*.example.com", checked after url rewriting.
+	// Defaults to any host.
+	AllowedHosts []string
+}
+
+// Server queues jobs and runs them on a bounded pool of workers. It is an http.Handler.
+type Server struct {
+	options Options
+	store   JobStore
+	queue   chan string
+
+	mu     sync.Mutex
+	active map[string]*activeJob
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// a job that is queued or running
+type activeJob struct {
+	job       api.Job
+	ctx       context.Context
+	cancel    context.CancelFunc
+	running   bool
+	cancelled bool
+
+	// progress so far, and who is listening for more
+	events      []api.ProgressEvent
+	subscribers map[chan api.ProgressEvent]bool
+}
+
+// New starts the workers. Records left queued or running by an earlier process are marked as failed,
+// as their jobs cannot be resumed.
+func New(ctx context.Context, options Options) (*Server, error) {
+	if options.Store == nil {
+		options.Store = NewMemoryStore()
+	}
+	if options.Workers < 1 {
+		options.Workers = defaultWorkers
+	}
+	if options.QueueSize < 1 {
+		options.QueueSize = defaultQueueSize
+	}
+	if options.NewInstance == nil {
+		options.NewInstance = generatorgit.ThreadsafeInstance
+	}
+	if options.AllowedSchemes == nil {
+		options.AllowedSchemes = defaultAllowedSchemes
+	}
+	for _, pattern := range options.AllowedHosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowed host pattern '%s': %w", pattern, err)
+		}
+	}
+
+	s := &Server{
+		options: options,
+		store:   options.Store,
+		queue:   make(chan string, options.QueueSize),
+		active:  make(map[string]*activeJob),
+	}
+	if err := s.failInterrupted(ctx); err != nil {
+		return nil, err
+	}
+	for w := 0; w < options.Workers; w++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s, nil
+}
+
+func (s *Server) failInterrupted(ctx context.Context) error {
+	records, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if !record.Status.Done() {
+			finish(record, StatusFailed, "interrupted by a restart of the server")
+			if err := s.store.Save(ctx, record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Submit validates the entry and queues it. The returned record has status queued.
+//
+// Invalid entries give a *jobfile.ValidationError, and a full queue gives ErrQueueFull.
+func (s *Server) Submit(ctx context.Context, entry jobfile.Entry) (*Record, error) {
+	// these settings name files on the server, so only Defaults may have them
+	if entry.Transport != nil {
+		return nil, &jobfile.ValidationError{Problems: []string{"transport can only be set in the defaults of the server"}}
+	}
+	if entry.HostKeys != nil {
+		return nil, &jobfile.ValidationError{Problems: []string{"host_keys can only be set in the defaults of the server"}}
+	}
+	f := &jobfile.File{Defaults: s.options.Defaults, Jobs: []jobfile.Entry{entry}}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	jobs, err := f.ToJobs(ctx, s.options.ResolveAuth)
+	if err != nil {
+		return nil, err
+	}
+	job := jobs[0]
+	if problems := s.checkUrls(job); len(problems) > 0 {
+		return nil, &jobfile.ValidationError{Problems: problems}
+	}
+	job.WorkdirBase = s.options.WorkdirBase
+	job.Credentials = s.options.Credentials
+
+	id, err := newId()
+	if err != nil {
+		return nil, err
+	}
+	record := &Record{
+		ID:        id,
+		Name:      job.Name,
+		Generator: job.Generator,
+		TargetUrl: job.Target.Url,
+		Status:    StatusQueued,
+		Submitted: time.Now(),
+	}
+	// jobs must not depend on the request they were submitted with
+	jobCtx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		cancel()
+		return nil, ErrClosed
+	}
+	if err := s.store.Save(ctx, record); err != nil {
+		cancel()
+		return nil, err
+	}
+	job.Progress = func(event api.ProgressEvent) {
+		s.publish(id, event)
+	}
+	select {
+	case s.queue <- id:
+		s.active[id] = &activeJob{job: job, ctx: jobCtx, cancel: cancel, subscribers: make(map[chan api.ProgressEvent]bool)}
+		aulogging.Logger.Ctx(ctx).Info().Printf("queued job %s (%s)", id, job.Name)
+		return record, nil
+	default:
+		cancel()
+		_ = s.store.Delete(ctx, id)
+		return nil, ErrQueueFull
+	}
+}
+
+// Get returns the record of a job, or ErrNotFound.
+func (s *Server) Get(ctx context.Context, id string) (*Record, error) {
+	return s.store.Load(ctx, id)
+}
+
+// List returns the records of all jobs, oldest first.
+func (s *Server) List(ctx context.Context) ([]*Record, error) {
+	return s.store.List(ctx)
+}
+
+// Cancel stops a queued or running job. Queued jobs are cancelled right away, running jobs once their
+// current step notices. Cancelling a finished job does nothing.
+func (s *Server) Cancel(ctx context.Context, id string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := s.active[id]
+	if !ok || a.cancelled {
+		return record, nil
+	}
+	a.cancelled = true
+	a.cancel()
+	if !a.running {
+		s.retire(id, a)
+		finish(record, StatusCancelled, "cancelled before it started")
+		if err := s.store.Save(ctx, record); err != nil {
+			return nil, err
+		}
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("cancelled job %s", id)
+	return record, nil
+}
+
+// Close stops accepting jobs, cancels the running ones, and waits for the workers until ctx is done.
+// Queued jobs are marked as cancelled.
+func (s *Server) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+		for _, a := range s.active {
+			a.cancelled = true
+			a.cancel()
+		}
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) work() {
+	defer s.wg.Done()
+	for id := range s.queue {
+		s.runJob(id)
+	}
+}
+
+func (s *Server) runJob(id string) {
+	ctx := context.Background()
+
+	s.mu.Lock()
+	a, ok := s.active[id]
+	if !ok {
+		// cancelled while queued
+		s.mu.Unlock()
+		return
+	}
+	record, err := s.store.Load(ctx, id)
+	if err != nil {
+		s.retire(id, a)
+		s.mu.Unlock()
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("cannot load job %s", id)
+		return
+	}
+	if a.cancelled {
+		s.retire(id, a)
+		finish(record, StatusCancelled, "cancelled before it started")
+		s.save(ctx, record)
+		s.mu.Unlock()
+		return
+	}
+	a.running = true
+	now := time.Now()
+	record.Status = StatusRunning
+	record.Started = &now
+	s.save(ctx, record)
+	s.mu.Unlock()
+
+	result, runErr := pipeline.Run(a.ctx, s.options.NewInstance(), a.job)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retire(id, a)
+	a.cancel()
+	if err := fillFromSession(record, result); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("cannot record the result of job %s", id)
+	}
+	switch {
+	case a.cancelled:
+		finish(record, StatusCancelled, "cancelled while running")
+	case runErr != nil:
+		finish(record, StatusFailed, runErr.Error())
+	default:
+		finish(record, StatusSucceeded, "")
+	}
+	s.save(ctx, record)
+	aulogging.Logger.Ctx(ctx).Info().Printf("job %s %s", id, record.Status)
+}
+
+// retire forgets a job that ended, and tells its subscribers. Must hold s.mu.
+func (s *Server) retire(id string, a *activeJob) {
+	delete(s.active, id)
+	for ch := range a.subscribers {
+		close(ch)
+	}
+	a.subscribers = nil
+}
+
+func (s *Server) save(ctx context.Context, record *Record) {
+	if err := s.store.Save(ctx, record); err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("cannot save job %s", record.ID)
+	}
+}
+
+func finish(record *Record, status Status, message string) {
+	now := time.Now()
+	record.Status = status
+	record.Finished = &now
+	record.Error = message
+}
+
+func newId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("cannot create job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
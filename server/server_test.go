@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/mplushnikov/go-generator-git/v2/jobfile"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const generatorSpec = `templates:
+  - source: src/main.go.tmpl
+    target: cmd/{{ .serviceName }}/main.go
+variables:
+  serviceName:
+    description: The name of the service
+    pattern: '^[a-z-]+$'
+    default: demo-service
+`
+
+// startServer serves jobs that generate from a local source into new local targets.
+func startServer(t *testing.T, options Options) (*Server, *httptest.Server) {
+	options.Defaults = jobfile.Entry{
+		Source:    jobfile.Source{Url: testrepo.Create(t, map[string]string{"generator-main.yaml": generatorSpec, "src/main.go.tmpl": "package main\n\n// {{ .serviceName }}\nfunc main() {\n}\n"})},
+		Target:    jobfile.Target{Branch: "main"},
+		Generator: "main",
+	}
+	// the file transport ignores credentials, but pushing needs some
+	options.ResolveAuth = func(context.Context, string) (transport.AuthMethod, error) {
+		return &githttp.BasicAuth{Username: "local"}, nil
+	}
+	options.WorkdirBase = t.TempDir()
+	if options.AllowedSchemes == nil {
+		// the repositories of the tests are local
+		options.AllowedSchemes = []string{"file"}
+	}
+	s, err := New(context.TODO(), options)
+	require.Nil(t, err)
+	httpServer := httptest.NewServer(s)
+	t.Cleanup(func() {
+		httpServer.Close()
+		require.Nil(t, s.Close(context.TODO()))
+	})
+	return s, httpServer
+}
+
+func call(t *testing.T, method string, url string, body string) (int, string) {
+	request, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	require.Nil(t, err)
+	response, err := http.DefaultClient.Do(request)
+	require.Nil(t, err)
+	defer response.Body.Close()
+	contents, err := ioutil.ReadAll(response.Body)
+	require.Nil(t, err)
+	return response.StatusCode, string(contents)
+}
+
+func submit(t *testing.T, baseUrl string, body string) statusJSON {
+	code, response := call(t, http.MethodPost, baseUrl+"/jobs", body)
+	require.Equal(t, http.StatusAccepted, code, response)
+	status := statusJSON{}
+	require.Nil(t, json.Unmarshal([]byte(response), &status))
+	require.Equal(t, StatusQueued, status.Status)
+	return status
+}
+
+func waitFor(t *testing.T, s *Server, id string, done func(status Status) bool) *Record {
+	var record *Record
+	require.Eventually(t, func() bool {
+		var err error
+		record, err = s.Get(context.TODO(), id)
+		require.Nil(t, err)
+		return done(record.Status)
+	}, 10*time.Second, 10*time.Millisecond)
+	return record
+}
+
+func TestSubmitAndFetchResults(t *testing.T) {
+	s, httpServer := startServer(t, Options{})
+	target := testrepo.Create(t, map[string]string{"README.md": "# target\n"})
+
+	status := submit(t, httpServer.URL, `{"name": "order", "target": {"url": "`+target+`"}, "parameters": {"serviceName": "order"}, "commit": {}, "push": {}}`)
+	record := waitFor(t, s, status.ID, Status.Done)
+	require.Equal(t, StatusSucceeded, record.Status, record.Error)
+
+	code, body := call(t, http.MethodGet, httpServer.URL+"/jobs/"+status.ID, "")
+	require.Equal(t, http.StatusOK, code)
+	parsed := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal([]byte(body), &parsed))
+	require.Equal(t, "succeeded", parsed["status"])
+	require.Equal(t, "order", parsed["name"])
+	require.Equal(t, true, parsed["result"].(map[string]interface{})["success"])
+
+	code, body = call(t, http.MethodGet, httpServer.URL+"/jobs/"+status.ID+"/files", "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"id": "`+status.ID+`", "files": ["cmd/order/main.go"]}`, body)
+
+	code, body = call(t, http.MethodGet, httpServer.URL+"/jobs/"+status.ID+"/diff?format=patch", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, "+++ b/cmd/order/main.go\n@@ -0,0 +1,5 @@\n+package main\n")
+	require.Contains(t, body, "+++ b/generated-main.yaml\n")
+
+	code, body = call(t, http.MethodGet, httpServer.URL+"/jobs/"+status.ID+"/diff", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, `"path": "cmd/order/main.go"`)
+	require.Contains(t, body, `"change": "added"`)
+
+	code, body = call(t, http.MethodGet, httpServer.URL+"/jobs/"+status.ID+"/errors", "")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"id": "`+status.ID+`", "status": "succeeded", "errors": []}`, body)
+
+	code, body = call(t, http.MethodGet, httpServer.URL+"/jobs", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, status.ID)
+}
+
+func TestSubmit_Invalid(t *testing.T) {
+	_, httpServer := startServer(t, Options{})
+
+	code, body := call(t, http.MethodPost, httpServer.URL+"/jobs", `{"update_strategy": "sideways"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.JSONEq(t, `{"error": "invalid job", "problems": ["job 1: target.url is required", "job 1: unknown update_strategy 'sideways'"]}`, body)
+
+	code, body = call(t, http.MethodPost, httpServer.URL+"/jobs", `{"transport": [{"host": "*", "ca_file": "/etc/passwd"}]}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.JSONEq(t, `{"error": "invalid job", "problems": ["transport can only be set in the defaults of the server"]}`, body)
+
+	code, body = call(t, http.MethodPost, httpServer.URL+"/jobs", `{"host_keys": {"trust_on_first_use": "/etc/passwd"}}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.JSONEq(t, `{"error": "invalid job", "problems": ["host_keys can only be set in the defaults of the server"]}`, body)
+
+	code, body = call(t, http.MethodPost, httpServer.URL+"/jobs", `{"unknown": true}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, body, "cannot read job")
+
+	code, _ = call(t, http.MethodGet, httpServer.URL+"/jobs/0123456789abcdef", "")
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+func TestSubmit_RefusedUrls(t *testing.T) {
+	_, httpServer := startServer(t, Options{AllowedSchemes: defaultAllowedSchemes, AllowedHosts: []string{"*.example.com"}})
+	source := `"source": {"url": "https://git.example.com/templates.git"}`
+
+	code, body := call(t, http.MethodPost, httpServer.URL+"/jobs", `{`+source+`, "target": {"url": "/srv/repos/other.git"}}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.JSONEq(t, `{"error": "invalid job", "problems": ["target.url: /srv/repos/other.git uses file, only http, https, ssh are allowed"]}`, body)
+
+	code, body = call(t, http.MethodPost, httpServer.URL+"/jobs", `{`+source+`, "target": {"url": "git@elsewhere.test:org/repo.git"}}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.JSONEq(t, `{"error": "invalid job", "problems": ["target.url: git@elsewhere.test:org/repo.git is on host elsewhere.test, which is not allowed"]}`, body)
+
+	code, body = call(t, http.MethodPost, httpServer.URL+"/jobs", `{`+source+`, "target": {"url": "https://git.example.com/service.git"},
+		"commit": {}, "push": {"mirrors": [{"name": "backup", "url": "file:///srv/repos/backup.git"}]},
+		"url_rewriting": {"instead_of": {"https://git.example.com/": "/srv/repos/"}}}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.JSONEq(t, `{"error": "invalid job", "problems": [
+		"source.url: /srv/repos/templates.git uses file, only http, https, ssh are allowed",
+		"target.url: /srv/repos/service.git uses file, only http, https, ssh are allowed",
+		"push.mirrors[0].url: file:///srv/repos/backup.git uses file, only http, https, ssh are allowed"]}`, body)
+}
+
+func TestSubmit_RenderSpecFileOutsideTheTarget(t *testing.T) {
+	_, httpServer := startServer(t, Options{})
+	target := testrepo.Create(t, map[string]string{"README.md": "# target\n"})
+
+	for _, renderSpecFile := range []string{"../../../tmp/x.yaml", "/tmp/x.yaml"} {
+		code, body := call(t, http.MethodPost, httpServer.URL+"/jobs", `{"target": {"url": "`+target+`"}, "render_spec_file": "`+renderSpecFile+`"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.JSONEq(t, `{"error": "invalid job", "problems": ["job 1: render_spec_file '`+renderSpecFile+`' is not a path inside the target"]}`, body)
+	}
+}
+
+func TestFailedJob(t *testing.T) {
+	s, httpServer := startServer(t, Options{})
+	target := testrepo.Create(t, map[string]string{"README.md": "# target\n"})
+
+	status := submit(t, httpServer.URL, `{"target": {"url": "`+target+`"}, "parameters": {"serviceName": "Not Valid"}}`)
+	record := waitFor(t, s, status.ID, Status.Done)
+	require.Equal(t, StatusFailed, record.Status)
+
+	code, body := call(t, http.MethodGet, httpServer.URL+"/jobs/"+status.ID+"/errors", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, `"status": "failed"`)
+	require.Contains(t, body, "serviceName")
+}
+
+// blockingInstances hands out instances only when released, so jobs stay running as long as a test needs.
+func blockingInstances() (func() api.GitApi, func()) {
+	release := make(chan struct{})
+	return func() api.GitApi {
+			<-release
+			return generatorgit.ThreadsafeInstance()
+		}, func() {
+			close(release)
+		}
+}
+
+func TestCancelAndQueueFull(t *testing.T) {
+	newInstance, release := blockingInstances()
+	s, httpServer := startServer(t, Options{Workers: 1, QueueSize: 1, NewInstance: newInstance})
+	target := testrepo.Create(t, map[string]string{"README.md": "# target\n"})
+	job := `{"target": {"url": "` + target + `"}, "commit": {}, "push": {}}`
+
+	running := submit(t, httpServer.URL, job)
+	waitFor(t, s, running.ID, func(status Status) bool { return status == StatusRunning })
+	queued := submit(t, httpServer.URL, job)
+
+	code, body := call(t, http.MethodPost, httpServer.URL+"/jobs", job)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Contains(t, body, "job queue is full")
+
+	code, body = call(t, http.MethodGet, httpServer.URL+"/jobs/"+queued.ID+"/files", "")
+	require.Equal(t, http.StatusConflict, code)
+	require.Contains(t, body, "is queued")
+
+	code, body = call(t, http.MethodPost, httpServer.URL+"/jobs/"+queued.ID+"/cancel", "")
+	require.Equal(t, http.StatusAccepted, code)
+	require.Contains(t, body, `"status": "cancelled"`)
+
+	code, body = call(t, http.MethodPost, httpServer.URL+"/jobs/"+running.ID+"/cancel", "")
+	require.Equal(t, http.StatusAccepted, code)
+	require.Contains(t, body, `"status": "running"`)
+	release()
+
+	record := waitFor(t, s, running.ID, Status.Done)
+	require.Equal(t, StatusCancelled, record.Status)
+	require.Equal(t, "", testrepo.ReadFile(t, target, "main", "generated-main.yaml"))
+}
+
+func TestProgressEvents(t *testing.T) {
+	newInstance, release := blockingInstances()
+	s, httpServer := startServer(t, Options{Workers: 1, NewInstance: newInstance})
+	target := testrepo.Create(t, map[string]string{"README.md": "# target\n"})
+
+	status := submit(t, httpServer.URL, `{"target": {"url": "`+target+`"}, "commit": {}, "push": {}}`)
+	waitFor(t, s, status.ID, func(status Status) bool { return status == StatusRunning })
+
+	response, err := http.Get(httpServer.URL + "/jobs/" + status.ID + "/events")
+	require.Nil(t, err)
+	defer response.Body.Close()
+	require.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	release()
+
+	stream, err := ioutil.ReadAll(response.Body)
+	require.Nil(t, err)
+	require.Contains(t, string(stream), "event: phase-started\ndata: {\"kind\":\"phase-started\"")
+	require.Contains(t, string(stream), "\"phase\":\"clone-source\"")
+	require.Contains(t, string(stream), "event: render\ndata: {\"kind\":\"render\"")
+	require.Contains(t, string(stream), "\"current\":1,\"total\":1,\"percent\":100")
+	require.Contains(t, string(stream), "\"phase\":\"cleanup\"")
+	require.Contains(t, string(stream), "event: status\ndata: {\"id\":\""+status.ID+"\"")
+	require.Contains(t, string(stream), "\"status\":\"succeeded\"")
+
+	// once the job has ended, there is only the status
+	code, body := call(t, http.MethodGet, httpServer.URL+"/jobs/"+status.ID+"/events", "")
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, strings.HasPrefix(body, "event: status\n"), body)
+}
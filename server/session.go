@@ -0,0 +1,63 @@
+package server
+
+import (
+	"bytes"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/report"
+)
+
+// fillFromSession copies what the API serves from the session result into the record.
+func fillFromSession(record *Record, session *api.SessionResult) error {
+	if session == nil {
+		return nil
+	}
+	if session.Generate != nil && session.Generate.Response != nil {
+		for _, f := range session.Generate.RenderedFiles {
+			record.RenderedFiles = append(record.RenderedFiles, f.RelativeFilePath)
+		}
+	}
+	for _, c := range session.Changes {
+		record.Changes = append(record.Changes, Change{Path: c.Path, Change: string(c.Change), Diff: c.Diff, Binary: c.Binary})
+	}
+	record.Errors = sessionErrors(session)
+
+	buffer := &bytes.Buffer{}
+	if err := report.WriteJSON(buffer, session); err != nil {
+		return err
+	}
+	record.Result = buffer.Bytes()
+	return nil
+}
+
+func sessionErrors(session *api.SessionResult) []string {
+	var result []string
+	add := func(err error) {
+		if err != nil {
+			result = append(result, err.Error())
+		}
+	}
+	for _, p := range session.Phases {
+		add(p.Err)
+	}
+	if session.RenderSpec != nil {
+		for _, err := range session.RenderSpec.Errors {
+			add(err)
+		}
+	}
+	if session.Generate != nil && session.Generate.Response != nil {
+		for _, err := range session.Generate.Errors {
+			add(err)
+		}
+		for _, f := range session.Generate.RenderedFiles {
+			for _, err := range f.Errors {
+				add(err)
+			}
+		}
+	}
+	if session.Commit != nil {
+		for _, p := range session.Commit.PushResults {
+			add(p.Err)
+		}
+	}
+	return result
+}
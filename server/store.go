@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Done is true once the job will not change any more.
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCancelled
+}
+
+// ErrNotFound is returned by a JobStore for unknown ids.
+var ErrNotFound = errors.New("job not found")
+
+// Record is what the server knows about a job. It must survive a round trip through encoding/json,
+// so stores can keep it anywhere.
+type Record struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Generator string     `json:"generator"`
+	TargetUrl string     `json:"targetUrl"`
+	Status    Status     `json:"status"`
+	Submitted time.Time  `json:"submitted"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+
+	// the error that ended the job, if any
+	Error string `json:"error,omitempty"`
+
+	// paths of the rendered files, relative to the target
+	RenderedFiles []string `json:"renderedFiles,omitempty"`
+
+	// changes to the target, with unified diffs
+	Changes []Change `json:"changes,omitempty"`
+
+	// every error of the session, from phases, parameter validation, rendering and pushing
+	Errors []string `json:"errors,omitempty"`
+
+	// the complete session result, as written by report.WriteJSON
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+type Change struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	Diff   string `json:"diff,omitempty"`
+	Binary bool   `json:"binary,omitempty"`
+}
+
+// JobStore keeps job records. Implementations must be safe for concurrent use.
+type JobStore interface {
+	// Save creates or replaces the record with the same id.
+	Save(ctx context.Context, record *Record) error
+
+	// Load returns the record, or ErrNotFound.
+	Load(ctx context.Context, id string) (*Record, error)
+
+	// List returns all records, oldest submission first.
+	List(ctx context.Context) ([]*Record, error)
+
+	// Delete removes the record. Deleting an unknown id is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore keeps records in memory, so they are lost when the process ends.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record)}
+}
+
+func (m *MemoryStore) Save(_ context.Context, record *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[record.ID] = copyRecord(record)
+	return nil
+}
+
+func (m *MemoryStore) Load(_ context.Context, id string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyRecord(record), nil
+}
+
+func (m *MemoryStore) List(_ context.Context) ([]*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]*Record, 0, len(m.records))
+	for _, record := range m.records {
+		result = append(result, copyRecord(record))
+	}
+	sortRecords(result)
+	return result, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, id)
+	return nil
+}
+
+// copyRecord keeps callers from changing stored records. The slices are never modified in place, so
+// sharing them is fine.
+func copyRecord(record *Record) *Record {
+	c := *record
+	return &c
+}
+
+func sortRecords(records []*Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Submitted.Equal(records[j].Submitted) {
+			return records[i].ID < records[j].ID
+		}
+		return records[i].Submitted.Before(records[j].Submitted)
+	})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, store JobStore) {
+	ctx := context.TODO()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	later := now.Add(time.Second)
+
+	require.Nil(t, store.Save(ctx, &Record{ID: "b", Status: StatusQueued, Submitted: later}))
+	require.Nil(t, store.Save(ctx, &Record{ID: "a", Status: StatusQueued, Submitted: now}))
+	require.Nil(t, store.Save(ctx, &Record{ID: "a", Status: StatusSucceeded, Submitted: now, Finished: &later, RenderedFiles: []string{"main.go"}, Result: []byte(`{"success":true}`)}))
+
+	record, err := store.Load(ctx, "a")
+	require.Nil(t, err)
+	require.Equal(t, StatusSucceeded, record.Status)
+	require.Equal(t, []string{"main.go"}, record.RenderedFiles)
+	require.True(t, later.Equal(*record.Finished))
+	require.JSONEq(t, `{"success":true}`, string(record.Result))
+
+	records, err := store.List(ctx)
+	require.Nil(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "a", records[0].ID)
+	require.Equal(t, "b", records[1].ID)
+
+	require.Nil(t, store.Delete(ctx, "a"))
+	require.Nil(t, store.Delete(ctx, "a"))
+	_, err = store.Load(ctx, "a")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Load(ctx, "../escape")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.Nil(t, err)
+	testStore(t, store)
+}
+
+func TestNew_FailsInterruptedJobs(t *testing.T) {
+	ctx := context.TODO()
+	store, err := NewFileStore(t.TempDir())
+	require.Nil(t, err)
+	require.Nil(t, store.Save(ctx, &Record{ID: "running", Status: StatusRunning, Submitted: time.Now()}))
+	require.Nil(t, store.Save(ctx, &Record{ID: "done", Status: StatusSucceeded, Submitted: time.Now()}))
+
+	s, err := New(ctx, Options{Store: store})
+	require.Nil(t, err)
+	defer s.Close(ctx)
+
+	record, err := s.Get(ctx, "running")
+	require.Nil(t, err)
+	require.Equal(t, StatusFailed, record.Status)
+	require.Equal(t, "interrupted by a restart of the server", record.Error)
+	record, err = s.Get(ctx, "done")
+	require.Nil(t, err)
+	require.Equal(t, StatusSucceeded, record.Status)
+}
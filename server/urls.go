@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/internal/telemetry"
+	"github.com/mplushnikov/go-generator-git/v2/internal/urlrewrite"
+	"path"
+	"strings"
+)
+
+var defaultAllowedSchemes = []string{"http", "https", "ssh"}
+
+// checkUrls lists the repository urls of the job that Options.AllowedSchemes or Options.AllowedHosts refuse.
+func (s *Server) checkUrls(job api.Job) []string {
+	// validated by ToJobs already
+	rules, _ := urlrewrite.New(job.UrlRewriting)
+
+	fields := []string{"source.url", "target.url"}
+	urls := []string{job.Source.Url, job.Target.Url}
+	if job.Push != nil {
+		for i, m := range job.Push.Mirrors {
+			fields = append(fields, fmt.Sprintf("push.mirrors[%d].url", i))
+			urls = append(urls, m.Url)
+		}
+	}
+
+	var problems []string
+	for i, url := range urls {
+		rewritten := rules.Rewrite(url)
+		if reason := s.refuse(rewritten); reason != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s %s", fields[i], telemetry.SafeUrl(rewritten), reason))
+		}
+	}
+	return problems
+}
+
+// refuse returns why url is not allowed, or "" if it is.
+func (s *Server) refuse(url string) string {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return "is not a valid url"
+	}
+	if !contains(s.options.AllowedSchemes, endpoint.Protocol) {
+		return fmt.Sprintf("uses %s, only %s are allowed", endpoint.Protocol, strings.Join(s.options.AllowedSchemes, ", "))
+	}
+	if len(s.options.AllowedHosts) == 0 {
+		return ""
+	}
+	host := strings.ToLower(endpoint.Host)
+	for _, pattern := range s.options.AllowedHosts {
+		if matched, _ := path.Match(strings.ToLower(pattern), host); matched {
+			return ""
+		}
+	}
+	return fmt.Sprintf("is on host %s, which is not allowed", host)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
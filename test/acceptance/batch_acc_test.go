@@ -0,0 +1,92 @@
+package acceptance
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/mplushnikov/go-generator-git/v2/jobfile"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBatch_JobFileDrivesRegeneration(t *testing.T) {
+	docs.Given("a generator and three target repositories")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	orderUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	billingUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	brokenUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.Given("a job file with one job per target, one of them with an invalid parameter")
+	jobFilePath := filepath.Join(t.TempDir(), "jobs.yaml")
+	require.Nil(t, os.WriteFile(jobFilePath, []byte(fmt.Sprintf(`defaults:
+  source:
+    url: %s
+  target:
+    branch: main
+  generator: main
+  commit:
+    author_name: bot
+    author_email: bot@example.com
+  push: {}
+jobs:
+  - name: order
+    target:
+      url: %s
+    parameters:
+      serviceName: order-service
+  - name: broken
+    target:
+      url: %s
+    parameters:
+      serviceName: Not Valid
+  - name: billing
+    target:
+      url: %s
+    parameters:
+      serviceName: billing-service
+`, sourceUrl, orderUrl, brokenUrl, billingUrl)), 0644))
+
+	docs.When("the job file is loaded and run as a batch")
+	f, err := jobfile.Load(jobFilePath)
+	require.Nil(t, err)
+	jobs, err := f.ToJobs(context.TODO(), func(_ context.Context, url string) (transport.AuthMethod, error) {
+		return localPushAuth, nil
+	})
+	require.Nil(t, err)
+	results, err := generatorgit.RunBatch(context.TODO(), jobs, api.BatchOptions{Parallelism: 2})
+
+	docs.Then("the valid jobs regenerate their targets, and the broken one is reported")
+	require.EqualError(t, err, "1 of 3 jobs failed, 0 skipped")
+	require.Len(t, results, 3)
+	require.True(t, results[0].Success())
+	require.Equal(t, "broken", results[1].JobName)
+	require.False(t, results[1].Success())
+	require.True(t, results[2].Success())
+	require.Contains(t, testrepo.ReadFile(t, orderUrl, "main", "cmd/order-service/main.go"), "order-service")
+	require.Contains(t, testrepo.ReadFile(t, billingUrl, "main", "cmd/billing-service/main.go"), "billing-service")
+	require.Equal(t, "", testrepo.ReadFile(t, brokenUrl, "main", "generated-main.yaml"))
+}
+
+func TestBatch_StopOnError(t *testing.T) {
+	docs.Given("a batch whose first job fails")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	jobs := []api.Job{
+		{Name: "broken", Source: api.SourceSpec{Url: sourceUrl}, Target: api.TargetSpec{Url: targetUrl, Branch: "main"}, Generator: "missing"},
+		{Name: "fine", Source: api.SourceSpec{Url: sourceUrl}, Target: api.TargetSpec{Url: targetUrl, Branch: "main"}, Generator: "main"},
+	}
+
+	docs.When("the batch is run with StopOnError")
+	results, err := generatorgit.RunBatch(context.TODO(), jobs, api.BatchOptions{StopOnError: true})
+
+	docs.Then("the remaining jobs are skipped")
+	require.EqualError(t, err, "1 of 2 jobs failed, 1 skipped")
+	require.False(t, results[0].Success())
+	require.Nil(t, results[1])
+}
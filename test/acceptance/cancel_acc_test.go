@@ -0,0 +1,110 @@
+package acceptance
+
+import (
+	"context"
+	"errors"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+// cancelBeforeCommit cancels the context of the session when the commit is about to happen
+type cancelBeforeCommit struct {
+	api.NoHooks
+	cancel context.CancelFunc
+}
+
+func (h cancelBeforeCommit) BeforeCommit(context.Context, api.HookSession) error {
+	h.cancel()
+	return nil
+}
+
+func requireEmptyDir(t *testing.T, dir string) {
+	entries, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	require.Empty(t, entries)
+}
+
+func TestCancel_BeforeStart(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	workdirBase := t.TempDir()
+
+	docs.When("a job is run with a context that is already cancelled")
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	result, err := generatorgit.Run(ctx, api.Job{
+		WorkdirBase: workdirBase,
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+	})
+
+	docs.Then("nothing is started, not even the working directory")
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, result.Phases, 1)
+	require.Equal(t, api.PhaseCreateWorkdir, result.Phases[0].Name)
+	requireEmptyDir(t, workdirBase)
+}
+
+func TestCancel_StopsBeforeCommit(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	workdirBase := t.TempDir()
+
+	docs.When("the session is cancelled while it is about to commit and push")
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	_, err := generatorgit.Run(ctx, api.Job{
+		WorkdirBase: workdirBase,
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Commit:      &api.CommitSpec{},
+		Push:        &api.PushSpec{Auth: localPushAuth},
+		Hooks:       []api.Hooks{cancelBeforeCommit{cancel: cancel}},
+	})
+
+	docs.Then("nothing is committed or pushed, and the working directory is cleaned up")
+	require.ErrorIs(t, err, context.Canceled)
+	var apiErr *api.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, api.PhaseCommitAndPush, apiErr.Phase)
+	require.Equal(t, "", testrepo.ReadFile(t, targetUrl, "main", "generated-main.yaml"))
+	requireEmptyDir(t, workdirBase)
+}
+
+func TestCancel_PhaseTimeout(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	workdirBase := t.TempDir()
+
+	docs.When("a job is run with a timeout for generate that cannot be met")
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: workdirBase,
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Timeouts:    map[string]time.Duration{api.PhaseCloneSource: time.Minute, api.PhaseGenerate: time.Nanosecond},
+	})
+
+	docs.Then("the phases before it succeed, generate fails with a timeout, and cleanup still runs")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	var names []string
+	for _, p := range result.Phases {
+		names = append(names, p.Name)
+	}
+	require.Equal(t, []string{api.PhaseCreateWorkdir, api.PhaseCloneSource, api.PhaseCloneTarget,
+		api.PhaseWriteRenderSpec, api.PhaseGenerate, api.PhaseCleanup}, names)
+	require.Nil(t, result.Phases[3].Err)
+	require.ErrorIs(t, result.Phases[4].Err, context.DeadlineExceeded)
+	requireEmptyDir(t, workdirBase)
+}
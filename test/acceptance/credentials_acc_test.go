@@ -0,0 +1,74 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// recordingResolver hands out the local push auth for write access, and records what it was asked for
+type recordingResolver struct {
+	requests []string
+}
+
+func (r *recordingResolver) Resolve(_ context.Context, repoUrl string, access api.Access) (transport.AuthMethod, error) {
+	r.requests = append(r.requests, access.String()+" "+repoUrl)
+	if access == api.AccessWrite {
+		return localPushAuth, nil
+	}
+	return nil, nil
+}
+
+func TestCredentials_ResolvedPerRepository(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.When("a job that pushes is run with a credential resolver instead of auth methods")
+	resolver := &recordingResolver{}
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Commit:      &api.CommitSpec{},
+		Push:        &api.PushSpec{},
+		Credentials: resolver,
+	})
+
+	docs.Then("the resolver is asked for read access to both repositories and write access to the target")
+	require.Nil(t, err)
+	require.Equal(t, []string{"read " + sourceUrl, "read " + targetUrl, "write " + targetUrl}, resolver.requests)
+
+	docs.Then("the commit is pushed with the credentials of the resolver")
+	require.Len(t, result.Commit.PushResults, 1)
+	require.Contains(t, testrepo.ReadFile(t, targetUrl, "main", "generated-main.yaml"), "demo-service")
+}
+
+func TestCredentials_NoWriteAccessWithoutPush(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.When("a job that only commits is run with a credential resolver")
+	resolver := &recordingResolver{}
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Commit:      &api.CommitSpec{},
+		Credentials: resolver,
+	})
+
+	docs.Then("nothing is pushed, even though the resolver has write credentials")
+	require.Nil(t, err)
+	require.NotEmpty(t, result.Commit.CommitHash)
+	require.Empty(t, result.Commit.PushResults)
+	require.Equal(t, "", testrepo.ReadFile(t, targetUrl, "main", "generated-main.yaml"))
+}
@@ -0,0 +1,90 @@
+package acceptance
+
+import (
+	"context"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDrift_ReportsDifferencesWithoutTouchingTheTarget(t *testing.T) {
+	docs.Given("a target that was generated with a manifest")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	_, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, func(gen api.GitApi) {
+		gen.SetPruneOrphans(true)
+	})
+	require.Nil(t, err)
+
+	docs.Given("a hand edited file and a deleted file in the target")
+	testrepo.PushCommit(t, targetUrl, map[string]string{
+		"cmd/demo-service/main.go": "package main\n\n// demo-service\nfunc main() {\n\thuman()\n}\n",
+		"README.md":                "",
+	}, "human edits")
+
+	docs.Given("a newer generator version that produces an additional file")
+	testrepo.PushCommit(t, sourceUrl, map[string]string{
+		"src/Dockerfile.tmpl": "FROM scratch\n",
+		"generator-main.yaml": "templates:\n  - source: src/main.go.tmpl\n    target: cmd/{{ .serviceName }}/main.go\n  - source: src/README.md.tmpl\n    target: README.md\n  - source: src/Dockerfile.tmpl\n    target: Dockerfile\nvariables:\n  serviceName:\n    default: demo-service\n",
+	}, "newer generator version")
+
+	ctx := context.TODO()
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+	_, err = gen.CloneSourceRepo(ctx, sourceUrl, "main", nil)
+	require.Nil(t, err)
+	targetRepo, err := gen.CloneTargetRepo(ctx, targetUrl, "main", "main", nil)
+	require.Nil(t, err)
+
+	docs.When("drift detection is run")
+	result, err := gen.DetectDrift(ctx)
+
+	docs.Then("hand edited, missing, and added files are reported")
+	require.Nil(t, err)
+	require.Equal(t, []string{"generated-main.yaml"}, result.RenderSpecFiles)
+	require.Equal(t, []string{"cmd/demo-service/main.go"}, result.Modified)
+	require.Equal(t, []string{"README.md"}, result.Missing)
+	require.Equal(t, []string{"Dockerfile"}, result.Added)
+	require.Empty(t, result.Unchanged)
+	require.True(t, result.HasDrift())
+	require.Equal(t, 1.0, result.Score())
+
+	docs.Then("the target worktree is unchanged")
+	contents, err := os.ReadFile(filepath.Join(targetRepo.GetLocalPath(), "cmd/demo-service/main.go"))
+	require.Nil(t, err)
+	require.Contains(t, string(contents), "human()")
+	_, err = os.Stat(filepath.Join(targetRepo.GetLocalPath(), "Dockerfile"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestDrift_NoDriftRightAfterGeneration(t *testing.T) {
+	docs.Given("a freshly generated target")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	_, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{"serviceName": "other-service"}, nil)
+	require.Nil(t, err)
+
+	ctx := context.TODO()
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+	_, err = gen.CloneSourceRepo(ctx, sourceUrl, "main", nil)
+	require.Nil(t, err)
+	_, err = gen.CloneTargetRepo(ctx, targetUrl, "main", "main", nil)
+	require.Nil(t, err)
+
+	docs.When("drift detection is run")
+	result, err := gen.DetectDrift(ctx)
+
+	docs.Then("all generated files are unchanged")
+	require.Nil(t, err)
+	require.Equal(t, []string{"README.md", "cmd/other-service/main.go"}, result.Unchanged)
+	require.False(t, result.HasDrift())
+	require.Equal(t, 0.0, result.Score())
+}
@@ -0,0 +1,113 @@
+package acceptance
+
+import (
+	"context"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestErrors_WrongOrder(t *testing.T) {
+	docs.Given("an instance with a temporary workdir, but no clones")
+	ctx := context.TODO()
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+
+	docs.When("Generate is called")
+	_, err := gen.Generate(ctx)
+
+	docs.Then("the error says the calls were in the wrong order, and comes from the generate phase")
+	require.ErrorIs(t, err, api.ErrWrongOrder)
+	var apiErr *api.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, api.PhaseGenerate, apiErr.Phase)
+	require.Equal(t, "implementation error - must clone source before using it", err.Error())
+}
+
+func TestErrors_BranchNotFound(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	ctx := context.TODO()
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+
+	docs.When("the source is cloned from a branch that does not exist")
+	_, err := gen.CloneSourceRepo(ctx, sourceUrl, "no-such-branch", nil)
+
+	docs.Then("the error is a missing branch of the source repo, and still is the error of go-git")
+	require.ErrorIs(t, err, api.ErrBranchNotFound)
+	require.ErrorIs(t, err, git.NoMatchingRefSpecError{})
+	var apiErr *api.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, api.PhaseCloneSource, apiErr.Phase)
+	require.Equal(t, sourceUrl, apiErr.Repo)
+
+	docs.When("the target is cloned with a base branch that does not exist")
+	_, err = gen.CloneTargetRepo(ctx, targetUrl, "feature", "no-such-branch", nil)
+
+	docs.Then("the error is a missing branch of the target repo")
+	require.ErrorIs(t, err, api.ErrBranchNotFound)
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, api.PhaseCloneTarget, apiErr.Phase)
+	require.Equal(t, targetUrl, apiErr.Repo)
+}
+
+func TestErrors_InvalidParameters(t *testing.T) {
+	docs.Given("a local generator source and target repository, both cloned")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	ctx := context.TODO()
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+	_, err := gen.CloneSourceRepo(ctx, sourceUrl, "main", nil)
+	require.Nil(t, err)
+	_, err = gen.CloneTargetRepo(ctx, targetUrl, "main", "main", nil)
+	require.Nil(t, err)
+
+	docs.When("the render spec is written with a parameter that does not match its pattern")
+	_, err = gen.WriteRenderSpecFile(ctx, "main", "generated-main.yaml", map[string]interface{}{"serviceName": "Not Valid"})
+
+	docs.Then("the error is about invalid parameters")
+	require.ErrorIs(t, err, api.ErrInvalidParameters)
+	require.NotErrorIs(t, err, api.ErrWrongOrder)
+}
+
+func TestErrors_PushRejected(t *testing.T) {
+	docs.Given("a local generator source and target repository, both cloned and rendered")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	ctx := context.TODO()
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+	_, err := gen.CloneSourceRepo(ctx, sourceUrl, "main", nil)
+	require.Nil(t, err)
+	_, err = gen.CloneTargetRepo(ctx, targetUrl, "main", "main", nil)
+	require.Nil(t, err)
+	_, err = gen.WriteRenderSpecFile(ctx, "main", "generated-main.yaml", map[string]interface{}{})
+	require.Nil(t, err)
+	_, err = gen.Generate(ctx)
+	require.Nil(t, err)
+
+	docs.Given("somebody else pushed to the target branch in the meantime")
+	testrepo.PushCommit(t, targetUrl, map[string]string{"other.txt": "other\n"}, "concurrent change")
+
+	docs.When("the result is committed and pushed")
+	_, err = gen.CommitAndPushWithResult(ctx, "somebody", "somebody@mailinator.com", "generate", localPushAuth)
+
+	docs.Then("the push is rejected, from the commit phase of the target repo")
+	require.ErrorIs(t, err, api.ErrPushRejected)
+	var apiErr *api.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, api.PhaseCommitAndPush, apiErr.Phase)
+	require.Equal(t, targetUrl, apiErr.Repo)
+}
@@ -0,0 +1,116 @@
+package acceptance
+
+import (
+	"context"
+	"errors"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// auditHooks records every call, and adds a CODEOWNERS file after rendering
+type auditHooks struct {
+	calls []string
+}
+
+func (h *auditHooks) AfterSourceClone(_ context.Context, session api.HookSession) error {
+	h.calls = append(h.calls, "after source clone")
+	return nil
+}
+
+func (h *auditHooks) AfterTargetCheckout(_ context.Context, session api.HookSession) error {
+	h.calls = append(h.calls, "after target checkout on "+session.TargetBranch)
+	return nil
+}
+
+func (h *auditHooks) BeforeRender(_ context.Context, session api.HookSession) error {
+	h.calls = append(h.calls, "before render of "+session.Generator)
+	return nil
+}
+
+func (h *auditHooks) AfterRender(_ context.Context, session api.HookSession, result *api.GenerateResult) error {
+	h.calls = append(h.calls, "after render")
+	return os.WriteFile(filepath.Join(session.TargetPath, "CODEOWNERS"), []byte("* @platform\n"), 0644)
+}
+
+func (h *auditHooks) BeforeCommit(_ context.Context, session api.HookSession) error {
+	h.calls = append(h.calls, "before commit")
+	return nil
+}
+
+func (h *auditHooks) AfterPush(_ context.Context, session api.HookSession, result *api.CommitResult) error {
+	h.calls = append(h.calls, "after push of "+result.CommitHash)
+	return nil
+}
+
+type vetoCommit struct {
+	api.NoHooks
+}
+
+var errNotApproved = errors.New("not approved")
+
+func (vetoCommit) BeforeCommit(context.Context, api.HookSession) error {
+	return errNotApproved
+}
+
+func TestHooks_CalledInOrderAndCanAddFiles(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.When("a job is run with hooks that audit every step and add a CODEOWNERS file")
+	hooks := &auditHooks{}
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Commit:      &api.CommitSpec{},
+		Push:        &api.PushSpec{Auth: localPushAuth},
+		Hooks:       []api.Hooks{hooks},
+	})
+
+	docs.Then("every hook is called once, in order")
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		"after source clone",
+		"after target checkout on main",
+		"before render of main",
+		"after render",
+		"before commit",
+		"after push of " + result.Commit.CommitHash,
+	}, hooks.calls)
+
+	docs.Then("the file added by the hook is committed along with the generated files")
+	require.Equal(t, "* @platform\n", testrepo.ReadFile(t, targetUrl, "main", "CODEOWNERS"))
+	require.Contains(t, testrepo.ReadFile(t, targetUrl, "main", "cmd/demo-service/main.go"), "demo-service")
+}
+
+func TestHooks_VetoStopsTheStep(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.When("a job is run with a hook that vetoes the commit")
+	audit := &auditHooks{}
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Commit:      &api.CommitSpec{},
+		Push:        &api.PushSpec{Auth: localPushAuth},
+		Hooks:       []api.Hooks{vetoCommit{}, audit},
+	})
+
+	docs.Then("the commit phase fails with the error of the hook, and later hooks are not called")
+	require.ErrorIs(t, err, errNotApproved)
+	require.Equal(t, api.PhaseCommitAndPush, result.Phases[len(result.Phases)-2].Name)
+	require.NotContains(t, audit.calls, "before commit")
+	require.Equal(t, "", testrepo.ReadFile(t, targetUrl, "main", "generated-main.yaml"))
+}
@@ -0,0 +1,301 @@
+package acceptance
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newSshSigner(t *testing.T) ssh.Signer {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	signer, err := ssh.NewSignerFromKey(private)
+	require.Nil(t, err)
+	return signer
+}
+
+// sshGitServer serves the local repositories over ssh, running git-upload-pack and git-receive-pack for
+// any client key. Repositories are addressed by their absolute path. Returns the url prefix and the
+// address as ssh clients see it.
+func sshGitServer(t *testing.T, hostKey ssh.Signer) (urlPrefix string, hostname string) {
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git is not installed")
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSshConn(conn, config)
+		}
+	}()
+	return "ssh://git@" + listener.Addr().String(), listener.Addr().String()
+}
+
+func serveSshConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only sessions")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveGitCommand(channel, channelRequests)
+	}
+}
+
+// serveGitCommand runs the command of the first exec request, e.g. git-upload-pack '/path/repo.git'
+func serveGitCommand(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for request := range requests {
+		if request.Type != "exec" {
+			_ = request.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if ssh.Unmarshal(request.Payload, &payload) != nil {
+			_ = request.Reply(false, nil)
+			return
+		}
+		fields := strings.SplitN(payload.Command, " ", 2)
+		if len(fields) != 2 || (fields[0] != "git-upload-pack" && fields[0] != "git-receive-pack") {
+			_ = request.Reply(false, nil)
+			return
+		}
+		_ = request.Reply(true, nil)
+
+		cmd := exec.Command(fields[0], strings.Trim(fields[1], "'"))
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = channel, channel, channel.Stderr()
+		status := make([]byte, 4)
+		if err := cmd.Run(); err != nil {
+			binary.BigEndian.PutUint32(status, 1)
+		}
+		_, _ = channel.SendRequest("exit-status", false, status)
+		return
+	}
+}
+
+func sshClientAuth(t *testing.T) *gitssh.PublicKeys {
+	return &gitssh.PublicKeys{User: "git", Signer: newSshSigner(t)}
+}
+
+// sshAgent serves an ssh agent holding a new key, and points SSH_AUTH_SOCK at it for the test.
+func sshAgent(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	keyring := agent.NewKeyring()
+	require.Nil(t, keyring.Add(agent.AddedKey{PrivateKey: private}))
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+}
+
+func TestHostKeys_KnownHosts(t *testing.T) {
+	docs.Given("a git server reachable over ssh, and its host key in known_hosts format")
+	hostKey := newSshSigner(t)
+	urlPrefix, hostname := sshGitServer(t, hostKey)
+	sourcePath := testrepo.Create(t, localGeneratorFiles())
+	targetPath := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	knownHosts := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, hostKey.PublicKey()) + "\n"
+
+	docs.When("a job that pushes over ssh is run with the known hosts")
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: urlPrefix + sourcePath, Auth: sshClientAuth(t)},
+		Target:      api.TargetSpec{Url: urlPrefix + targetPath, Branch: "main", Auth: sshClientAuth(t)},
+		Generator:   "main",
+		Commit:      &api.CommitSpec{},
+		Push:        &api.PushSpec{},
+		HostKeys:    &api.HostKeyVerification{KnownHosts: []byte(knownHosts)},
+	})
+
+	docs.Then("the repositories are cloned and the commit is pushed")
+	require.Nil(t, err)
+	require.Len(t, result.Commit.PushResults, 1)
+	require.Contains(t, testrepo.ReadFile(t, targetPath, "main", "generated-main.yaml"), "demo-service")
+}
+
+func TestHostKeys_PinnedFingerprintMismatch(t *testing.T) {
+	docs.Given("a git server reachable over ssh")
+	urlPrefix, _ := sshGitServer(t, newSshSigner(t))
+	sourcePath := testrepo.Create(t, localGeneratorFiles())
+	targetPath := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.When("a job is run with a different fingerprint pinned for the host")
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: urlPrefix + sourcePath, Auth: sshClientAuth(t)},
+		Target:      api.TargetSpec{Url: targetPath, Branch: "main"},
+		Generator:   "main",
+		HostKeys: &api.HostKeyVerification{Fingerprints: map[string][]string{
+			"127.0.0.1": {ssh.FingerprintSHA256(newSshSigner(t).PublicKey())},
+		}},
+	})
+
+	docs.Then("cloning the source fails because the host key is rejected")
+	require.ErrorIs(t, err, api.ErrHostKeyRejected)
+	require.Equal(t, api.PhaseCloneSource, result.Phases[1].Name)
+}
+
+func TestHostKeys_TrustOnFirstUse(t *testing.T) {
+	docs.Given("a git server reachable over ssh, and an empty trust on first use store")
+	hostKey := newSshSigner(t)
+	urlPrefix, hostname := sshGitServer(t, hostKey)
+	sourcePath := testrepo.Create(t, localGeneratorFiles())
+	store := filepath.Join(t.TempDir(), "known_hosts")
+	run := func() error {
+		_, err := generatorgit.Run(context.TODO(), api.Job{
+			WorkdirBase: t.TempDir(),
+			Source:      api.SourceSpec{Url: urlPrefix + sourcePath, Auth: sshClientAuth(t)},
+			Target:      api.TargetSpec{Url: testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"}), Branch: "main"},
+			Generator:   "main",
+			HostKeys:    &api.HostKeyVerification{TrustOnFirstUse: store},
+		})
+		return err
+	}
+
+	docs.When("jobs are run against the server")
+	docs.Then("the host key is stored on first contact, and accepted afterwards")
+	require.Nil(t, run())
+	contents, err := os.ReadFile(store)
+	require.Nil(t, err)
+	require.Equal(t, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, hostKey.PublicKey())+"\n", string(contents))
+	require.Nil(t, run())
+
+	docs.When("the store holds a different key for the host")
+	otherKey := newSshSigner(t).PublicKey()
+	require.Nil(t, os.WriteFile(store, []byte(knownhosts.Line([]string{knownhosts.Normalize(hostname)}, otherKey)+"\n"), 0600))
+
+	docs.Then("the server is rejected")
+	require.ErrorIs(t, run(), api.ErrHostKeyRejected)
+}
+
+func TestHostKeys_NoAuthUsesTheSshAgent(t *testing.T) {
+	docs.Given("a git server reachable over ssh, and an ssh agent, but no auth in the job")
+	hostKey := newSshSigner(t)
+	urlPrefix, hostname := sshGitServer(t, hostKey)
+	sshAgent(t)
+	sourcePath := testrepo.Create(t, localGeneratorFiles())
+	run := func(verification *api.HostKeyVerification) error {
+		_, err := generatorgit.Run(context.TODO(), api.Job{
+			WorkdirBase: t.TempDir(),
+			Source:      api.SourceSpec{Url: urlPrefix + sourcePath},
+			Target:      api.TargetSpec{Url: testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"}), Branch: "main"},
+			Generator:   "main",
+			HostKeys:    verification,
+		})
+		return err
+	}
+
+	docs.When("a job is run with the host key in the known hosts")
+	docs.Then("the source is cloned with the keys of the agent")
+	require.Nil(t, run(&api.HostKeyVerification{
+		KnownHosts: []byte(knownhosts.Line([]string{knownhosts.Normalize(hostname)}, hostKey.PublicKey()) + "\n"),
+	}))
+
+	docs.When("a job is run with a different fingerprint pinned for the host")
+	docs.Then("the host key is rejected, rather than checked against ~/.ssh/known_hosts")
+	require.ErrorIs(t, run(&api.HostKeyVerification{Fingerprints: map[string][]string{
+		"127.0.0.1": {ssh.FingerprintSHA256(newSshSigner(t).PublicKey())},
+	}}), api.ErrHostKeyRejected)
+}
+
+func TestHostKeys_NoAuthAndNoSshAgent(t *testing.T) {
+	docs.Given("a job cloning over ssh without auth, and no ssh agent")
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	docs.When("it is run with host key verification")
+	_, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: "ssh://git@127.0.0.1:1/some-org/generator.git"},
+		Target:      api.TargetSpec{Url: testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"}), Branch: "main"},
+		Generator:   "main",
+		HostKeys:    &api.HostKeyVerification{TrustOnFirstUse: filepath.Join(t.TempDir(), "known_hosts")},
+	})
+
+	docs.Then("it fails, instead of connecting without the verification")
+	require.ErrorContains(t, err, "cannot verify the host key")
+}
+
+func TestHostKeys_CommitWithoutPushAuthDoesNotPush(t *testing.T) {
+	docs.Given("a target cloned over ssh with host key verification, and no ssh agent")
+	t.Setenv("SSH_AUTH_SOCK", "")
+	hostKey := newSshSigner(t)
+	urlPrefix, hostname := sshGitServer(t, hostKey)
+	targetPath := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	ctx := context.TODO()
+
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.SetHostKeyVerification(&api.HostKeyVerification{
+		KnownHosts: []byte(knownhosts.Line([]string{knownhosts.Normalize(hostname)}, hostKey.PublicKey()) + "\n"),
+	}))
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+	_, err := gen.CloneSourceRepo(ctx, testrepo.Create(t, localGeneratorFiles()), "main", nil)
+	require.Nil(t, err)
+	targetRepo, err := gen.CloneTargetRepo(ctx, urlPrefix+targetPath, "main", "main", sshClientAuth(t))
+	require.Nil(t, err)
+	_, err = gen.WriteRenderSpecFile(ctx, "main", "generated-main.yaml", map[string]interface{}{})
+	require.Nil(t, err)
+	_, err = gen.Generate(ctx)
+	require.Nil(t, err)
+
+	docs.When("the result is committed without push auth")
+	result, err := gen.CommitAndPushWithResult(ctx, "somebody", "somebody@mailinator.com", "generate", nil)
+
+	docs.Then("the commit is created locally and nothing is pushed")
+	require.Nil(t, err)
+	require.NotEqual(t, "", result.CommitHash)
+	require.Empty(t, result.PushResults)
+	require.Contains(t, testrepo.ReadFile(t, targetRepo.GetLocalPath(), "main", "generated-main.yaml"), "demo-service")
+	require.Equal(t, "", testrepo.ReadFile(t, targetPath, "main", "generated-main.yaml"))
+}
@@ -0,0 +1,62 @@
+package acceptance
+
+import (
+	"context"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// local repositories, so tests of individual features do not depend on network access
+
+const localGeneratorSpec = `templates:
+  - source: src/main.go.tmpl
+    target: cmd/{{ .serviceName }}/main.go
+  - source: src/README.md.tmpl
+    target: README.md
+variables:
+  serviceName:
+    description: The name of the service
+    pattern: '^[a-z-]+$'
+    default: demo-service
+`
+
+// the file transport ignores auth, but CommitAndPush only pushes if it gets some
+var localPushAuth = &githttp.BasicAuth{Username: "local"}
+
+// generateLocally runs a full generation cycle against local repositories and pushes the result.
+//
+// configure is called after both repositories have been cloned, and may be nil.
+func generateLocally(t *testing.T, sourceUrl string, targetUrl string, parameters map[string]interface{}, configure func(gen api.GitApi)) (*api.GenerateResult, *api.CommitResult, error) {
+	ctx := context.TODO()
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+
+	_, err := gen.CloneSourceRepo(ctx, sourceUrl, "main", nil)
+	require.Nil(t, err)
+	_, err = gen.CloneTargetRepo(ctx, targetUrl, "main", "main", nil)
+	require.Nil(t, err)
+	if configure != nil {
+		configure(gen)
+	}
+
+	_, err = gen.WriteRenderSpecFile(ctx, "main", "generated-main.yaml", parameters)
+	require.Nil(t, err)
+	generateResult, err := gen.GenerateWithResult(ctx)
+	if err != nil {
+		return generateResult, nil, err
+	}
+	commitResult, err := gen.CommitAndPushWithResult(ctx, "somebody", "somebody@mailinator.com", "generate", localPushAuth)
+	return generateResult, commitResult, err
+}
+
+func localGeneratorFiles() map[string]string {
+	return map[string]string{
+		"generator-main.yaml": localGeneratorSpec,
+		"src/main.go.tmpl":    "package main\n\n// {{ .serviceName }}\nfunc main() {\n}\n",
+		"src/README.md.tmpl":  "# {{ .serviceName }}\n\nThis service was generated.\n",
+	}
+}
@@ -0,0 +1,63 @@
+package acceptance
+
+import (
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMerge_HumanEditsSurviveRegeneration(t *testing.T) {
+	docs.Given("a target that was generated with the merge strategy")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	useMerge := func(gen api.GitApi) {
+		gen.SetUpdateStrategy(api.UpdateMerge)
+	}
+	generateResult, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, useMerge)
+	require.Nil(t, err)
+	require.Empty(t, generateResult.Conflicts)
+	require.Equal(t, "package main\n\n// demo-service\nfunc main() {\n}\n", testrepo.ReadFile(t, targetUrl, "main", "cmd/demo-service/main.go"))
+
+	docs.Given("human edits to the generated files")
+	testrepo.PushCommit(t, targetUrl, map[string]string{
+		"cmd/demo-service/main.go": "package main\n\n// demo-service\nfunc main() {\n\thuman()\n}\n",
+		"README.md":                "# demo-service\n\nThis service was written by hand.\n",
+	}, "human edits")
+
+	docs.Given("a newer generator version that changes the same files")
+	testrepo.PushCommit(t, sourceUrl, map[string]string{
+		"src/main.go.tmpl":   "package main\n\n// {{ .serviceName }}\nfunc main() {\n}\n\nfunc generated() {\n}\n",
+		"src/README.md.tmpl": "# {{ .serviceName }}\n\nThis service was generated by a newer generator.\n",
+	}, "newer generator version")
+
+	docs.When("the target is regenerated with the merge strategy")
+	generateResult, _, err = generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, useMerge)
+	require.Nil(t, err)
+
+	docs.Then("changes to different lines are merged")
+	require.Equal(t, "package main\n\n// demo-service\nfunc main() {\n\thuman()\n}\n\nfunc generated() {\n}\n",
+		testrepo.ReadFile(t, targetUrl, "main", "cmd/demo-service/main.go"))
+
+	docs.Then("changes to the same lines produce conflict markers and a conflict report")
+	require.Equal(t, []api.MergeConflict{{Path: "README.md", Regions: 1}}, generateResult.Conflicts)
+	require.Equal(t, "# demo-service\n\n<<<<<<< target\nThis service was written by hand.\n=======\nThis service was generated by a newer generator.\n>>>>>>> generated\n",
+		testrepo.ReadFile(t, targetUrl, "main", "README.md"))
+}
+
+func TestMerge_WithoutBaseEveryDifferenceConflicts(t *testing.T) {
+	docs.Given("a target that already has a file the generator produces, but no record of an earlier generation")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{"README.md": "# hand written\n"})
+
+	docs.When("the target is generated with the merge strategy")
+	generateResult, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, func(gen api.GitApi) {
+		gen.SetUpdateStrategy(api.UpdateMerge)
+	})
+
+	docs.Then("the existing file is not silently overwritten")
+	require.Nil(t, err)
+	require.Equal(t, []api.MergeConflict{{Path: "README.md", Regions: 1}}, generateResult.Conflicts)
+	require.Contains(t, testrepo.ReadFile(t, targetUrl, "main", "README.md"), "# hand written\n=======\n")
+}
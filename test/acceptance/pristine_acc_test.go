@@ -0,0 +1,102 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPristine_GeneratorOutputIsMergedFromItsOwnBranch(t *testing.T) {
+	docs.Given("a target that was generated with the pristine branch strategy")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	usePristine := func(gen api.GitApi) {
+		gen.SetUpdateStrategy(api.UpdatePristineBranch)
+	}
+	generateResult, commitResult, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, usePristine)
+	require.Nil(t, err)
+	require.Empty(t, generateResult.Conflicts)
+
+	docs.Then("the pure generator output lives on the pristine branch, and the target branch merged it")
+	require.Equal(t, "package main\n\n// demo-service\nfunc main() {\n}\n", testrepo.ReadFile(t, targetUrl, "generator/pristine/main", "cmd/demo-service/main.go"))
+	require.Equal(t, "", testrepo.ReadFile(t, targetUrl, "generator/pristine/main", ".gitignore"))
+	require.Equal(t, "package main\n\n// demo-service\nfunc main() {\n}\n", testrepo.ReadFile(t, targetUrl, "main", "cmd/demo-service/main.go"))
+	require.Equal(t, "*.tmp\n", testrepo.ReadFile(t, targetUrl, "main", ".gitignore"))
+	requireMergeCommit(t, targetUrl, commitResult.CommitHash, "generator/pristine/main")
+
+	docs.Given("human edits to the generated files")
+	testrepo.PushCommit(t, targetUrl, map[string]string{
+		"cmd/demo-service/main.go": "package main\n\n// demo-service\nfunc main() {\n\thuman()\n}\n",
+	}, "human edits")
+
+	docs.Given("a newer generator version that changes the same file and no longer produces the README")
+	testrepo.PushCommit(t, sourceUrl, map[string]string{
+		"generator-main.yaml": "templates:\n  - source: src/main.go.tmpl\n    target: cmd/{{ .serviceName }}/main.go\nvariables:\n  serviceName:\n    default: demo-service\n",
+		"src/main.go.tmpl":    "package main\n\n// {{ .serviceName }}\nfunc main() {\n}\n\nfunc generated() {\n}\n",
+	}, "newer generator version")
+
+	docs.When("the target is regenerated with the pristine branch strategy")
+	generateResult, commitResult, err = generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, usePristine)
+	require.Nil(t, err)
+
+	docs.Then("git history provides the merge base, so human edits and generator changes are combined")
+	require.Empty(t, generateResult.Conflicts)
+	require.Equal(t, "package main\n\n// demo-service\nfunc main() {\n\thuman()\n}\n\nfunc generated() {\n}\n",
+		testrepo.ReadFile(t, targetUrl, "main", "cmd/demo-service/main.go"))
+	requireMergeCommit(t, targetUrl, commitResult.CommitHash, "generator/pristine/main")
+
+	docs.Then("the file that is no longer generated is removed")
+	require.Equal(t, []string{"README.md"}, generateResult.PrunedFiles)
+	require.Equal(t, "", testrepo.ReadFile(t, targetUrl, "main", "README.md"))
+	require.Equal(t, "", testrepo.ReadFile(t, targetUrl, "generator/pristine/main", "README.md"))
+}
+
+func requireMergeCommit(t *testing.T, repoPath string, commitHash string, mergedBranch string) {
+	repo, err := git.PlainOpen(repoPath)
+	require.Nil(t, err)
+	commit, err := repo.CommitObject(plumbing.NewHash(commitHash))
+	require.Nil(t, err)
+	merged, err := repo.Reference(plumbing.NewBranchReferenceName(mergedBranch), true)
+	require.Nil(t, err)
+	require.Equal(t, 2, commit.NumParents())
+	require.Equal(t, merged.Hash(), commit.ParentHashes[1])
+}
+
+func TestPristine_RefusesToDeleteUncommittedFiles(t *testing.T) {
+	docs.Given("a target with an untracked file and an ignored file")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	ctx := context.TODO()
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+	_, err := gen.CloneSourceRepo(ctx, sourceUrl, "main", nil)
+	require.Nil(t, err)
+	targetRepo, err := gen.CloneTargetRepo(ctx, targetUrl, "main", "main", nil)
+	require.Nil(t, err)
+	stray := filepath.Join(targetRepo.GetLocalPath(), "notes.txt")
+	ignored := filepath.Join(targetRepo.GetLocalPath(), "build", "cache.tmp")
+	require.Nil(t, os.WriteFile(stray, []byte("notes"), 0644))
+	require.Nil(t, os.MkdirAll(filepath.Dir(ignored), 0755))
+	require.Nil(t, os.WriteFile(ignored, []byte("cache"), 0644))
+
+	docs.When("it is generated with the pristine branch strategy")
+	gen.SetUpdateStrategy(api.UpdatePristineBranch)
+	_, err = gen.WriteRenderSpecFile(ctx, "main", "generated-main.yaml", map[string]interface{}{})
+	require.Nil(t, err)
+	_, err = gen.Generate(ctx)
+
+	docs.Then("generating fails and names the files, instead of deleting them")
+	require.ErrorIs(t, err, api.ErrUnexpectedChanges)
+	require.ErrorContains(t, err, "build/cache.tmp, notes.txt")
+	require.FileExists(t, stray)
+	require.FileExists(t, ignored)
+}
@@ -0,0 +1,62 @@
+package acceptance
+
+import (
+	"context"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestProgress_EventsForEveryPhase(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.When("a job is run that reports its progress to a channel")
+	events := make(chan api.ProgressEvent, 1000)
+	_, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Commit:      &api.CommitSpec{},
+		Push:        &api.PushSpec{Auth: localPushAuth},
+		Progress:    api.ProgressChannel(events),
+	})
+	require.Nil(t, err)
+	close(events)
+
+	docs.Then("every phase is reported as started and finished, in order")
+	var phases []string
+	var rendered []api.ProgressEvent
+	for event := range events {
+		switch event.Kind {
+		case api.ProgressPhaseStarted:
+			phases = append(phases, "+"+event.Phase)
+		case api.ProgressPhaseFinished:
+			require.Nil(t, event.Err)
+			phases = append(phases, "-"+event.Phase)
+		case api.ProgressRender:
+			rendered = append(rendered, event)
+		}
+	}
+	require.Equal(t, []string{
+		"+" + api.PhaseCreateWorkdir, "-" + api.PhaseCreateWorkdir,
+		"+" + api.PhaseCloneSource, "-" + api.PhaseCloneSource,
+		"+" + api.PhaseCloneTarget, "-" + api.PhaseCloneTarget,
+		"+" + api.PhaseWriteRenderSpec, "-" + api.PhaseWriteRenderSpec,
+		"+" + api.PhaseGenerate, "-" + api.PhaseGenerate,
+		"+" + api.PhaseCommitAndPush, "-" + api.PhaseCommitAndPush,
+		"+" + api.PhaseCleanup, "-" + api.PhaseCleanup,
+	}, phases)
+
+	docs.Then("rendering reports the number of rendered files")
+	require.Len(t, rendered, 2)
+	require.Equal(t, 0, rendered[0].Total)
+	require.Equal(t, -1, rendered[0].Percent)
+	require.Equal(t, 2, rendered[1].Current)
+	require.Equal(t, 100, rendered[1].Percent)
+}
@@ -0,0 +1,123 @@
+package acceptance
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/repository/manifest"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrune_OrphanedFilesAreDeleted(t *testing.T) {
+	docs.Given("a generator that produces two files which it will later stop producing")
+	files := localGeneratorFiles()
+	files["generator-main.yaml"] = `templates:
+  - source: src/README.md.tmpl
+    target: README.md
+  - source: src/old.md.tmpl
+    target: docs/old.md
+  - source: src/old.md.tmpl
+    target: docs/edited.md
+variables:
+  serviceName:
+    default: demo-service
+`
+	files["src/old.md.tmpl"] = "old documentation\n"
+	sourceUrl := testrepo.Create(t, files)
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	enablePruning := func(gen api.GitApi) {
+		gen.SetPruneOrphans(true)
+	}
+
+	docs.Given("a target that was generated with pruning enabled")
+	_, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, enablePruning)
+	require.Nil(t, err)
+	require.Equal(t, "old documentation\n", testrepo.ReadFile(t, targetUrl, "main", "docs/old.md"))
+	require.Contains(t, testrepo.ReadFile(t, targetUrl, "main", "generated-main.manifest.yaml"), "docs/old.md")
+
+	docs.Given("a human edit to one of the generated files, and a newer generator version that no longer produces them")
+	testrepo.PushCommit(t, targetUrl, map[string]string{"docs/edited.md": "edited by a human\n"}, "human edit")
+	testrepo.PushCommit(t, sourceUrl, map[string]string{"generator-main.yaml": `templates:
+  - source: src/README.md.tmpl
+    target: README.md
+variables:
+  serviceName:
+    default: demo-service
+`}, "drop old docs")
+
+	docs.When("the target is regenerated")
+	generateResult, commitResult, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, enablePruning)
+
+	docs.Then("the unchanged orphan is deleted in the commit, and the edited one is kept and reported")
+	require.Nil(t, err)
+	require.Equal(t, []string{"docs/old.md"}, generateResult.PrunedFiles)
+	require.Equal(t, []string{"docs/edited.md"}, generateResult.ModifiedOrphans)
+	require.Equal(t, []string{"docs/old.md"}, commitResult.PrunedFiles)
+	require.Equal(t, "", testrepo.ReadFile(t, targetUrl, "main", "docs/old.md"))
+	require.Equal(t, "edited by a human\n", testrepo.ReadFile(t, targetUrl, "main", "docs/edited.md"))
+	require.NotContains(t, testrepo.ReadFile(t, targetUrl, "main", "generated-main.manifest.yaml"), "docs/")
+}
+
+// victimFile is a file outside of any repository that a hostile manifest tries to get deleted.
+func victimFile(t *testing.T) (path string, sha256 string) {
+	path = filepath.Join(t.TempDir(), "known_hosts")
+	require.Nil(t, os.WriteFile(path, []byte("precious\n"), 0600))
+	return path, manifest.Hash([]byte("precious\n"))
+}
+
+func TestPrune_ManifestPathsOutsideTheTargetAreRefused(t *testing.T) {
+	docs.Given("a file outside the target, and a committed manifest that lists it by a path leading out of the target")
+	victim, sha256 := victimFile(t)
+	escape := strings.Repeat("../", 30) + strings.TrimPrefix(filepath.ToSlash(victim), "/")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	for _, listed := range []string{escape, victim} {
+		targetUrl := testrepo.Create(t, map[string]string{
+			"generated-main.manifest.yaml": fmt.Sprintf("generator: main\nfiles:\n- path: %s\n  sha256: %s\n", listed, sha256),
+		})
+
+		docs.When("the target is regenerated with pruning enabled")
+		_, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, func(gen api.GitApi) {
+			gen.SetPruneOrphans(true)
+		})
+
+		docs.Then("generating fails, and the file is left alone")
+		require.NotNil(t, err)
+		require.Contains(t, err.Error(), "not a path inside the target")
+		require.FileExists(t, victim)
+	}
+}
+
+func TestPrune_SymlinksOutOfTheTargetAreNotFollowed(t *testing.T) {
+	docs.Given("a file outside the target, a committed symlink to its directory, and a manifest listing it through the symlink")
+	victim, sha256 := victimFile(t)
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{
+		"generated-main.manifest.yaml": fmt.Sprintf("generator: main\nfiles:\n- path: outside/known_hosts\n  sha256: %s\n", sha256),
+	})
+	clonePath := filepath.Join(t.TempDir(), "clone")
+	repo, err := git.PlainClone(clonePath, false, &git.CloneOptions{URL: targetUrl})
+	require.Nil(t, err)
+	escape := strings.Repeat("../", 30) + strings.TrimPrefix(filepath.ToSlash(filepath.Dir(victim)), "/")
+	require.Nil(t, os.Symlink(escape, filepath.Join(clonePath, "outside")))
+	worktree, err := repo.Worktree()
+	require.Nil(t, err)
+	_, err = worktree.Add("outside")
+	require.Nil(t, err)
+	testrepo.CommitFiles(t, repo, clonePath, map[string]string{}, "add symlink")
+	require.Nil(t, repo.Push(&git.PushOptions{}))
+
+	docs.When("the target is regenerated with pruning enabled")
+	_, _, err = generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, func(gen api.GitApi) {
+		gen.SetPruneOrphans(true)
+	})
+
+	docs.Then("the file behind the symlink is left alone")
+	require.Nil(t, err)
+	require.FileExists(t, victim)
+}
@@ -0,0 +1,69 @@
+package acceptance
+
+import (
+	"context"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gitServerAnswering is a git http server that answers every request with the given status.
+func gitServerAnswering(t *testing.T, status int) (url string, requests *int32) {
+	requests = new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/generator.git", requests
+}
+
+var fastRetries = api.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func TestRetry_TransientFailuresAreRetried(t *testing.T) {
+	docs.Given("a git server that is temporarily unavailable")
+	sourceUrl, requests := gitServerAnswering(t, http.StatusServiceUnavailable)
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	workdirBase := t.TempDir()
+
+	docs.When("a job is run with a retry policy of 3 attempts")
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: workdirBase,
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Retry:       fastRetries,
+	})
+
+	docs.Then("cloning the source is tried 3 times before the session fails, and nothing is left behind")
+	require.NotNil(t, err)
+	require.Equal(t, api.PhaseCloneSource, result.Phases[1].Name)
+	require.Equal(t, int32(3), atomic.LoadInt32(requests))
+	requireEmptyDir(t, workdirBase)
+}
+
+func TestRetry_PermanentFailuresAreNot(t *testing.T) {
+	docs.Given("a git server that does not know the repository")
+	sourceUrl, requests := gitServerAnswering(t, http.StatusNotFound)
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.When("a job is run with a retry policy of 3 attempts")
+	_, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Retry:       fastRetries,
+	})
+
+	docs.Then("cloning the source is tried only once")
+	require.ErrorIs(t, err, api.ErrRepositoryNotFound)
+	require.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
@@ -0,0 +1,123 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestRun_WholePipeline(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	workdirBase := t.TempDir()
+
+	docs.When("a job is run that generates, commits and pushes")
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: workdirBase,
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "feature", BaseBranch: "main"},
+		Generator:   "main",
+		Parameters:  map[string]interface{}{"serviceName": "job-service"},
+		Commit:      &api.CommitSpec{AuthorName: "somebody", AuthorEmail: "somebody@mailinator.com"},
+		Push:        &api.PushSpec{Auth: localPushAuth},
+	})
+
+	docs.Then("every phase succeeds and the generated files are pushed")
+	require.Nil(t, err)
+	require.True(t, result.Success())
+	var phases []string
+	for _, p := range result.Phases {
+		phases = append(phases, p.Name)
+	}
+	require.Equal(t, []string{api.PhaseCreateWorkdir, api.PhaseCloneSource, api.PhaseCloneTarget, api.PhaseWriteRenderSpec,
+		api.PhaseGenerate, api.PhaseCommitAndPush, api.PhaseCleanup}, phases)
+	require.Equal(t, "generated-main.yaml", result.RenderSpecFile)
+	require.Len(t, result.Generate.RenderedFiles, 2)
+	require.NotEmpty(t, result.Commit.CommitHash)
+	require.Contains(t, testrepo.ReadFile(t, targetUrl, "feature", "cmd/job-service/main.go"), "job-service")
+
+	docs.Then("the working directory is cleaned up")
+	entries, err := os.ReadDir(workdirBase)
+	require.Nil(t, err)
+	require.Empty(t, entries)
+}
+
+func TestRun_SourceAtTagOrRevision(t *testing.T) {
+	docs.Given("a generator repository with a tagged commit, followed by a commit that breaks the generator")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	repo, err := git.PlainOpen(sourceUrl)
+	require.Nil(t, err)
+	head, err := repo.Head()
+	require.Nil(t, err)
+	_, err = repo.CreateTag("v1", head.Hash(), nil)
+	require.Nil(t, err)
+	testrepo.PushCommit(t, sourceUrl, map[string]string{"generator-main.yaml": "broken: ["}, "break the generator")
+
+	for name, ref := range map[string]string{"tag": "v1", "revision": head.Hash().String(), "short revision": head.Hash().String()[:7]} {
+		t.Run(name, func(t *testing.T) {
+			targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+			docs.When("a job is run with the source at the " + name)
+			_, err := generatorgit.Run(context.TODO(), api.Job{
+				WorkdirBase:  t.TempDir(),
+				Source:       api.SourceSpec{Url: sourceUrl, Branch: ref},
+				Target:       api.TargetSpec{Url: targetUrl, Branch: "main"},
+				Generator:    "main",
+				PruneOrphans: true,
+				Commit:       &api.CommitSpec{},
+				Push:         &api.PushSpec{Auth: localPushAuth},
+			})
+
+			docs.Then("the generator is rendered as of that commit")
+			require.Nil(t, err)
+			require.Contains(t, testrepo.ReadFile(t, targetUrl, "main", "generated-main.yaml"), "demo-service")
+			require.Contains(t, testrepo.ReadFile(t, targetUrl, "main", "generated-main.manifest.yaml"), "source_revision: "+head.Hash().String())
+		})
+	}
+}
+
+func TestRun_InvalidParametersStopThePipeline(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	workdirBase := t.TempDir()
+
+	docs.When("a job is run with a parameter that does not match the pattern")
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: workdirBase,
+		Source:      api.SourceSpec{Url: sourceUrl},
+		Target:      api.TargetSpec{Url: targetUrl, Branch: "main"},
+		Generator:   "main",
+		Parameters:  map[string]interface{}{"serviceName": "Not Valid"},
+		Commit:      &api.CommitSpec{},
+	})
+
+	docs.Then("the pipeline stops after writing the render spec, reports the parameter error, and still cleans up")
+	require.NotNil(t, err)
+	require.False(t, result.Success())
+	last := result.Phases[len(result.Phases)-2]
+	require.Equal(t, api.PhaseWriteRenderSpec, last.Name)
+	require.NotNil(t, last.Err)
+	require.NotEmpty(t, result.RenderSpec.Errors)
+	require.Nil(t, result.Generate)
+	require.Equal(t, api.PhaseCleanup, result.Phases[len(result.Phases)-1].Name)
+	entries, err := os.ReadDir(workdirBase)
+	require.Nil(t, err)
+	require.Empty(t, entries)
+}
+
+func TestRun_IncompleteJob(t *testing.T) {
+	docs.When("a job without a generator is run")
+	result, err := generatorgit.Run(context.TODO(), api.Job{Source: api.SourceSpec{Url: "a"}, Target: api.TargetSpec{Url: "b", Branch: "main"}})
+
+	docs.Then("it fails before doing anything")
+	require.NotNil(t, err)
+	require.Empty(t, result.Phases)
+}
@@ -0,0 +1,76 @@
+package acceptance
+
+import (
+	"context"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaging_GeneratedOnly(t *testing.T) {
+	docs.Given("a local generator source and target repository")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	ctx := context.TODO()
+
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+	_, err := gen.CloneSourceRepo(ctx, sourceUrl, "main", nil)
+	require.Nil(t, err)
+	targetRepo, err := gen.CloneTargetRepo(ctx, targetUrl, "main", "main", nil)
+	require.Nil(t, err)
+
+	docs.Given("a stray file that was not written by the generator")
+	require.Nil(t, os.WriteFile(filepath.Join(targetRepo.GetLocalPath(), "stray.log"), []byte("leftover"), 0644))
+
+	docs.When("the generator is run with staging mode StageGeneratedOnly")
+	gen.SetStagingMode(api.StageGeneratedOnly)
+	_, err = gen.WriteRenderSpecFile(ctx, "main", "generated-main.yaml", map[string]interface{}{})
+	require.Nil(t, err)
+	_, err = gen.Generate(ctx)
+	require.Nil(t, err)
+	result, err := gen.CommitAndPushWithResult(ctx, "somebody", "somebody@mailinator.com", "generate", nil)
+
+	docs.Then("only the generated files are committed, and the stray file is reported")
+	require.Nil(t, err)
+	require.Equal(t, []string{"stray.log"}, result.IgnoredPaths)
+	require.Equal(t, "", testrepo.ReadFile(t, targetRepo.GetLocalPath(), "main", "stray.log"))
+	require.Contains(t, testrepo.ReadFile(t, targetRepo.GetLocalPath(), "main", "cmd/demo-service/main.go"), "demo-service")
+	require.Contains(t, testrepo.ReadFile(t, targetRepo.GetLocalPath(), "main", "generated-main.yaml"), "demo-service")
+}
+
+func TestStaging_GeneratedOnlyStrict(t *testing.T) {
+	docs.Given("a local generator source and target repository with a stray file in the target")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	ctx := context.TODO()
+
+	gen := generatorgit.ThreadsafeInstance()
+	require.Nil(t, gen.CreateTemporaryWorkdir(ctx, t.TempDir()))
+	defer gen.Cleanup(ctx)
+	_, err := gen.CloneSourceRepo(ctx, sourceUrl, "main", nil)
+	require.Nil(t, err)
+	targetRepo, err := gen.CloneTargetRepo(ctx, targetUrl, "main", "main", nil)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(filepath.Join(targetRepo.GetLocalPath(), "stray.log"), []byte("leftover"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(targetRepo.GetLocalPath(), "ignored.tmp"), []byte("ignored"), 0644))
+
+	docs.When("the generator is run with staging mode StageGeneratedOnlyStrict")
+	gen.SetStagingMode(api.StageGeneratedOnlyStrict)
+	_, err = gen.WriteRenderSpecFile(ctx, "main", "generated-main.yaml", map[string]interface{}{})
+	require.Nil(t, err)
+	_, err = gen.Generate(ctx)
+	require.Nil(t, err)
+	result, err := gen.CommitAndPushWithResult(ctx, "somebody", "somebody@mailinator.com", "generate", nil)
+
+	docs.Then("the commit is refused because of the stray file, while ignored files do not matter")
+	require.NotNil(t, err)
+	require.Equal(t, []string{"stray.log"}, result.IgnoredPaths)
+	require.Equal(t, "", result.CommitHash)
+}
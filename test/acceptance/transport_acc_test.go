@@ -0,0 +1,155 @@
+package acceptance
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/go-git/go-git/v5"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for the tls servers and clients of a test
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM encoded certificate and key for 127.0.0.1, usable by servers and clients
+func (ca *testCA) issue(t *testing.T, serial int64) (certPem []byte, keyPem []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// gitTlsServer serves the local repositories over https with git http-backend, and requires client
+// certificates issued by ca. Repositories are addressed by their absolute path.
+func gitTlsServer(t *testing.T, ca *testCA) string {
+	backend, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	server := httptest.NewUnstartedServer(&cgi.Handler{
+		Path: string(backend[:len(backend)-1]) + "/git-http-backend",
+		Env:  []string{"GIT_PROJECT_ROOT=/", "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	certPem, keyPem := ca.issue(t, 2)
+	certificate, err := tls.X509KeyPair(certPem, keyPem)
+	require.Nil(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// allowPush lets git http-backend accept pushes to the repository without authentication
+func allowPush(t *testing.T, repoPath string) {
+	repo, err := git.PlainOpen(repoPath)
+	require.Nil(t, err)
+	config, err := repo.Config()
+	require.Nil(t, err)
+	config.Raw.Section("http").SetOption("receivepack", "true")
+	require.Nil(t, repo.SetConfig(config))
+}
+
+func TestTransport_PrivateCAAndClientCertificate(t *testing.T) {
+	docs.Given("a git server with a certificate of a private CA, that requires client certificates")
+	ca := newTestCA(t)
+	serverUrl := gitTlsServer(t, ca)
+	sourcePath := testrepo.Create(t, localGeneratorFiles())
+	targetPath := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	allowPush(t, targetPath)
+
+	docs.When("a job that pushes is run with the CA bundle and a client certificate for the host")
+	clientCert, clientKey := ca.issue(t, 3)
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: serverUrl + sourcePath},
+		Target:      api.TargetSpec{Url: serverUrl + targetPath, Branch: "main"},
+		Generator:   "main",
+		Commit:      &api.CommitSpec{},
+		Push:        &api.PushSpec{Auth: localPushAuth},
+		Transport: []api.HostTransport{
+			{Host: "127.0.0.1", CABundle: ca.pem, ClientCertificate: clientCert, ClientKey: clientKey},
+		},
+	})
+
+	docs.Then("both repositories are cloned and the commit is pushed over https")
+	require.Nil(t, err)
+	require.Len(t, result.Commit.PushResults, 1)
+	require.Contains(t, testrepo.ReadFile(t, targetPath, "main", "generated-main.yaml"), "demo-service")
+}
+
+func TestTransport_UntrustedServer(t *testing.T) {
+	docs.Given("a git server with a certificate of a private CA")
+	ca := newTestCA(t)
+	serverUrl := gitTlsServer(t, ca)
+	sourcePath := testrepo.Create(t, localGeneratorFiles())
+	targetPath := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+
+	docs.When("a job is run with transport settings for a different host only")
+	clientCert, clientKey := ca.issue(t, 3)
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase: t.TempDir(),
+		Source:      api.SourceSpec{Url: serverUrl + sourcePath},
+		Target:      api.TargetSpec{Url: targetPath, Branch: "main"},
+		Generator:   "main",
+		Transport: []api.HostTransport{
+			{Host: "git.example.com", CABundle: ca.pem, ClientCertificate: clientCert, ClientKey: clientKey},
+		},
+	})
+
+	docs.Then("cloning the source fails because its certificate is not trusted")
+	require.ErrorContains(t, err, "certificate")
+	require.Equal(t, api.PhaseCloneSource, result.Phases[1].Name)
+}
@@ -0,0 +1,61 @@
+package acceptance
+
+import (
+	"context"
+	generatorgit "github.com/mplushnikov/go-generator-git/v2"
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestUrlRewriting_AliasesAndInsteadOf(t *testing.T) {
+	docs.Given("a generator known by an alias, and a target only reachable through a mirror")
+	sourceUrl := testrepo.Create(t, localGeneratorFiles())
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	publicTargetUrl := "https://github.example.com/some-org/remote.git"
+	rewriting := &api.UrlRewriting{
+		Aliases:   map[string]string{"templates/go-rest": sourceUrl},
+		InsteadOf: map[string]string{"https://github.example.com/some-org/": filepath.Dir(targetUrl) + "/"},
+	}
+
+	docs.When("a job using the alias and the public url is run with the rewriting rules")
+	result, err := generatorgit.Run(context.TODO(), api.Job{
+		WorkdirBase:  t.TempDir(),
+		Source:       api.SourceSpec{Url: "templates/go-rest", Branch: "main"},
+		Target:       api.TargetSpec{Url: publicTargetUrl, Branch: "main"},
+		Generator:    "main",
+		PruneOrphans: true,
+		UrlRewriting: rewriting,
+		Commit:       &api.CommitSpec{},
+		Push:         &api.PushSpec{Auth: localPushAuth},
+	})
+
+	docs.Then("the rewritten urls are cloned from and pushed to")
+	require.Nil(t, err)
+	require.Contains(t, testrepo.ReadFile(t, targetUrl, "main", "README.md"), "This service was generated.")
+
+	docs.Then("the result records both the original and the rewritten urls")
+	require.Equal(t, api.RepositoryUrl{Original: "templates/go-rest", Rewritten: sourceUrl}, result.Source)
+	require.Equal(t, api.RepositoryUrl{Original: publicTargetUrl, Rewritten: targetUrl}, result.Target)
+	require.Equal(t, targetUrl, result.Commit.PushResults[0].RemoteUrl)
+	require.Equal(t, publicTargetUrl, result.Commit.PushResults[0].OriginalUrl)
+
+	docs.Then("the manifest records where the generator came from")
+	manifest := testrepo.ReadFile(t, targetUrl, "main", "generated-main.manifest.yaml")
+	require.Contains(t, manifest, "source_url: templates/go-rest\n")
+	require.Contains(t, manifest, "source_url_rewritten: "+sourceUrl+"\n")
+}
+
+func TestUrlRewriting_InvalidRules(t *testing.T) {
+	docs.Given("rewriting rules with an empty prefix")
+	rewriting := &api.UrlRewriting{InsteadOf: map[string]string{"": "ssh://git@mirror.example.com/"}}
+
+	docs.When("they are set")
+	err := generatorgit.ThreadsafeInstance().SetUrlRewriting(rewriting)
+
+	docs.Then("they are rejected")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,84 @@
+package acceptance
+
+import (
+	"github.com/mplushnikov/go-generator-git/v2/api"
+	"github.com/mplushnikov/go-generator-git/v2/docs"
+	"github.com/mplushnikov/go-generator-git/v2/internal/testrepo"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUserRegions_SurviveRegeneration(t *testing.T) {
+	docs.Given("a generator whose main.go template has user regions")
+	files := localGeneratorFiles()
+	files["src/main.go.tmpl"] = "package main\n\n// BEGIN USER CODE: imports\n// END USER CODE\n\nfunc main() {\n\t// BEGIN USER CODE: main\n\t// END USER CODE\n}\n"
+	sourceUrl := testrepo.Create(t, files)
+	targetUrl := testrepo.Create(t, map[string]string{".gitignore": "*.tmp\n"})
+	preserve := func(gen api.GitApi) {
+		gen.SetPreserveUserRegions(true)
+	}
+	_, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, preserve)
+	require.Nil(t, err)
+
+	docs.Given("code written by humans inside the user regions")
+	testrepo.PushCommit(t, targetUrl, map[string]string{
+		"cmd/demo-service/main.go": "package main\n\n// BEGIN USER CODE: imports\nimport \"fmt\"\n// END USER CODE\n\nfunc main() {\n\t// BEGIN USER CODE: main\n\tfmt.Println(\"hello\")\n\t// END USER CODE\n}\n",
+	}, "human edits")
+
+	docs.Given("a newer generator version that changes the file and drops the imports region")
+	testrepo.PushCommit(t, sourceUrl, map[string]string{
+		"src/main.go.tmpl": "package main\n\nfunc main() {\n\tsetup()\n\t// BEGIN USER CODE: main\n\t// END USER CODE\n}\n",
+	}, "newer generator version")
+
+	docs.When("the target is regenerated with user regions preserved")
+	generateResult, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, preserve)
+	require.Nil(t, err)
+
+	docs.Then("the generated changes are applied and the remaining user region keeps its content")
+	require.Equal(t, "package main\n\nfunc main() {\n\tsetup()\n\t// BEGIN USER CODE: main\n\tfmt.Println(\"hello\")\n\t// END USER CODE\n}\n",
+		testrepo.ReadFile(t, targetUrl, "main", "cmd/demo-service/main.go"))
+
+	docs.Then("the region that no longer exists is reported along with its content")
+	require.Equal(t, []api.OrphanedRegion{{Path: "cmd/demo-service/main.go", Name: "imports", Content: "import \"fmt\"\n"}},
+		generateResult.OrphanedRegions)
+}
+
+func TestUserRegions_UnterminatedRegionsFailGeneration(t *testing.T) {
+	docs.Given("a target where a human deleted the end marker of a user region")
+	files := localGeneratorFiles()
+	files["src/main.go.tmpl"] = "package main\n\nfunc main() {\n\t// BEGIN USER CODE: main\n\t// END USER CODE\n}\n\nfunc generated() {}\n"
+	sourceUrl := testrepo.Create(t, files)
+	broken := "package main\n\nfunc main() {\n\t// BEGIN USER CODE: main\n\thuman()\n}\n\nfunc generated() {}\n"
+	targetUrl := testrepo.Create(t, map[string]string{"cmd/demo-service/main.go": broken})
+	preserve := func(gen api.GitApi) {
+		gen.SetPreserveUserRegions(true)
+	}
+
+	docs.When("the target is regenerated with user regions preserved")
+	_, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, preserve)
+
+	docs.Then("generating fails and names the file and region, instead of duplicating generated code")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "cmd/demo-service/main.go")
+	require.Contains(t, err.Error(), "user region main starting in line 4")
+	require.Equal(t, broken, testrepo.ReadFile(t, targetUrl, "main", "cmd/demo-service/main.go"))
+}
+
+func TestUserRegions_UnterminatedRegionsInTemplatesFailGeneration(t *testing.T) {
+	docs.Given("a generator whose template lacks the end marker of a user region")
+	files := localGeneratorFiles()
+	files["src/main.go.tmpl"] = "package main\n\nfunc main() {\n\t// BEGIN USER CODE: main\n}\n\nfunc generated() {}\n"
+	sourceUrl := testrepo.Create(t, files)
+	targetUrl := testrepo.Create(t, map[string]string{
+		"cmd/demo-service/main.go": "package main\n\nfunc main() {\n\t// BEGIN USER CODE: main\n\thuman()\n\t// END USER CODE\n}\n",
+	})
+
+	docs.When("the target is regenerated with user regions preserved")
+	_, _, err := generateLocally(t, sourceUrl, targetUrl, map[string]interface{}{}, func(gen api.GitApi) {
+		gen.SetPreserveUserRegions(true)
+	})
+
+	docs.Then("generating fails as a rendering error, instead of dropping the rest of the generated file")
+	require.ErrorIs(t, err, api.ErrRenderFailed)
+	require.Contains(t, err.Error(), "user region main starting in line 4")
+}